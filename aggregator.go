@@ -0,0 +1,253 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An aggregator reduces the metrics sent during a flush window before they are
+// written to the buffer. It is guarded by the mutex of its connection.
+type aggregator struct {
+	pack            bool
+	packBytes       int
+	timings         bool
+	percentiles     []float64
+	sketchAccuracy  float64
+	sketchQuantiles []float64
+	series          map[seriesKey]*summary
+}
+
+// A seriesKey identifies the metrics aggregated together.
+type seriesKey struct {
+	t    Type
+	name string
+	tags string
+	rate float32 // Only set for packed series.
+}
+
+// A summary is the summary of the values of a series.
+type summary struct {
+	tagFormat TagFormat
+	count     float64
+	sum       float64
+	min       float64
+	max       float64
+	values    []float64 // Used to compute exact percentiles or packed.
+	sketch    *ddSketch
+	packed    bool
+}
+
+func newAggregator(conf connConfig) *aggregator {
+	if !conf.TimingSummaries && conf.SketchAccuracy == 0 && !conf.PackTimings {
+		return nil
+	}
+	packBytes := conf.MaxPacketSize / 2
+	if packBytes < minPackBytes {
+		packBytes = minPackBytes
+	}
+	return &aggregator{
+		pack:            conf.PackTimings,
+		packBytes:       packBytes,
+		timings:         conf.TimingSummaries,
+		percentiles:     conf.Percentiles,
+		sketchAccuracy:  conf.SketchAccuracy,
+		sketchQuantiles: conf.SketchQuantiles,
+		series:          make(map[seriesKey]*summary),
+	}
+}
+
+// aggregates returns whether the metrics of type t are aggregated.
+func (a *aggregator) aggregates(t Type) bool {
+	switch t {
+	case TIMINGS:
+		return a.timings || a.pack
+	case HISTOGRAM:
+		return a.sketchAccuracy > 0
+	}
+	return false
+}
+
+// add aggregates the metric m. It returns false if m is not aggregated and
+// must be sent as is.
+func (a *aggregator) add(m *Metric) bool {
+	if !a.aggregates(m.Type) {
+		return false
+	}
+	v, ok := toFloat64(m.Value)
+	if !ok {
+		return false
+	}
+	if m.Type == TIMINGS && !a.timings {
+		return a.addPacked(m, v)
+	}
+	w := 1.0
+	if m.Rate > 0 && m.Rate < 1 {
+		w = 1 / float64(m.Rate)
+	}
+	k := seriesKey{t: m.Type, name: m.Name(), tags: m.Tags}
+	s := a.series[k]
+	if s == nil {
+		s = &summary{tagFormat: m.tagFormat, min: v, max: v}
+		if m.Type == HISTOGRAM {
+			s.sketch = newDDSketch(a.sketchAccuracy)
+		}
+		a.series[k] = s
+	}
+	s.count += w
+	s.sum += v * w
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+	if s.sketch != nil {
+		s.sketch.add(v, w)
+	} else if len(a.percentiles) > 0 {
+		s.values = append(s.values, v)
+	}
+	return true
+}
+
+// addPacked adds the timing m of value v to the values sent packed in a single
+// line, which needs the same sample rate.
+func (a *aggregator) addPacked(m *Metric, v float64) bool {
+	if !m.Timestamp.IsZero() {
+		return false
+	}
+	k := seriesKey{t: m.Type, name: m.Name(), tags: m.Tags, rate: m.Rate}
+	s := a.series[k]
+	if s == nil {
+		s = &summary{tagFormat: m.tagFormat, packed: true}
+		a.series[k] = s
+	}
+	s.values = append(s.values, v)
+	return true
+}
+
+// flush passes the metrics summarizing the window to send and starts a new
+// window.
+func (a *aggregator) flush(send func(*Metric)) {
+	m := GetMetric()
+	defer m.Release()
+	for _, k := range sortedKeys(a.series) {
+		s := a.series[k]
+		m.Rate = 1
+		m.Tags = k.tags
+		m.tagFormat = s.tagFormat
+		if s.packed {
+			a.flushPacked(send, m, k, s.values)
+			delete(a.series, k)
+			continue
+		}
+		emit := func(suffix string, t Type, v float64) {
+			m.Type = t
+			m.Bucket = k.name + suffix
+			m.Value = v
+			send(m)
+		}
+		emit(".count", COUNT, s.count)
+		emit(".min", GAUGE, s.min)
+		emit(".max", GAUGE, s.max)
+		emit(".sum", GAUGE, s.sum)
+		emit(".avg", GAUGE, s.sum/s.count)
+		if s.sketch != nil {
+			for _, p := range a.sketchQuantiles {
+				emit(percentileSuffix(p), GAUGE, s.sketch.quantile(p/100))
+			}
+		} else if len(a.percentiles) > 0 {
+			sort.Float64s(s.values)
+			for _, p := range a.percentiles {
+				emit(percentileSuffix(p), GAUGE, percentile(s.values, p))
+			}
+		}
+		delete(a.series, k)
+	}
+}
+
+// flushPacked passes to send the values of the packed series k, in lines of at
+// most about packBytes bytes.
+func (a *aggregator) flushPacked(send func(*Metric), m *Metric, k seriesKey, values []float64) {
+	m.Type = k.t
+	m.Bucket = k.name
+	m.Rate = k.rate
+	for len(values) > 0 {
+		n, size := 0, len(k.name)+len(k.tags)
+		for n < len(values) && (n == 0 || size < a.packBytes) {
+			size += len(strconv.FormatFloat(values[n], 'f', -1, 64)) + 1
+			n++
+		}
+		m.Value = values[:n]
+		send(m)
+		values = values[n:]
+	}
+}
+
+// sortedKeys returns the keys of the series sorted by name, tags, type and
+// rate, so the aggregates are sent in a stable order.
+func sortedKeys(series map[seriesKey]*summary) []seriesKey {
+	keys := make([]seriesKey, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		if keys[i].tags != keys[j].tags {
+			return keys[i].tags < keys[j].tags
+		}
+		if keys[i].t != keys[j].t {
+			return keys[i].t < keys[j].t
+		}
+		return keys[i].rate > keys[j].rate
+	})
+	return keys
+}
+
+// percentile returns the p-th percentile of the sorted values, using the
+// nearest-rank method.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// percentileSuffix returns the bucket suffix of the p-th percentile, e.g.
+// ".p99_9" for 99.9.
+func percentileSuffix(p float64) string {
+	return ".p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", 1)
+}
+
+// minPackBytes is the minimum size of the lines of packed values.
+const minPackBytes = 512
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingSummaries(t *testing.T) {
+	testOutput(t, "test_key:1|c\n"+
+		"test_key.count:5|c\ntest_key.min:1|g\ntest_key.max:10|g\ntest_key.sum:22|g\ntest_key.avg:4.4|g\ntest_key.p50:3|g\ntest_key.p99_9:10|g\n"+
+		"test_key.count,a=b:1|c\ntest_key.min,a=b:7|g\ntest_key.max,a=b:7|g\ntest_key.sum,a=b:7|g\ntest_key.avg,a=b:7|g\ntest_key.p50,a=b:7|g\ntest_key.p99_9,a=b:7|g", func(c *Client) {
+		c.Increment(testKey)
+		for _, v := range []int{3, 1, 10, 2, 6} {
+			c.Timing(testKey, v)
+		}
+		c.Clone(Tags("a", "b")).Timing(testKey, 7)
+		c.Flush()
+	}, TimingSummaries(50, 99.9), TagsFormat(InfluxDB))
+}
+
+func TestTimingSummariesSampleRate(t *testing.T) {
+	randFloat = func() float32 { return 0 }
+	defer func() { randFloat = rand.Float32 }()
+
+	testOutput(t, "test_key.count:4|c\ntest_key.min:2|g\ntest_key.max:4|g\ntest_key.sum:12|g\ntest_key.avg:3|g", func(c *Client) {
+		c.Timing(testKey, 2)
+		c.Timing(testKey, 4)
+		c.Flush()
+	}, TimingSummaries(), SampleRate(0.5))
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := map[float64]float64{10: 1, 50: 5, 90: 9, 95: 10, 100: 10}
+	for p, want := range tests {
+		if got := percentile(values, p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestPackTimings(t *testing.T) {
+	testOutput(t, "test_key:1|c\nother:2|ms\nother:1|ms|@0.5\ntest_key:12:7:3.5|ms", func(c *Client) {
+		c.Increment(testKey)
+		c.Timing(testKey, 12)
+		c.Timing(testKey, 7)
+		c.Timing(testKey, 3.5)
+		c.Timing("other", 1, func(m *Metric) { m.Rate = 0.5 })
+		c.Timing("other", 2)
+		c.Flush()
+	}, PackTimings())
+}
+
+func TestPackTimingsSplit(t *testing.T) {
+	a := newAggregator(connConfig{PackTimings: true})
+	m := &Metric{Type: TIMINGS, Bucket: testKey, Rate: 1}
+	for i := 0; i < 200; i++ {
+		m.Value = 100 + i
+		a.add(m)
+	}
+	values := 0
+	s := &StatsDSerializer{}
+	a.flush(func(m *Metric) {
+		line := s.AppendMetric(nil, m)
+		if len(line) > minPackBytes+20 {
+			t.Errorf("line of %d bytes, want at most about %d", len(line), minPackBytes)
+		}
+		values += strings.Count(string(line), ":")
+	})
+	if values != 200 {
+		t.Errorf("%d values sent, want 200", values)
+	}
+}
+
+func TestAggregatesFlushedOnClose(t *testing.T) {
+	testOutput(t, "test_key.count:1|c\ntest_key.min:5|g\ntest_key.max:5|g\ntest_key.sum:5|g\ntest_key.avg:5|g", func(c *Client) {
+		c.Timing(testKey, 5)
+	}, TimingSummaries(), FlushPeriod(time.Hour))
+}
+
+func TestAggregationStats(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Timing(testKey, 1)
+		c.Timing(testKey, 2)
+		c.Timing("other", 3)
+		c.Increment(testKey)
+		s := c.Stats()
+		if s.AggregatedSamples != 3 || s.AggregatedSeries != 2 {
+			t.Errorf("Stats() = %+v, want 3 samples in 2 series", s)
+		}
+		c.Flush()
+		s = c.Stats()
+		if s.AggregatedSamples != 3 || s.AggregatedSeries != 0 || s.AggregationFlushDuration <= 0 {
+			t.Errorf("Stats() = %+v, want 3 samples, no series and the flush duration", s)
+		}
+		c.Close()
+	}, TimingSummaries())
+}
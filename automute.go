@@ -0,0 +1,62 @@
+package statsd
+
+import "sync/atomic"
+
+// isAutoMuted returns whether the connection is muted by the AutoMute option.
+func (c *conn) isAutoMuted() bool {
+	return atomic.LoadInt32(&c.autoMuted) != 0
+}
+
+// muteFailed mutes the connection after muteAfter consecutive failed flushes.
+// The connection is then probed until it recovers.
+func (c *conn) muteFailed() {
+	if c.muteAfter == 0 || c.isAutoMuted() || c.failedFlushes < c.muteAfter {
+		return
+	}
+	atomic.StoreInt32(&c.autoMuted, 1)
+	c.wstats.AutoMutes++
+	c.logf("muting the metrics sent to %s after %d failures, probing every %v", c.addr, c.muteAfter, c.probePeriod)
+	if c.onAutoMute != nil {
+		c.onAutoMute(c.addr, true)
+	}
+	go c.probeLoop()
+}
+
+// probeLoop tries to connect to the StatsD daemon every probe period until it
+// succeeds, and then unmutes the connection.
+func (c *conn) probeLoop() {
+	ticker := c.clock.NewTicker(c.probePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C():
+		}
+		if c.probe() {
+			return
+		}
+	}
+}
+
+// probe tries to connect to the StatsD daemon and unmutes the connection if it
+// succeeds. It returns whether the probing is over.
+func (c *conn) probe() bool {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return true
+	}
+	if c.w == nil {
+		if err := c.dial(); err != nil {
+			return false
+		}
+	}
+	atomic.StoreInt32(&c.autoMuted, 0)
+	c.failedFlushes = 0
+	c.logf("%s %s is reachable again, unmuting the metrics", c.network, c.addr)
+	if c.onAutoMute != nil {
+		c.onAutoMute(c.addr, false)
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoMute(t *testing.T) {
+	var down int32 = 1
+	var dials int32
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		if atomic.LoadInt32(&down) != 0 {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	events := make(chan bool, 2)
+	clk := &fakeClock{t: testDate}
+	c, err := New(AutoMute(2, time.Second), OnAutoMute(func(addr string, muted bool) {
+		events <- muted
+	}), UseClock(clk), FlushPeriod(0), ErrorHandler(func(error) {}))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	c.Increment(testKey)
+	c.Flush()
+	c.Increment(testKey)
+	c.Flush()
+	if muted := <-events; !muted {
+		t.Fatal("the Client should be muted after 2 failed flushes")
+	}
+	c.Increment(testKey)
+	if metrics, _ := c.Pending(); metrics != 0 {
+		t.Errorf("Pending() = %d, want the metrics to be dropped while muted", metrics)
+	}
+	if s := c.Stats(); s.AutoMutes != 1 || s.Dropped != 3 {
+		t.Errorf("Stats() = %+v, want 1 auto mute and 3 dropped metrics", s)
+	}
+
+	for clk.active() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	n := atomic.LoadInt32(&dials)
+	clk.Advance(time.Second)
+	for atomic.LoadInt32(&dials) == n {
+		time.Sleep(time.Millisecond)
+	}
+	atomic.StoreInt32(&down, 0)
+	clk.Advance(time.Second)
+	if muted := <-events; muted {
+		t.Fatal("the Client should be unmuted once the StatsD daemon is reachable")
+	}
+
+	c.Count(testKey, 2)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:2|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	c.Close()
+}
@@ -0,0 +1,45 @@
+package statsd
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ReportBuildInfo sends at every poll, see GaugeFunc:
+//   - a "build_info" gauge of value 1 tagged with the version of the main
+//     module, the Go version and the VCS commit of the binary, so dashboards
+//     get deploy markers,
+//   - an "uptime" count of the seconds elapsed since the previous poll, or
+//     since the process started for the first poll, as a liveness heartbeat.
+//
+// The tags are only sent if the Client has a TagFormat.
+func (c *Client) ReportBuildInfo() {
+	version, commit := "unknown", "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Version != "" {
+			version = bi.Main.Version
+		}
+		if rev := buildCommit(bi); rev != "" {
+			commit = rev
+		}
+	}
+	bc := c.Clone(Tags("version", version, "go_version", runtime.Version(), "commit", commit))
+
+	var mu sync.Mutex
+	last := processStart
+	c.addPoller(func() {
+		bc.Gauge("build_info", 1)
+
+		mu.Lock()
+		t := c.conn.clock.Now()
+		elapsed := t.Sub(last)
+		last = t
+		mu.Unlock()
+		c.Count("uptime", elapsed.Seconds())
+	})
+}
+
+// processStart is the approximate start time of the process.
+var processStart = time.Now()
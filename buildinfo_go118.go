@@ -0,0 +1,16 @@
+//go:build go1.18
+// +build go1.18
+
+package statsd
+
+import "runtime/debug"
+
+// buildCommit returns the VCS revision the binary was built from.
+func buildCommit(bi *debug.BuildInfo) string {
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package statsd
+
+import "runtime/debug"
+
+// buildCommit returns the VCS revision the binary was built from, which is
+// only recorded since Go 1.18.
+func buildCommit(bi *debug.BuildInfo) string {
+	return ""
+}
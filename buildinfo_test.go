@@ -0,0 +1,36 @@
+package statsd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportBuildInfo(t *testing.T) {
+	date := processStart.Add(90 * time.Second)
+	now = func() time.Time { return date }
+	defer func() { now = time.Now }()
+
+	testClient(t, func(c *Client) {
+		c.ReportBuildInfo()
+		c.conn.poll()
+		date = date.Add(10 * time.Second)
+		c.conn.poll()
+
+		lines := c.PendingLines()
+		if len(lines) != 4 {
+			t.Fatalf("PendingLines() = %q, want 4 metrics", lines)
+		}
+		if !strings.HasPrefix(lines[0], "build_info:1|g|#version:") || !strings.Contains(lines[0], ",go_version:"+runtime.Version()+",commit:") {
+			t.Errorf("build_info = %q", lines[0])
+		}
+		if got, want := lines[1], "uptime:90|c"; got != want {
+			t.Errorf("uptime = %q, want %q", got, want)
+		}
+		if got, want := lines[3], "uptime:10|c"; got != want {
+			t.Errorf("uptime = %q, want %q", got, want)
+		}
+		c.Close()
+	}, TagsFormat(Datadog))
+}
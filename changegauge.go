@@ -0,0 +1,80 @@
+package statsd
+
+import (
+	"sync"
+	"time"
+)
+
+// A ChangeGauge is a gauge which is only sent when its value changes, which
+// cuts the traffic of slowly-changing values like configuration versions or
+// pool sizes. It is safe for concurrent use.
+type ChangeGauge struct {
+	c         *Client
+	bucket    string
+	heartbeat time.Duration
+	opts      []MetricOption
+
+	mu     sync.Mutex
+	set    bool
+	last   float64
+	sentAt time.Time
+}
+
+// NewChangeGauge returns a ChangeGauge sending its value to bucket. If
+// heartbeat is positive, the last value is also sent again when it has not
+// been sent for heartbeat, so the gauge does not look stale. The heartbeat is
+// checked by Set and at every flush period, or at every poll period if the
+// PollPeriod option is used, until Stop is called.
+func (c *Client) NewChangeGauge(bucket string, heartbeat time.Duration, opts ...MetricOption) *ChangeGauge {
+	g := &ChangeGauge{c: c, bucket: bucket, heartbeat: heartbeat, opts: opts}
+	if heartbeat > 0 && !c.muted {
+		c.conn.pollMu.Lock()
+		if c.conn.changeGauges == nil {
+			c.conn.changeGauges = make(map[*ChangeGauge]struct{})
+		}
+		c.conn.changeGauges[g] = struct{}{}
+		c.conn.pollMu.Unlock()
+	}
+	return g
+}
+
+// Stop stops the heartbeat of the gauge, so that it can be garbage collected.
+// The gauge is still sent by Set.
+func (g *ChangeGauge) Stop() {
+	g.c.conn.pollMu.Lock()
+	delete(g.c.conn.changeGauges, g)
+	g.c.conn.pollMu.Unlock()
+}
+
+// Set sets the value of the gauge. It is sent if it is the first value, if it
+// differs from the previous one or if the heartbeat has elapsed.
+func (g *ChangeGauge) Set(v float64) {
+	g.mu.Lock()
+	t := g.c.conn.clock.Now()
+	send := !g.set || v != g.last || (g.heartbeat > 0 && t.Sub(g.sentAt) >= g.heartbeat)
+	if send {
+		g.set = true
+		g.last = v
+		g.sentAt = t
+	}
+	g.mu.Unlock()
+	if send {
+		g.c.Gauge(g.bucket, v, g.opts...)
+	}
+}
+
+// beat sends the last value again if the heartbeat has elapsed since it was
+// sent.
+func (g *ChangeGauge) beat() {
+	g.mu.Lock()
+	t := g.c.conn.clock.Now()
+	send := g.set && t.Sub(g.sentAt) >= g.heartbeat
+	if send {
+		g.sentAt = t
+	}
+	v := g.last
+	g.mu.Unlock()
+	if send {
+		g.c.Gauge(g.bucket, v, g.opts...)
+	}
+}
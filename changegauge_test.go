@@ -0,0 +1,42 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangeGauge(t *testing.T) {
+	date := testDate
+	now = func() time.Time { return date }
+	defer func() { now = time.Now }()
+
+	testOutput(t, "test_key:1|g\ntest_key:2|g\ntest_key:1|g\ntest_key:1|g", func(c *Client) {
+		g := c.NewChangeGauge(testKey, time.Minute)
+		g.Set(1)
+		g.Set(1)
+		g.Set(2)
+		g.Set(1)
+		date = date.Add(59 * time.Second)
+		g.Set(1)
+		date = date.Add(time.Second)
+		g.Set(1)
+	})
+}
+
+func TestChangeGaugeHeartbeat(t *testing.T) {
+	clk := &fakeClock{t: testDate}
+	testOutput(t, "test_key:1|g\ntest_key:1|g\ntest_key:1|g", func(c *Client) {
+		g := c.NewChangeGauge(testKey, time.Minute)
+		g.Set(1)
+		clk.Advance(59 * time.Second)
+		c.conn.poll()
+		clk.Advance(time.Second)
+		c.conn.poll()
+		c.conn.poll()
+		clk.Advance(time.Minute)
+		c.conn.poll()
+		g.Stop()
+		clk.Advance(time.Minute)
+		c.conn.poll()
+	}, UseClock(clk))
+}
@@ -0,0 +1,39 @@
+package statsd
+
+import "time"
+
+// A Clock tells the time to a Client and schedules its periodic tasks, like
+// the flushes. It can be replaced with UseClock, e.g. by a fake clock to test
+// the instrumentation of an application.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// A Ticker delivers ticks at intervals, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// systemClock is the default Clock, based on the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+// sleep waits for d on the clock clk.
+func sleep(clk Clock, d time.Duration) {
+	t := clk.NewTicker(d)
+	<-t.C()
+	t.Stop()
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
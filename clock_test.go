@@ -0,0 +1,123 @@
+package statsd
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	t       time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clk     *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clk: c, c: make(chan time.Time, 1), period: d, next: c.t.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the time forward by d and fires the tickers which are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.t) {
+			select {
+			case t.c <- c.t:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// ticker returns the i-th ticker created, or nil if there are less tickers.
+func (c *fakeClock) ticker(i int) *fakeTicker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i < len(c.tickers) {
+		return c.tickers[i]
+	}
+	return nil
+}
+
+// active returns the number of tickers which are not stopped.
+func (c *fakeClock) active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.tickers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clk.mu.Lock()
+	t.stopped = true
+	t.clk.mu.Unlock()
+}
+
+func TestUseClock(t *testing.T) {
+	dialTimeout = mockDial
+	defer func() { dialTimeout = net.DialTimeout }()
+	clk := &fakeClock{t: testDate}
+	c, err := New(UseClock(clk), FlushPeriod(time.Second), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	timing := c.NewTiming()
+	clk.Advance(250 * time.Millisecond)
+	if got := timing.Duration(); got != 250*time.Millisecond {
+		t.Errorf("Duration() = %v, want 250ms", got)
+	}
+	timing.Send(testKey)
+
+	for clk.active() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Second)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if n, _ := c.Pending(); n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the fake clock did not trigger a flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.conn.mu.Lock()
+	got := getOutput(c)
+	c.conn.mu.Unlock()
+	if want := "test_key:250|ms"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
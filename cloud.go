@@ -0,0 +1,168 @@
+package statsd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Stubbed out for testing.
+	ec2MetadataURL   = "http://169.254.169.254"
+	gceMetadataURL   = "http://metadata.google.internal"
+	azureMetadataURL = "http://169.254.169.254"
+
+	cloudTagsOnce  sync.Once
+	cloudTagsCache []tag
+
+	// metadataClient is the HTTP client of the metadata services, which must
+	// not be reached through a proxy.
+	metadataClient = &http.Client{Transport: &http.Transport{}}
+)
+
+// CloudTags appends the cloud_provider, instance_id, zone and instance_type
+// tags to the tags sent with every metrics when the process runs on an EC2,
+// GCE or Azure instance. The tags are looked up with the metadata services of
+// the cloud providers, which are all queried concurrently for at most timeout.
+//
+// The tags are only looked up the first time the option is used by a Client
+// and cached for the lifetime of the process. No tag is added if the lookups
+// fail.
+func CloudTags(timeout time.Duration) Option {
+	return Option(func(c *config) {
+		cloudTagsOnce.Do(func() {
+			cloudTagsCache = lookupCloudTags(timeout)
+		})
+		c.Client.Tags = append(c.Client.Tags, cloudTagsCache...)
+	})
+}
+
+// lookupCloudTags queries the metadata services and returns the tags of the
+// first one answering.
+func lookupCloudTags(timeout time.Duration) []tag {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	lookups := []func(context.Context) []tag{ec2Tags, gceTags, azureTags}
+	results := make(chan []tag, len(lookups))
+	for _, lookup := range lookups {
+		go func(lookup func(context.Context) []tag) {
+			results <- lookup(ctx)
+		}(lookup)
+	}
+	for range lookups {
+		select {
+		case tags := <-results:
+			if tags != nil {
+				return tags
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// ec2Tags returns the tags of an EC2 instance, using IMDSv2.
+func ec2Tags(ctx context.Context) []tag {
+	token, err := metadataGet(ctx, http.MethodPut, ec2MetadataURL+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err != nil {
+		return nil
+	}
+	header := map[string]string{"X-aws-ec2-metadata-token": token}
+	tags := []tag{{K: "cloud_provider", V: "aws"}}
+	for _, t := range [][2]string{
+		{"instance_id", "instance-id"},
+		{"zone", "placement/availability-zone"},
+		{"instance_type", "instance-type"},
+	} {
+		v, err := metadataGet(ctx, http.MethodGet, ec2MetadataURL+"/latest/meta-data/"+t[1], header)
+		if err != nil {
+			return nil
+		}
+		tags = append(tags, tag{K: t[0], V: v})
+	}
+	return tags
+}
+
+// gceTags returns the tags of a GCE instance.
+func gceTags(ctx context.Context) []tag {
+	header := map[string]string{"Metadata-Flavor": "Google"}
+	tags := []tag{{K: "cloud_provider", V: "gcp"}}
+	for _, t := range [][2]string{
+		{"instance_id", "id"},
+		{"zone", "zone"},
+		{"instance_type", "machine-type"},
+	} {
+		v, err := metadataGet(ctx, http.MethodGet, gceMetadataURL+"/computeMetadata/v1/instance/"+t[1], header)
+		if err != nil {
+			return nil
+		}
+		// The zone and machine type are resource paths, e.g.
+		// "projects/42/zones/us-central1-a".
+		tags = append(tags, tag{K: t[0], V: v[strings.LastIndexByte(v, '/')+1:]})
+	}
+	return tags
+}
+
+// azureTags returns the tags of an Azure virtual machine.
+func azureTags(ctx context.Context) []tag {
+	body, err := metadataGet(ctx, http.MethodGet, azureMetadataURL+"/metadata/instance/compute?api-version=2021-02-01", map[string]string{
+		"Metadata": "true",
+	})
+	if err != nil {
+		return nil
+	}
+	var compute struct {
+		VMID     string `json:"vmId"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMSize   string `json:"vmSize"`
+	}
+	if err := json.Unmarshal([]byte(body), &compute); err != nil || compute.VMID == "" {
+		return nil
+	}
+	zone := compute.Location
+	if compute.Zone != "" {
+		zone += "-" + compute.Zone
+	}
+	return []tag{
+		{K: "cloud_provider", V: "azure"},
+		{K: "instance_id", V: compute.VMID},
+		{K: "zone", V: zone},
+		{K: "instance_type", V: compute.VMSize},
+	}
+}
+
+// metadataGet sends a request to a metadata service and returns the body of
+// the response.
+func metadataGet(ctx context.Context, method, url string, header map[string]string) (string, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("statsd: %s: %s", url, resp.Status)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,76 @@
+package statsd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloudTagsGCE(t *testing.T) {
+	gce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/id":
+			w.Write([]byte("4242"))
+		case "/computeMetadata/v1/instance/zone":
+			w.Write([]byte("projects/42/zones/europe-west1-b"))
+		case "/computeMetadata/v1/instance/machine-type":
+			w.Write([]byte("projects/42/machineTypes/n1-standard-1"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gce.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+	setCloudURLs(down.URL, gce.URL, down.URL)
+	defer setCloudURLs("http://169.254.169.254", "http://metadata.google.internal", "http://169.254.169.254")
+
+	testOutput(t, "test_key:1|c|#cloud_provider:gcp,instance_id:4242,zone:europe-west1-b,instance_type:n1-standard-1", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), CloudTags(time.Second))
+}
+
+func TestCloudTagsAzure(t *testing.T) {
+	azure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Path != "/metadata/instance/compute" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"vmId":"02aab8a4","location":"westeurope","zone":"1","vmSize":"Standard_D2s_v3"}`))
+	}))
+	defer azure.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+	setCloudURLs(down.URL, down.URL, azure.URL)
+	defer setCloudURLs("http://169.254.169.254", "http://metadata.google.internal", "http://169.254.169.254")
+
+	testOutput(t, "test_key:1|c|#cloud_provider:azure,instance_id:02aab8a4,zone:westeurope-1,instance_type:Standard_D2s_v3", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), CloudTags(time.Second))
+}
+
+func TestCloudTagsTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	setCloudURLs(slow.URL, slow.URL, slow.URL)
+	defer setCloudURLs("http://169.254.169.254", "http://metadata.google.internal", "http://169.254.169.254")
+
+	testOutput(t, "test_key:1|c", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), CloudTags(10*time.Millisecond))
+}
+
+// setCloudURLs sets the URLs of the metadata services and resets the cache.
+func setCloudURLs(ec2, gce, azure string) {
+	ec2MetadataURL, gceMetadataURL, azureMetadataURL = ec2, gce, azure
+	cloudTagsOnce = sync.Once{}
+	cloudTagsCache = nil
+}
@@ -0,0 +1,61 @@
+package statsd
+
+import "time"
+
+// Config is a snapshot of the effective configuration of a Client, once all
+// its options, including the DSN and the environment, have been applied.
+type Config struct {
+	// Addr and Network are the address and the network of the StatsD daemon
+	// the Client currently sends to, which change with the FallbackNetwork
+	// and Failover options.
+	Addr    string
+	Network string
+	Muted   bool
+	// Rate is the sample rate of the metrics and Rates the sample rates of
+	// the metrics of the types set by SampleRateFor.
+	Rate  float32
+	Rates map[Type]float32
+	// Prefix is the prefix of the buckets, with its trailing dot.
+	Prefix string
+	// Tags are the tags sent with every metric, as key/value pairs.
+	Tags          []string
+	TagFormat     TagFormat
+	TimingUnit    TimeUnit
+	MaxPacketSize int
+	FlushPeriod   time.Duration
+	DialTimeout   time.Duration
+	WriteTimeout  time.Duration
+}
+
+// Config returns the effective configuration of the Client, e.g. to log it
+// when the options come from several layers of configuration. The returned
+// Config is a copy: modifying it does not change the Client.
+func (c *Client) Config() Config {
+	conf := Config{
+		Muted:         c.muted,
+		Rate:          c.rate,
+		Prefix:        c.prefix,
+		TagFormat:     c.conn.tagFormat,
+		TimingUnit:    c.timingUnit,
+		MaxPacketSize: c.conn.maxPacketSize,
+		FlushPeriod:   c.conn.flushPeriod,
+		DialTimeout:   c.conn.dialTimeout,
+		WriteTimeout:  c.conn.writeTimeout,
+	}
+	if len(c.rates) > 0 {
+		conf.Rates = make(map[Type]float32, len(c.rates))
+		for t, rate := range c.rates {
+			conf.Rates[t] = rate
+		}
+	}
+	if c.conn.tagFormat != 0 {
+		for _, t := range c.tagList {
+			conf.Tags = append(conf.Tags, t.K, t.V)
+		}
+	}
+	c.conn.wmu.Lock()
+	conf.Addr = c.conn.addr
+	conf.Network = c.conn.network
+	c.conn.wmu.Unlock()
+	return conf
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	testClient(t, func(c *Client) {
+		want := Config{
+			Addr:          ":8125",
+			Network:       "udp",
+			Rate:          0.5,
+			Rates:         map[Type]float32{TIMINGS: 0.1},
+			Prefix:        "app.",
+			Tags:          []string{"env", "prod"},
+			TagFormat:     Datadog,
+			MaxPacketSize: 1000,
+			DialTimeout:   time.Second,
+			WriteTimeout:  5 * time.Second,
+		}
+		got := c.Config()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Config() = %+v, want %+v", got, want)
+		}
+		got.Rates[TIMINGS] = 1
+		if c.rates[TIMINGS] != 0.1 {
+			t.Error("modifying the Config should not change the Client")
+		}
+
+		clone := c.Clone(Prefix("api"), Tags("region", "eu")).Config()
+		if clone.Prefix != "app.api." || !reflect.DeepEqual(clone.Tags, []string{"env", "prod", "region", "eu"}) {
+			t.Errorf("Config() = %+v, want the prefix and tags of the clone", clone)
+		}
+		c.Close()
+	},
+		SampleRate(0.5),
+		SampleRateFor(TIMINGS, 0.1),
+		Prefix("app"),
+		TagsFormat(Datadog),
+		Tags("env", "prod"),
+		MaxPacketSize(1000),
+		DialTimeout(time.Second),
+	)
+}
+
+func TestConfigTags(t *testing.T) {
+	testClient(t, func(c *Client) {
+		want := []string{"url", "http://x:80/a", "canary", "", "region", "eu"}
+		if got := c.WithTags("region", "eu").Config().Tags; !reflect.DeepEqual(got, want) {
+			t.Errorf("Config().Tags = %q, want %q", got, want)
+		}
+		if got := c.Clone(Tags("region", "eu")).Config().Tags; !reflect.DeepEqual(got, want) {
+			t.Errorf("Config().Tags = %q, want %q", got, want)
+		}
+		c.Close()
+	}, TagsFormat(Datadog), Tags("url", "http://x:80/a", "canary", ""))
+}
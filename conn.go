@@ -1,10 +1,14 @@
 package statsd
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"io"
 	"math/rand"
 	"net"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,82 +23,400 @@ type WriteCloserWithTimeout interface {
 
 type conn struct {
 	// Fields settable with options at Client's creation.
-	addr          string
-	errorHandler  func(error)
-	timeout       time.Duration
-	flushPeriod   time.Duration
-	maxPacketSize int
-	network       string
-	tagFormat     TagFormat
-	sendLastEndl  bool
+	addr            string
+	errorHandler    func(error)
+	onConnect       func(addr string)
+	onDisconnect    func(addr string, err error)
+	onAutoMute      func(addr string, muted bool)
+	logger          Logger
+	dialTimeout     time.Duration
+	writeTimeout    time.Duration
+	maxConnAge      time.Duration
+	muteAfter       int
+	probePeriod     time.Duration
+	clock           Clock
+	flushPeriod     time.Duration
+	flushJitter     float64
+	maxPacketSize   int
+	network         string
+	tagFormat       TagFormat
+	sortTags        bool
+	tagPolicy       TagPolicy
+	sendLastEndl    bool
+	lengthPrefix    bool
+	udpCheck        bool
+	record          bool
+	serializer      Serializer
+	framer          Framer
+	debugWriter     io.Writer
+	dryRun          io.Writer
+	given           WriteCloserWithTimeout
+	useConn         bool
+	redial          func() (WriteCloserWithTimeout, error)
+	spool           *spool
+	queueMax        int
+	bufferTTL       time.Duration
+	noDelay         bool
+	sendBufferSize  int
+	trafficClass    int
+	localAddr       string
+	fallbackNetwork string
+	fallbackAddr    string
+	failover        []string
+	children        []*conn
+	routes          map[Type]*conn
+	nchildren       int
+	srvConf         connConfig
+	pollPeriod      time.Duration
+	// done is closed when the connection is closed, to stop its goroutines.
+	done chan struct{}
+
+	pollMu sync.Mutex
+	// Fields guarded by pollMu.
+	pollers      []func()
+	counters     map[*Counter]struct{}
+	changeGauges map[*ChangeGauge]struct{}
+
+	agg *aggregator // guarded by mu
+
+	formats *formatCache // guarded by its own mutex
+
+	routeMu sync.RWMutex
+	// Fields guarded by routeMu.
+	shards []*conn
+	ring   *hashRing
 
 	mu sync.Mutex
 	// Fields guarded by the mutex.
-	closed    bool
+	buf   []byte
+	stats Stats
+	// typeBufs are the buffers of each type of metrics, if the TypeBuffers
+	// option is used.
+	typeBufs [][]byte
+	// packLines is true if the lines of a type buffer with the same name are
+	// packed into one multi-value line.
+	packLines bool
+	// lowBuf is the buffer of the metrics with the LowPriority.
+	lowBuf []byte
+	// bufKept and lowKept are the payloads put back at the front of buf and
+	// lowBuf after a failed write.
+	bufKept, lowKept keptPayload
+	// spares are the buffers swapped in when a payload is taken out of buf.
+	spares [][]byte
+	// bufSize is the initial capacity of the buffers.
+	bufSize int
+	// top counts the metrics of each series for the TopSeries option.
+	top  *topSeries
+	topK int
+	// holdLock makes flush keep mu locked while writing, e.g. while the
+	// aggregates are being appended to the buffer.
+	holdLock bool
+
+	// autoMuted is set atomically when the connection is muted by the
+	// AutoMute option.
+	autoMuted int32
+
+	// closed is set with both mu and wmu locked, so it can be read with
+	// either of them locked.
+	closed bool
+
+	// wmu serializes the writes to the connection. It is locked with mu
+	// locked, before mu is unlocked to write, so that the payloads are written
+	// in order. mu must not be locked with wmu locked.
+	wmu sync.Mutex
+	// Fields guarded by wmu.
 	w         WriteCloserWithTimeout
-	buf       []byte
-	rateCache map[float32]string
+	dialedAt  time.Time
+	frame     []byte
+	debugBuf  []byte
+	queue     []stashed
+	queueSize int
+	// wstats are the statistics of the writes.
+	wstats Stats
+	// ctx bounds the writes of the flush in progress, if set.
+	ctx context.Context
+
+	failoverIdx  int
+	failures     int
+	failedOverAt time.Time
+	// failedFlushes is the number of consecutive failed flushes.
+	failedFlushes int
+	lastFlush     time.Time
 }
 
 func newConn(conf connConfig, muted bool) (*conn, error) {
+	var srvErr error
+	if conf.SRV != "" && !muted {
+		var addrs []string
+		if addrs, srvErr = resolveSRV(conf.SRV); srvErr == nil {
+			conf.Shards = addrs
+		}
+	}
+	if len(conf.Shards) > 0 {
+		conf.Addr = conf.Shards[0]
+	}
 	c := &conn{
-		addr:          conf.Addr,
-		errorHandler:  conf.ErrorHandler,
-		timeout:       conf.Timeout,
-		flushPeriod:   conf.FlushPeriod,
-		maxPacketSize: conf.MaxPacketSize,
-		network:       conf.Network,
-		tagFormat:     conf.TagFormat,
+		addr:            conf.Addr,
+		errorHandler:    conf.ErrorHandler,
+		onConnect:       conf.OnConnect,
+		onDisconnect:    conf.OnDisconnect,
+		onAutoMute:      conf.OnAutoMute,
+		logger:          conf.Logger,
+		dialTimeout:     conf.DialTimeout,
+		writeTimeout:    conf.WriteTimeout,
+		maxConnAge:      conf.MaxConnAge,
+		muteAfter:       conf.MuteAfter,
+		probePeriod:     conf.ProbePeriod,
+		clock:           conf.Clock,
+		flushPeriod:     conf.FlushPeriod,
+		flushJitter:     conf.FlushJitter,
+		maxPacketSize:   conf.MaxPacketSize,
+		network:         conf.Network,
+		tagFormat:       conf.TagFormat,
+		sortTags:        conf.SortTags,
+		tagPolicy:       conf.TagPolicy,
+		serializer:      conf.Serializer,
+		udpCheck:        conf.UDPCheck,
+		debugWriter:     conf.DebugWriter,
+		dryRun:          conf.DryRun,
+		given:           conf.Conn,
+		useConn:         conf.Conn != nil || conf.SocketActivation,
+		redial:          conf.Redial,
+		pollPeriod:      conf.PollPeriod,
+		agg:             newAggregator(conf),
+		queueMax:        conf.QueueSize,
+		bufferTTL:       conf.BufferTTL,
+		noDelay:         conf.TCPNoDelay,
+		sendBufferSize:  conf.SendBufferSize,
+		trafficClass:    conf.TrafficClass,
+		localAddr:       conf.LocalAddr,
+		fallbackNetwork: conf.FallbackNetwork,
+		fallbackAddr:    conf.FallbackAddr,
+		done:            make(chan struct{}),
+		formats:         newFormatCache(),
+	}
+	if c.clock == nil {
+		c.clock = systemClock{}
+	}
+	if conf.TopSeries > 0 {
+		c.topK = conf.TopSeries
+		c.top = newTopSeries(conf.TopSeries, conf.TopSeriesWindow, c.clock.Now())
+	}
+	if len(conf.Failover) > 0 {
+		c.failover = append([]string{conf.Addr}, conf.Failover...)
+	}
+	if conf.SocketActivation {
+		name := conf.SocketName
+		c.redial = func() (WriteCloserWithTimeout, error) {
+			return dialActivated(name, c.addr)
+		}
+	}
+	if c.serializer == nil {
+		c.serializer = newSerializer(conf)
 	}
+	c.framer, _ = c.serializer.(Framer)
 
-	if c.network[:3] != "udp" {
+	if conf.LengthPrefix {
+		// Each payload is a frame, so it is handled as a datagram.
+		c.lengthPrefix = true
+	} else if !isDatagram(c.network) {
 		c.sendLastEndl = true
 	}
 
+	if conf.SpoolPath != "" && !muted {
+		var err error
+		if c.spool, err = openSpool(conf.SpoolPath, conf.SpoolSize); err != nil {
+			return nil, err
+		}
+	}
+
 	if muted {
+		if conf.Record {
+			c.record = true
+		}
 		return c, nil
 	}
 
 	var err error
 
-	err = c.dial()
-	c.handleError(err)
+	if !conf.LazyConnect {
+		err = c.dialRetry(conf.ConnectRetry)
+		c.handleError(err)
+	}
 
-	// To prevent a buffer overflow add some capacity to the buffer to allow for
-	// an additional metric.
-	c.buf = make([]byte, 0, c.maxPacketSize+200)
+	// By default, add some capacity to the buffer to allow for an additional
+	// metric without growing it.
+	c.bufSize = conf.InitialBufferSize
+	if c.bufSize == 0 {
+		c.bufSize = c.maxPacketSize + bufferSlack
+	}
+	c.buf = make([]byte, 0, c.bufSize)
+	if conf.TypeBuffers {
+		c.typeBufs = make([][]byte, CUSTOM+1)
+		// The DogStatsD multi-value lines have another format and the
+		// format of the custom Serializers is unknown.
+		c.packLines = conf.Serializer == nil && conf.TagFormat != Datadog
+	}
 
 	if c.flushPeriod > 0 {
-		go func() {
-			ticker := time.NewTicker(c.flushPeriod)
-			for _ = range ticker.C {
-				c.mu.Lock()
-				if c.closed {
-					ticker.Stop()
-					c.mu.Unlock()
-					return
+		go c.flushLoop()
+	}
+	if c.pollPeriod > 0 {
+		go c.pollLoop()
+	}
+
+	if len(conf.Shards) > 1 {
+		if serr := c.setShards(conf, conf.Shards); err == nil {
+			err = serr
+		}
+	}
+	if conf.SRV != "" {
+		if err == nil {
+			err = srvErr
+		}
+		c.srvConf = conf
+		go c.refreshSRVLoop()
+	}
+	if len(conf.Routes) > 0 {
+		byAddr := map[string]*conn{}
+		c.routes = make(map[Type]*conn, len(conf.Routes))
+		for _, t := range []Type{COUNT, GAUGE, TIMINGS, HISTOGRAM, SET} {
+			addr, ok := conf.Routes[t]
+			if !ok {
+				continue
+			}
+			if byAddr[addr] == nil {
+				child, cerr := c.newChildConn(conf, addr)
+				if child == nil {
+					return nil, cerr
 				}
-				c.flush(0)
-				c.mu.Unlock()
+				if err == nil {
+					err = cerr
+				}
+				byAddr[addr] = child
+				c.children = append(c.children, child)
 			}
-		}()
+			c.routes[t] = byAddr[addr]
+		}
 	}
 
 	return c, err
 }
 
+// flushLoop periodically flushes the buffer until the connection is closed.
+func (c *conn) flushLoop() {
+	for {
+		// The period changes with the jitter, so a new ticker is used each time.
+		ticker := c.clock.NewTicker(c.nextFlushPeriod())
+		select {
+		case <-c.done:
+			ticker.Stop()
+			return
+		case <-ticker.C():
+		}
+		ticker.Stop()
+		if c.pollPeriod == 0 {
+			c.poll()
+		}
+		c.sendCounters()
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return
+		}
+		c.flushAll(nil)
+		c.mu.Unlock()
+	}
+}
+
+// nextFlushPeriod returns the delay until the next periodic flush: the flush
+// period randomly shifted by up to flushJitter times the period.
+func (c *conn) nextFlushPeriod() time.Duration {
+	if c.flushJitter == 0 {
+		return c.flushPeriod
+	}
+	shift := (2*rand.Float64() - 1) * c.flushJitter * float64(c.flushPeriod)
+	return c.flushPeriod + time.Duration(shift)
+}
+
+// dialRetry dials, retrying with an exponential backoff until maxWait has
+// elapsed. It returns the last error if no attempt succeeded.
+func (c *conn) dialRetry(maxWait time.Duration) error {
+	if maxWait <= 0 {
+		return c.dial()
+	}
+	deadline := c.clock.Now().Add(maxWait)
+	backoff := minRetryBackoff
+	for {
+		err := c.dial()
+		if err == nil {
+			return nil
+		}
+		remaining := deadline.Sub(c.clock.Now())
+		if remaining <= 0 {
+			return err
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		sleep(c.clock, backoff)
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// dial connects to the StatsD daemon. If the connection fails and a fallback
+// network is set, the fallback network is dialed and used from then on.
 func (c *conn) dial() error {
+	err := c.dialNetwork()
+	if err == nil || c.fallbackNetwork == "" || c.network == c.fallbackNetwork {
+		return err
+	}
+	network, addr, sendLastEndl := c.network, c.addr, c.sendLastEndl
+	c.network = c.fallbackNetwork
+	if c.fallbackAddr != "" {
+		c.addr = c.fallbackAddr
+	}
+	c.sendLastEndl = !c.lengthPrefix && !isDatagram(c.network)
+	if ferr := c.dialNetwork(); ferr != nil {
+		c.network, c.addr, c.sendLastEndl = network, addr, sendLastEndl
+		return err
+	}
+	c.logf("%s %s failed (%v), falling back to %s %s", network, addr, err, c.network, c.addr)
+	return nil
+}
+
+func (c *conn) dialNetwork() error {
 	var err error
-	c.w, err = dialTimeout(c.network, c.addr, c.timeout)
+	if c.dryRun != nil {
+		c.w = writerConn{c.dryRun}
+		return nil
+	}
+	if c.useConn {
+		return c.dialGiven()
+	}
+	if c.network == "npipe" {
+		c.w, err = dialPipe(c.addr, c.dialTimeout)
+	} else if c.localAddr != "" {
+		c.w, err = dialFrom(c.network, c.localAddr, c.addr, c.dialTimeout)
+	} else {
+		c.w, err = dialTimeout(c.network, c.addr, c.dialTimeout)
+	}
 	if err != nil {
 		return err
 	}
+	if err = c.setSockopts(); err != nil {
+		_ = c.w.Close()
+		c.w = nil
+		return err
+	}
 	// When using UDP do a quick check to see if something is listening on the
 	// given port to return an error as soon as possible.
-	if c.network[:3] == "udp" {
+	if c.udpCheck && strings.HasPrefix(c.network, "udp") {
 		for i := 0; i < 2; i++ {
-			if c.timeout > 0 {
-				c.w.SetDeadline(time.Now().Add(c.timeout))
+			if c.writeTimeout > 0 {
+				c.w.SetWriteDeadline(time.Now().Add(c.writeTimeout))
 			}
 			_, err = c.w.Write(nil)
 			if err != nil {
@@ -104,160 +426,206 @@ func (c *conn) dial() error {
 			}
 		}
 	}
+	if c.maxConnAge > 0 {
+		c.dialedAt = c.clock.Now()
+	}
+	c.watch()
+	if c.onConnect != nil {
+		c.onConnect(c.addr)
+	}
 	return nil
 }
 
-func (c *conn) metric(prefix, bucket string, n interface{}, typ string, rate float32, tags string) {
-	c.mu.Lock()
-	l := len(c.buf)
-	c.appendBucket(prefix, bucket, tags)
-	c.appendNumber(n)
-	c.appendType(typ)
-	c.appendRate(rate)
-	c.closeMetric(tags)
-	c.flushIfBufferFull(l)
-	c.mu.Unlock()
+// errNoRedial is the error returned when the connection set by UseConn failed
+// and no Redial function is set.
+var errNoRedial = errors.New("statsd: the connection set by UseConn failed and there is no Redial function")
+
+// dialGiven connects with the connection set by UseConn the first time, and
+// then with the Redial function.
+func (c *conn) dialGiven() error {
+	var err error
+	if c.given != nil {
+		c.w, c.given = c.given, nil
+	} else if c.redial == nil {
+		return errNoRedial
+	} else if c.w, err = c.redial(); err != nil {
+		c.w = nil
+		return err
+	}
+	if c.maxConnAge > 0 {
+		c.dialedAt = c.clock.Now()
+	}
+	c.watch()
+	if c.onConnect != nil {
+		c.onConnect(c.addr)
+	}
+	return nil
 }
 
-func (c *conn) gauge(prefix, bucket string, value interface{}, tags string) {
+// errMaxConnAge is the error reported to OnDisconnect when a connection is
+// closed because it is older than MaxConnAge.
+var errMaxConnAge = errors.New("statsd: maximum connection age reached")
+
+// disconnect closes the connection after the error err. It does nothing if
+// the connection is not established.
+func (c *conn) disconnect(err error) {
+	if c.w == nil {
+		return
+	}
+	c.w.Close()
+	c.w = nil
+	if c.onDisconnect != nil {
+		c.onDisconnect(c.addr, err)
+	}
+}
+
+func (c *conn) send(m *Metric) {
+	if to := c.pick(m); to != c {
+		to.send(m)
+		return
+	}
 	c.mu.Lock()
-	l := len(c.buf)
-	// To set a gauge to a negative value we must first set it to 0.
-	// https://github.com/etsy/statsd/blob/master/docs/metric_types.md#gauges
-	if isNegative(value) {
-		c.appendBucket(prefix, bucket, tags)
-		c.appendGauge(0, tags)
-	}
-	c.appendBucket(prefix, bucket, tags)
-	c.appendGauge(value, tags)
-	c.flushIfBufferFull(l)
+	if c.top != nil && !c.closed {
+		c.top.add(m, c.clock.Now())
+	}
+	if c.closed {
+		c.stats.Dropped++
+		c.wmu.Lock()
+		c.handleError(ErrClosed)
+		c.wmu.Unlock()
+	} else if c.isAutoMuted() {
+		c.stats.Dropped++
+	} else if c.agg != nil && c.agg.add(m) {
+		c.stats.AggregatedSamples++
+	} else {
+		c.appendMetric(m)
+	}
 	c.mu.Unlock()
 }
 
-func (c *conn) appendGauge(value interface{}, tags string) {
-	c.appendNumber(value)
-	c.appendType(GAUGE_S)
-	c.closeMetric(tags)
-}
+// Errors returned by SendRaw.
+var (
+	errEmptyLine     = errors.New("statsd: empty line")
+	errMultipleLines = errors.New("statsd: the line contains a new line")
+	errLineTooLong   = errors.New("statsd: the line does not fit in a packet")
+)
 
-func (c *conn) unique(prefix, bucket string, value string, tags string) {
+// sendRaw appends the line to the buffer, flushing it if it is full.
+func (c *conn) sendRaw(line []byte) error {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.stats.Dropped++
+		return ErrClosed
+	}
+	if c.maxPacketSize > 0 && len(line)+1 > c.maxPacketSize && !c.record {
+		return errLineTooLong
+	}
 	l := len(c.buf)
-	c.appendBucket(prefix, bucket, tags)
-	c.appendString(value)
-	c.appendType(SET_S)
-	c.closeMetric(tags)
+	c.buf = append(c.buf, line...)
+	c.buf = append(c.buf, '\n')
 	c.flushIfBufferFull(l)
-	c.mu.Unlock()
+	return nil
 }
 
-func (c *conn) appendByte(b byte) {
-	c.buf = append(c.buf, b)
-}
-
-func (c *conn) appendString(s string) {
-	c.buf = append(c.buf, s...)
-}
-
-func (c *conn) appendNumber(v interface{}) {
-	switch n := v.(type) {
-	case int:
-		c.buf = strconv.AppendInt(c.buf, int64(n), 10)
-	case uint:
-		c.buf = strconv.AppendUint(c.buf, uint64(n), 10)
-	case int64:
-		c.buf = strconv.AppendInt(c.buf, n, 10)
-	case uint64:
-		c.buf = strconv.AppendUint(c.buf, n, 10)
-	case int32:
-		c.buf = strconv.AppendInt(c.buf, int64(n), 10)
-	case uint32:
-		c.buf = strconv.AppendUint(c.buf, uint64(n), 10)
-	case int16:
-		c.buf = strconv.AppendInt(c.buf, int64(n), 10)
-	case uint16:
-		c.buf = strconv.AppendUint(c.buf, uint64(n), 10)
-	case int8:
-		c.buf = strconv.AppendInt(c.buf, int64(n), 10)
-	case uint8:
-		c.buf = strconv.AppendUint(c.buf, uint64(n), 10)
-	case float64:
-		c.buf = strconv.AppendFloat(c.buf, n, 'f', -1, 64)
-	case float32:
-		c.buf = strconv.AppendFloat(c.buf, float64(n), 'f', -1, 32)
-	}
-}
-
-func isNegative(v interface{}) bool {
-	switch n := v.(type) {
-	case int:
-		return n < 0
-	case uint:
-		return n < 0
-	case int64:
-		return n < 0
-	case uint64:
-		return n < 0
-	case int32:
-		return n < 0
-	case uint32:
-		return n < 0
-	case int16:
-		return n < 0
-	case uint16:
-		return n < 0
-	case int8:
-		return n < 0
-	case uint8:
-		return n < 0
-	case float64:
-		return n < 0
-	case float32:
-		return n < 0
+// appendMetric appends the metric m to the buffer, flushing it if it is full.
+func (c *conn) appendMetric(m *Metric) {
+	if m.Priority == LowPriority && !c.record {
+		c.appendLow(m)
+		return
 	}
-	return false
+	if int(m.Type) < len(c.typeBufs) {
+		c.appendTyped(m)
+		return
+	}
+	l := len(c.buf)
+	c.buf = c.serializer.AppendMetric(c.buf, m)
+	c.flushIfBufferFull(l)
 }
 
-func (c *conn) appendBucket(prefix, bucket string, tags string) {
-	c.appendString(prefix)
-	c.appendString(bucket)
-	if c.tagFormat == InfluxDB {
-		c.appendString(tags)
+// appendTyped appends the metric m to the buffer of its type, packing it into
+// the previous line if they have the same name. If the buffer of the type is
+// full, its previous lines are moved to the buffer.
+func (c *conn) appendTyped(m *Metric) {
+	b := c.typeBufs[m.Type]
+	l := len(b)
+	b = c.serializer.AppendMetric(b, m)
+	if c.packLines && m.Timestamp.IsZero() {
+		if i, ok := packable(b, l); ok && len(b)-i-1 <= c.maxPacketSize {
+			// The line is appended to the previous one without its name.
+			c.typeBufs[m.Type] = b[:l-1+copy(b[l-1:], b[l+i:])]
+			return
+		}
+	}
+	if len(b) <= c.maxPacketSize || l == 0 {
+		c.typeBufs[m.Type] = b
+		return
 	}
-	c.appendByte(':')
+	lastSafeLen := len(c.buf)
+	c.buf = append(c.buf, b[:l]...)
+	c.typeBufs[m.Type] = b[:copy(b, b[l:])]
+	c.flushIfBufferFull(lastSafeLen)
 }
 
-func (c *conn) appendType(t string) {
-	c.appendString(t)
+// packable reports whether the line appended at l to the buffer b has the same
+// name as the previous line and can be packed into it, e.g. "b:1|ms\nb:2|ms\n"
+// into "b:1|ms:2|ms\n". i is the length of the name.
+func packable(b []byte, l int) (i int, ok bool) {
+	if l == 0 {
+		return 0, false
+	}
+	line := b[l:]
+	i = bytes.IndexByte(line, ':')
+	// The values and the other fields of the line must not contain ':' and
+	// the Serializer must have appended a single line, e.g. not a zero gauge
+	// before a negative one.
+	if i <= 0 || bytes.IndexByte(line[i+1:], ':') >= 0 || bytes.IndexByte(line, '\n') != len(line)-1 {
+		return 0, false
+	}
+	prev := b[bytes.LastIndexByte(b[:l-1], '\n')+1 : l-1]
+	return i, len(prev) > i && prev[i] == ':' && bytes.Equal(prev[:i], line[:i])
 }
 
-func (c *conn) appendRate(rate float32) {
-	if rate == 1 {
-		return
-	}
-	if c.rateCache == nil {
-		c.rateCache = make(map[float32]string)
+// flushTypeBuffers moves the lines of the buffers of the types to the buffer.
+func (c *conn) flushTypeBuffers() {
+	for t, b := range c.typeBufs {
+		if len(b) == 0 {
+			continue
+		}
+		l := len(c.buf)
+		c.buf = append(c.buf, b...)
+		c.typeBufs[t] = b[:0]
+		c.flushIfBufferFull(l)
 	}
+}
 
-	c.appendString("|@")
-	if s, ok := c.rateCache[rate]; ok {
-		c.appendString(s)
-	} else {
-		s = strconv.FormatFloat(float64(rate), 'f', -1, 32)
-		c.rateCache[rate] = s
-		c.appendString(s)
+// pending returns the lines buffered and not flushed yet.
+func (c *conn) pending() []byte {
+	if c.typeBufs == nil && len(c.lowBuf) == 0 {
+		return c.buf
 	}
+	p := append([]byte(nil), c.buf...)
+	for _, b := range c.typeBufs {
+		p = append(p, b...)
+	}
+	return append(p, c.lowBuf...)
 }
 
-func (c *conn) closeMetric(tags string) {
-	if c.tagFormat == Datadog {
-		c.appendString(tags)
+// flushAggregates writes the metrics aggregated during the current window to
+// the buffer.
+func (c *conn) flushAggregates() {
+	if c.agg != nil {
+		start := time.Now()
+		// The aggregates must not change while they are appended.
+		c.holdLock = true
+		c.agg.flush(c.appendMetric)
+		c.holdLock = false
+		c.stats.AggregationFlushDuration = time.Since(start)
 	}
-	c.appendByte('\n')
 }
 
 func (c *conn) flushIfBufferFull(lastSafeLen int) {
-	if len(c.buf) > c.maxPacketSize {
+	if len(c.buf) > c.maxPacketSize && !c.record {
 		c.flush(lastSafeLen)
 	}
 }
@@ -265,50 +633,514 @@ func (c *conn) flushIfBufferFull(lastSafeLen int) {
 // flush flushes the first n bytes of the buffer.
 // If n is 0, the whole buffer is flushed.
 func (c *conn) flush(n int) error {
-	if len(c.buf) == 0 {
+	return c.flushBuf(nil, &c.buf, n, HighPriority)
+}
+
+// flushAll appends the aggregates and the buffers of the types to the buffer
+// and flushes it and then the buffer of the low priority metrics, bounding the
+// writes by ctx if it is not nil.
+func (c *conn) flushAll(ctx context.Context) error {
+	c.flushAggregates()
+	c.flushTypeBuffers()
+	err := c.flushBuf(ctx, &c.buf, 0, HighPriority)
+	if lerr := c.flushBuf(ctx, &c.lowBuf, 0, LowPriority); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// flushBuf flushes the first n bytes of the buffer b, made of metrics of the
+// priority prio, bounding the writes by ctx if it is not nil. If n is 0, the
+// whole buffer is flushed.
+//
+// It must be called with mu locked. The payload is taken out of the buffer and
+// mu is unlocked while it is written, so that a slow write does not block the
+// goroutines sending metrics.
+func (c *conn) flushBuf(ctx context.Context, b *[]byte, n int, prio Priority) error {
+	if len(*b) == 0 {
 		return nil
 	}
 	if n == 0 {
-		n = len(c.buf)
+		n = len(*b)
+	}
+	kept := &c.bufKept
+	if b == &c.lowBuf {
+		kept = &c.lowKept
+	}
+	keptAt, keptLen := kept.t, kept.n
+	kept.n = 0
+	var expired []byte
+	if keptLen > 0 && c.bufferTTL > 0 && c.clock.Now().Sub(keptAt) > c.bufferTTL {
+		// The payload kept since an earlier flush is too old to be sent.
+		expired = c.takePayload(b, keptLen)
+		n -= keptLen
+		keptLen = 0
+	}
+	var p []byte
+	if n > 0 {
+		p = c.takePayload(b, n)
+	}
+	unlock := !c.holdLock
+	c.wmu.Lock()
+	if unlock {
+		c.mu.Unlock()
+	}
+	if expired != nil {
+		c.drop(expired)
 	}
+	var keep bool
+	var err error
+	if p != nil {
+		c.ctx = ctx
+		keep, err = c.writeBuffered(p, prio)
+		c.ctx = nil
+	}
+	c.wmu.Unlock()
+	if unlock {
+		c.mu.Lock()
+	}
+	if expired != nil {
+		c.putPayload(expired)
+	}
+	if p == nil {
+		return nil
+	}
+	if keep {
+		// The payload goes back to the front of the buffer, stamped with the
+		// time it was first kept for the BufferTTL.
+		if keptLen == 0 {
+			keptAt = c.clock.Now()
+		}
+		if kept.n > 0 && kept.t.Before(keptAt) {
+			// Another flush kept a payload in the meantime.
+			keptAt = kept.t
+		}
+		kept.t, kept.n = keptAt, len(p)+kept.n
+		p, *b = *b, append(p, *b...)
+	}
+	c.putPayload(p)
+	return err
+}
+
+// A keptPayload is the payload at the front of a buffer which was put back
+// after a failed write.
+type keptPayload struct {
+	// t is the time the payload was first kept.
+	t time.Time
+	// n is the length of the payload.
+	n int
+}
 
+// Maximum number of spare buffers of a connection.
+const maxSpares = 2
+
+// bufferSlack is the capacity added by default to the maximum packet size for
+// the buffers.
+const bufferSlack = 200
+
+// takePayload removes the first n bytes of the buffer b and returns them. The
+// buffer is swapped with a spare one, so that the payload is not copied.
+func (c *conn) takePayload(b *[]byte, n int) []byte {
+	var spare []byte
+	if i := len(c.spares) - 1; i >= 0 {
+		spare = c.spares[i]
+		c.spares[i] = nil
+		c.spares = c.spares[:i]
+	} else {
+		// The spare gets the capacity b grew to, so that it does not have
+		// to grow again.
+		size := cap(*b)
+		if size < c.bufSize {
+			size = c.bufSize
+		}
+		spare = make([]byte, 0, size)
+	}
+	p := (*b)[:n]
+	*b = append(spare, (*b)[n:]...)
+	return p
+}
+
+// putPayload gives back the buffer of a written payload to the spares.
+func (c *conn) putPayload(p []byte) {
+	if len(c.spares) < maxSpares {
+		c.spares = append(c.spares, p[:0])
+	}
+}
+
+// writeBuffered writes the payload p of priority prio taken out of a buffer.
+// If p must be sent again, e.g. because the connection failed or the write was
+// interrupted by the context of the flush, keep is true and p must be put back
+// in the buffer.
+func (c *conn) writeBuffered(p []byte, prio Priority) (keep bool, err error) {
+	if c.isAutoMuted() {
+		// The connection is probed instead.
+		c.stash(p, prio)
+		return false, nil
+	}
+	c.tryPrimary()
+	if c.w != nil && c.maxConnAge > 0 && c.clock.Now().Sub(c.dialedAt) >= c.maxConnAge {
+		c.logf("connection to %s %s reached its maximum age, reconnecting", c.network, c.addr)
+		c.disconnect(errMaxConnAge)
+	}
 	if c.w == nil {
 		if err := c.dial(); err != nil {
-			c.errorHandler(err)
-			return err
+			c.handleError(err)
+			c.failed()
+			if c.queueMax > 0 || c.spool != nil || prio == LowPriority || c.isAutoMuted() {
+				c.stash(p, prio)
+				return false, err
+			}
+			// The payload is kept for the next flush.
+			return true, err
 		}
+		c.logf("reconnected to %s %s", c.network, c.addr)
 	}
 
-	var err error
-	if c.timeout > 0 {
-		c.w.SetDeadline(time.Now().Add(c.timeout))
+	if c.debugWriter != nil {
+		c.writeDebug(p)
+	}
+
+	err = c.writePayload(p)
+	if err != nil && c.interrupted() != nil {
+		// The payload is kept for the next flush.
+		err = c.interrupted()
+		c.disconnect(err)
+		return true, err
 	}
-	if c.sendLastEndl {
-		// Don't trim the last \n, becouse persistent connection
-		_, err = c.w.Write(c.buf[:n])
+	if err != nil {
+		c.handleError(err)
+		c.disconnect(err)
+		c.stash(p, prio)
+		c.failed()
 	} else {
-		// Trim the last \n, StatsD does not like it.
-		_, err = c.w.Write(c.buf[:n-1])
+		c.succeeded()
+		c.lastFlush = c.clock.Now()
+		if len(c.queue) > 0 || (c.spool != nil && c.spool.size > 0) {
+			c.replay()
+		}
 	}
+	return false, err
+}
+
+// flushContext flushes the buffer, bounding the writes by ctx. The buffer is
+// kept if ctx is done before it is sent.
+func (c *conn) flushContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.flushAll(ctx)
+}
+
+// interrupted returns the error of the context of the flush in progress if it
+// is done or if its deadline has passed.
+func (c *conn) interrupted() error {
+	if c.ctx == nil {
+		return nil
+	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := c.ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// flushAndClose flushes the buffer and closes the connection, as done by
+// Client.Flush.
+func (c *conn) flushAndClose() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	err := c.flushAll(nil)
+	c.wmu.Lock()
 	if err != nil {
 		c.handleError(err)
-		c.w.Close()
-		c.w = nil
+	} else if c.w != nil && !c.useConn {
+		// c.w is nil if nothing was written with LazyConnect. The
+		// connections set by UseConn or returned by Redial are only closed
+		// by Close, as they cannot be dialed again.
+		err = c.w.Close()
+		c.handleError(err)
+	}
+	c.wmu.Unlock()
+	c.mu.Unlock()
+
+	return err
+}
+
+// close flushes the buffer and releases the resources of the connection. It
+// does nothing if the connection is already closed.
+func (c *conn) close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	// The connection is closed first, so that the metrics sent while the
+	// buffer is written are dropped instead of left in the buffer.
+	c.wmu.Lock()
+	c.closed = true
+	c.wmu.Unlock()
+	// Send the partial window, so short-lived programs don't lose it.
+	err := c.flushAll(nil)
+	c.wmu.Lock()
+	if err != nil {
+		c.handleError(err)
+	} else if c.w != nil {
+		err = c.w.Close()
+		c.handleError(err)
+		if c.onDisconnect != nil {
+			c.onDisconnect(c.addr, nil)
+		}
 	}
-	if n < len(c.buf) {
-		copy(c.buf, c.buf[n:])
+	if c.spool != nil {
+		c.handleError(c.spool.close())
+		c.spool = nil
 	}
-	c.buf = c.buf[:len(c.buf)-n]
+	c.wmu.Unlock()
+	close(c.done)
+	c.mu.Unlock()
+
+	c.pollMu.Lock()
+	c.counters = nil
+	c.changeGauges = nil
+	c.pollMu.Unlock()
 
 	return err
 }
 
+// writePayload writes the payload p, made of newline-terminated lines, to the
+// connection.
+func (c *conn) writePayload(p []byte) error {
+	var deadline time.Time
+	if c.writeTimeout > 0 {
+		deadline = time.Now().Add(c.writeTimeout)
+	}
+	if c.ctx != nil {
+		if d, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	// The deadline is always set, to clear the one of an interrupted flush. It
+	// is set before starting the interruption, which would be overridden
+	// otherwise if ctx is already done.
+	c.w.SetWriteDeadline(deadline)
+	if c.ctx != nil && c.ctx.Done() != nil {
+		defer interrupt(c.ctx, c.w)()
+	}
+	if c.sendLastEndl || c.dryRun != nil {
+		// Don't trim the last \n, becouse persistent connection, or so that
+		// the payloads of a dry run are not glued together.
+		return c.write(p)
+	}
+	// Trim the last \n, StatsD does not like it.
+	return c.write(p[:len(p)-1])
+}
+
+// interrupt makes the pending writes to w fail as soon as ctx is done. The
+// returned function must be called once the writes are over: it returns once
+// w cannot be interrupted anymore.
+func interrupt(ctx context.Context, w WriteCloserWithTimeout) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			w.SetWriteDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// stash saves the payload p of priority prio which could not be sent to the
+// overflow queue or else to the spool. The low priority payloads are evicted
+// from the queue to make room for the other ones and are never spooled. The
+// payload is dropped if it cannot be saved.
+func (c *conn) stash(p []byte, prio Priority) {
+	if c.queueMax > 0 && (c.queueSize+len(p) <= c.queueMax || prio != LowPriority && c.evictLow(len(p))) {
+		c.queue = append(c.queue, stashed{t: c.clock.Now(), payload: append([]byte(nil), p...), prio: prio})
+		c.queueSize += len(p)
+		if c.queueSize > c.wstats.QueueHighWater {
+			c.wstats.QueueHighWater = c.queueSize
+		}
+		return
+	}
+	if c.spool != nil && prio != LowPriority {
+		err := c.spool.append(c.clock.Now(), p)
+		if err == nil {
+			return
+		}
+		c.handleError(err)
+	}
+	c.drop(p)
+}
+
+// evictLow drops the low priority payloads of the overflow queue, oldest
+// first, until n more bytes fit in it. It returns whether they fit.
+func (c *conn) evictLow(n int) bool {
+	q := c.queue[:0]
+	for _, s := range c.queue {
+		if c.queueSize+n > c.queueMax && s.prio == LowPriority {
+			c.queueSize -= len(s.payload)
+			c.drop(s.payload)
+			continue
+		}
+		q = append(q, s)
+	}
+	for i := len(q); i < len(c.queue); i++ {
+		c.queue[i] = stashed{}
+	}
+	c.queue = q
+	return c.queueSize+n <= c.queueMax
+}
+
+// drop drops the payload p.
+func (c *conn) drop(p []byte) {
+	n := bytesCount(p, '\n')
+	c.wstats.Dropped += int64(n)
+	c.logf("dropped %d metrics which could not be sent to %s", n, c.addr)
+}
+
+// replay sends the payloads of the overflow queue and then of the spool. The
+// payloads which could not be sent are kept and the expired ones are dropped.
+func (c *conn) replay() {
+	for len(c.queue) > 0 {
+		r := c.queue[0]
+		if c.expired(r) {
+			c.drop(r.payload)
+		} else if err := c.writePayload(r.payload); err != nil {
+			c.handleError(err)
+			c.disconnect(err)
+			return
+		}
+		c.queue[0] = stashed{}
+		c.queue = c.queue[1:]
+		c.queueSize -= len(r.payload)
+	}
+	if c.spool != nil && c.spool.size > 0 {
+		c.replaySpool()
+	}
+}
+
+// replaySpool sends the spooled payloads. The payloads which could not be sent
+// are kept in the spool and the expired ones are dropped.
+func (c *conn) replaySpool() {
+	records, err := c.spool.read()
+	if err != nil {
+		c.handleError(err)
+		return
+	}
+	for i, r := range records {
+		if c.expired(r) {
+			c.drop(r.payload)
+			continue
+		}
+		if err := c.writePayload(r.payload); err != nil {
+			c.handleError(err)
+			c.disconnect(err)
+			c.handleError(c.spool.reset(records[i:]))
+			return
+		}
+	}
+	c.handleError(c.spool.reset(nil))
+}
+
+// expired returns whether the stashed payload r is older than the BufferTTL.
+func (c *conn) expired(r stashed) bool {
+	return c.bufferTTL > 0 && c.clock.Now().Sub(r.t) > c.bufferTTL
+}
+
+// writeDebug writes the lines of the payload p to the debug writer, each one
+// preceded by the current time.
+func (c *conn) writeDebug(p []byte) {
+	ts := c.clock.Now().Format(debugTimeFormat)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n') + 1
+		if i == 0 {
+			i = len(p)
+		}
+		c.debugBuf = append(c.debugBuf[:0], ts...)
+		c.debugBuf = append(c.debugBuf, ' ')
+		c.debugBuf = append(c.debugBuf, bytes.TrimSuffix(p[:i], []byte{'\n'})...)
+		c.debugBuf = append(c.debugBuf, '\n')
+		_, _ = c.debugWriter.Write(c.debugBuf)
+		p = p[i:]
+	}
+}
+
+// write writes the payload p to the connection. If the connection is length
+// prefixed, p is preceded by its length as a 4-byte little-endian integer, as
+// expected by the Datadog agent on unix stream sockets.
+func (c *conn) write(p []byte) error {
+	if c.framer != nil {
+		c.frame = c.framer.Frame(c.frame[:0], p)
+		p = c.frame
+	} else if c.lengthPrefix {
+		c.frame = append(c.frame[:0], 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(c.frame, uint32(len(p)))
+		c.frame = append(c.frame, p...)
+		p = c.frame
+	}
+	_, err := c.w.Write(p)
+	if err == nil {
+		c.wstats.PacketsSent++
+		c.wstats.BytesSent += int64(len(p))
+	}
+	return err
+}
+
+// writerConn is a connection writing to an io.Writer, used by dry runs.
+type writerConn struct {
+	io.Writer
+}
+
+func (writerConn) Close() error                     { return nil }
+func (writerConn) SetDeadline(time.Time) error      { return nil }
+func (writerConn) SetReadDeadline(time.Time) error  { return nil }
+func (writerConn) SetWriteDeadline(time.Time) error { return nil }
+
+// isDatagram returns whether the network is message oriented, in which case
+// every write is sent as a single packet.
+func isDatagram(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram", "unixpacket":
+		return true
+	}
+	return false
+}
+
 func (c *conn) handleError(err error) {
-	if err != nil && c.errorHandler != nil {
+	if err == nil {
+		return
+	}
+	c.wstats.Errors++
+	if c.errorHandler != nil {
 		c.errorHandler(err)
 	}
 }
 
+// debugTimeFormat is the format of the timestamps written by writeDebug.
+const debugTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Bounds of the backoff between two connection attempts of dialRetry.
+const (
+	minRetryBackoff = 50 * time.Millisecond
+	maxRetryBackoff = time.Second
+)
+
 // Stubbed out for testing.
 var (
 	dialTimeout = net.DialTimeout
@@ -0,0 +1,60 @@
+package statsd
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// containerIDEnv is the environment variable read by DetectContainerID
+	// before falling back to the cgroup file.
+	containerIDEnv = "DD_CONTAINER_ID"
+	// entityIDEnv is the environment variable read by EntityIDTag. It is
+	// usually set to the pod UID with the Kubernetes downward API.
+	entityIDEnv = "DD_ENTITY_ID"
+	// entityIDTag is the tag used by the Datadog agent for origin detection.
+	entityIDTag = "dd.internal.entity_id"
+)
+
+var (
+	// Stubbed out for testing.
+	cgroupPath = "/proc/self/cgroup"
+
+	// A container ID is either a 64 hexadecimal characters ID (Docker,
+	// containerd) or an UUID (ECS, Kubernetes pods).
+	containerIDRegexp = regexp.MustCompile(`([0-9a-f]{64})|([0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12})`)
+)
+
+// detectContainerID returns the ID of the container the process runs in, or an
+// empty string if it cannot be found.
+func detectContainerID() string {
+	if id := os.Getenv(containerIDEnv); id != "" {
+		return id
+	}
+	f, err := os.Open(cgroupPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return parseContainerID(f)
+}
+
+// parseContainerID extracts the container ID from the content of a cgroup
+// file, whose lines have the "hierarchy-ID:controller-list:cgroup-path" form.
+func parseContainerID(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := strings.TrimSuffix(parts[2], ".scope")
+		if id := containerIDRegexp.FindString(path[strings.LastIndexByte(path, '/')+1:]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
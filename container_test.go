@@ -0,0 +1,92 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseContainerID(t *testing.T) {
+	tests := []struct {
+		cgroup string
+		want   string
+	}{
+		{
+			cgroup: "12:pids:/docker/3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860\n" +
+				"11:hugetlb:/docker/3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860\n",
+			want: "3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860",
+		},
+		{
+			cgroup: "0::/system.slice/docker-3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860.scope\n",
+			want:   "3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860",
+		},
+		{
+			cgroup: "1:name=systemd:/ecs/34dc0b5e626f2c5c4c5170e34b10e765-1234567890\n" +
+				"2:cpu:/ecs/task/34dc0b5e-626f-2c5c-4c51-70e34b10e765\n",
+			want: "34dc0b5e-626f-2c5c-4c51-70e34b10e765",
+		},
+		{
+			cgroup: "0::/user.slice/user-1000.slice/session-1.scope\n",
+			want:   "",
+		},
+	}
+	for _, test := range tests {
+		if got := parseContainerID(strings.NewReader(test.cgroup)); got != test.want {
+			t.Errorf("parseContainerID(%q) = %q, want %q", test.cgroup, got, test.want)
+		}
+	}
+}
+
+func TestContainerID(t *testing.T) {
+	testOutput(t, "test_key:1|c|#tag1:value1|c:abc", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"), ContainerID("abc"))
+}
+
+func TestDetectContainerID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cgroupPath = filepath.Join(dir, "cgroup")
+	defer func() { cgroupPath = "/proc/self/cgroup" }()
+	id := "3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860"
+	if err := ioutil.WriteFile(cgroupPath, []byte("1:cpu:/docker/"+id+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Unsetenv(containerIDEnv)
+	testOutput(t, "test_key:1|c|c:"+id, func(c *Client) {
+		c.Increment(testKey)
+	}, DetectContainerID(true))
+
+	os.Setenv(containerIDEnv, "env-id")
+	defer os.Unsetenv(containerIDEnv)
+	testOutput(t, "test_key:1|c|c:env-id", func(c *Client) {
+		c.Increment(testKey)
+	}, DetectContainerID(true))
+	testOutput(t, "test_key:1|c|c:explicit", func(c *Client) {
+		c.Increment(testKey)
+	}, DetectContainerID(true), ContainerID("explicit"))
+}
+
+func TestEntityIDTag(t *testing.T) {
+	os.Setenv(entityIDEnv, "pod-uid")
+	defer os.Unsetenv(entityIDEnv)
+
+	testOutput(t, "test_key:1|c|#tag1:value1,dd.internal.entity_id:pod-uid", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"), EntityIDTag(true))
+	testOutput(t, "test_key:1|c|#tag1:value1", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"))
+
+	os.Unsetenv(entityIDEnv)
+	testOutput(t, "test_key:1|c|#tag1:value1", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"), EntityIDTag(true))
+}
@@ -0,0 +1,72 @@
+package statsd
+
+import "context"
+
+// tagsKey is the context key of the tags added by ContextWithTags.
+type tagsKey struct{}
+
+// ContextWithTags returns a copy of ctx carrying the given tags, as key/value
+// pairs, in addition to the tags already carried by ctx. The tags are sent
+// with the metrics sent by the *Context methods of the Client, e.g. to stamp
+// the tenant or route tags once in an HTTP middleware.
+//
+// If the number of tags is not even, ContextWithTags panics.
+func ContextWithTags(ctx context.Context, tags ...string) context.Context {
+	if len(tags)%2 != 0 {
+		panic("statsd: ContextWithTags only accepts an even number of arguments")
+	}
+	parent := TagsFromContext(ctx)
+	merged := make([]string, 0, len(parent)+len(tags))
+	merged = append(merged, parent...)
+	merged = append(merged, tags...)
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// TagsFromContext returns the tags carried by ctx, as key/value pairs.
+func TagsFromContext(ctx context.Context) []string {
+	tags, _ := ctx.Value(tagsKey{}).([]string)
+	return tags
+}
+
+// sendContext sends the metric with the tags carried by ctx.
+func (c *Client) sendContext(ctx context.Context, t Type, bucket string, value interface{}, opts []MetricOption) {
+	if tags := TagsFromContext(ctx); len(tags) > 0 {
+		c = c.WithTags(tags...)
+	}
+	c.send(t, bucket, value, opts)
+}
+
+// CountContext is like Count with the tags carried by ctx.
+func (c *Client) CountContext(ctx context.Context, bucket string, n interface{}, opts ...MetricOption) {
+	c.sendContext(ctx, COUNT, bucket, n, opts)
+}
+
+// IncrementContext is like Increment with the tags carried by ctx.
+func (c *Client) IncrementContext(ctx context.Context, bucket string, opts ...MetricOption) {
+	c.sendContext(ctx, COUNT, bucket, 1, opts)
+}
+
+// DecrementContext is like Decrement with the tags carried by ctx.
+func (c *Client) DecrementContext(ctx context.Context, bucket string, opts ...MetricOption) {
+	c.sendContext(ctx, COUNT, bucket, -1, opts)
+}
+
+// GaugeContext is like Gauge with the tags carried by ctx.
+func (c *Client) GaugeContext(ctx context.Context, bucket string, value interface{}, opts ...MetricOption) {
+	c.sendContext(ctx, GAUGE, bucket, value, opts)
+}
+
+// TimingContext is like Timing with the tags carried by ctx.
+func (c *Client) TimingContext(ctx context.Context, bucket string, value interface{}, opts ...MetricOption) {
+	c.sendContext(ctx, TIMINGS, bucket, c.timingValue(value), opts)
+}
+
+// HistogramContext is like Histogram with the tags carried by ctx.
+func (c *Client) HistogramContext(ctx context.Context, bucket string, value interface{}, opts ...MetricOption) {
+	c.sendContext(ctx, HISTOGRAM, bucket, value, opts)
+}
+
+// UniqueContext is like Unique with the tags carried by ctx.
+func (c *Client) UniqueContext(ctx context.Context, bucket string, value string, opts ...MetricOption) {
+	c.sendContext(ctx, SET, bucket, value, opts)
+}
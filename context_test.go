@@ -0,0 +1,21 @@
+package statsd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextWithTags(t *testing.T) {
+	ctx := ContextWithTags(context.Background(), "tenant", "acme")
+	ctx = ContextWithTags(ctx, "route", "/users", "tag1", "value2")
+
+	testOutput(t, "test_key:1|c|#tag1:value2,tenant:acme,route:/users\n"+
+		"test_key:2|g|#tag1:value2,tenant:acme,route:/users\n"+
+		"test_key:3|ms|#tag1:value2,tenant:acme,route:/users\n"+
+		"test_key:4|c|#tag1:value1", func(c *Client) {
+		c.IncrementContext(ctx, testKey)
+		c.GaugeContext(ctx, testKey, 2)
+		c.TimingContext(ctx, testKey, 3)
+		c.CountContext(context.Background(), testKey, 4)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"))
+}
@@ -0,0 +1,68 @@
+package statsd
+
+import "sync/atomic"
+
+// A Counter accumulates a count in memory and sends it with Count at every
+// flush, e.g. for per-packet counters on paths too hot to send a metric per
+// call. It is safe for concurrent use.
+type Counter struct {
+	n      int64 // accessed atomically, first for 64-bit alignment
+	c      *Client
+	bucket string
+	opts   []MetricOption
+}
+
+// NewCounter returns a Counter of the bucket. The accumulated count is sent
+// at every flush period, by Flush and by Close, and then reset. Nothing is
+// sent if it is zero.
+//
+// The Counter is never sent if the Client is muted or if the FlushPeriod is
+// zero and the Client is not flushed. The Client keeps a reference to the
+// Counter until Stop is called, so short-lived Counters must be stopped.
+func (c *Client) NewCounter(bucket string, opts ...MetricOption) *Counter {
+	ctr := &Counter{c: c, bucket: bucket, opts: opts}
+	if !c.muted {
+		c.conn.pollMu.Lock()
+		if c.conn.counters == nil {
+			c.conn.counters = make(map[*Counter]struct{})
+		}
+		c.conn.counters[ctr] = struct{}{}
+		c.conn.pollMu.Unlock()
+	}
+	return ctr
+}
+
+// Stop sends the accumulated count and stops sending the Counter, so that it
+// can be garbage collected. The counts added afterward are not sent.
+func (ctr *Counter) Stop() {
+	ctr.c.conn.pollMu.Lock()
+	delete(ctr.c.conn.counters, ctr)
+	ctr.c.conn.pollMu.Unlock()
+	ctr.send()
+}
+
+// Add adds n to the count.
+func (ctr *Counter) Add(n int64) {
+	atomic.AddInt64(&ctr.n, n)
+}
+
+// send sends and resets the count.
+func (ctr *Counter) send() {
+	if n := atomic.SwapInt64(&ctr.n, 0); n != 0 {
+		ctr.c.Count(ctr.bucket, n, ctr.opts...)
+	}
+}
+
+// sendCounters sends the counts of the Counters. It must not be called with
+// mu locked.
+func (c *conn) sendCounters() {
+	c.pollMu.Lock()
+	counters := make([]*Counter, 0, len(c.counters))
+	for ctr := range c.counters {
+		counters = append(counters, ctr)
+	}
+	c.pollMu.Unlock()
+	for _, ctr := range counters {
+		ctr.send()
+	}
+}
@@ -0,0 +1,47 @@
+package statsd
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	testOutput(t, "test_key:100|ctest_key:2|c", func(c *Client) {
+		ctr := c.NewCounter(testKey)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					ctr.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if err := c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// Nothing is sent while the count is zero.
+		c.conn.sendCounters()
+		ctr.Add(2)
+	})
+}
+
+func TestCounterMuted(t *testing.T) {
+	testOutput(t, "", func(c *Client) {
+		c.Clone(Mute(true)).NewCounter(testKey).Add(1)
+	})
+}
+
+func TestCounterStop(t *testing.T) {
+	testOutput(t, "test_key:1|c", func(c *Client) {
+		ctr := c.NewCounter(testKey)
+		ctr.Add(1)
+		ctr.Stop()
+		if n := len(c.conn.counters); n != 0 {
+			t.Errorf("%d Counters registered after Stop, want 0", n)
+		}
+		ctr.Add(2)
+	})
+}
@@ -0,0 +1,88 @@
+package statsd
+
+import "sync/atomic"
+
+// defaultClient holds the *Client used by the package-level functions.
+var defaultClient atomic.Value
+
+// SetDefault sets the Client used by the package-level functions (Count,
+// Gauge, SendTiming, etc.). Passing nil unsets it.
+//
+// It is safe to call SetDefault concurrently with the package-level functions.
+func SetDefault(c *Client) {
+	defaultClient.Store(c)
+}
+
+// Default returns the Client used by the package-level functions or nil if
+// none has been set.
+func Default() *Client {
+	c, _ := defaultClient.Load().(*Client)
+	return c
+}
+
+// Count adds n to bucket using the default Client. It does nothing if no
+// default Client has been set.
+func Count(bucket string, n interface{}, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Count(bucket, n, opts...)
+	}
+}
+
+// Increment increments the given bucket using the default Client. It does
+// nothing if no default Client has been set.
+func Increment(bucket string, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Increment(bucket, opts...)
+	}
+}
+
+// Decrement decrements the given bucket using the default Client. It does
+// nothing if no default Client has been set.
+func Decrement(bucket string, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Decrement(bucket, opts...)
+	}
+}
+
+// Gauge records an absolute value for the given bucket using the default
+// Client. It does nothing if no default Client has been set.
+func Gauge(bucket string, value interface{}, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Gauge(bucket, value, opts...)
+	}
+}
+
+// SendTiming sends a timing value to a bucket using the default Client. It
+// does nothing if no default Client has been set.
+//
+// It is the package-level equivalent of Client.Timing.
+func SendTiming(bucket string, value interface{}, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Timing(bucket, value, opts...)
+	}
+}
+
+// Histogram sends an histogram value to a bucket using the default Client. It
+// does nothing if no default Client has been set.
+func Histogram(bucket string, value interface{}, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Histogram(bucket, value, opts...)
+	}
+}
+
+// Unique sends the given value to a set bucket using the default Client. It
+// does nothing if no default Client has been set.
+func Unique(bucket string, value string, opts ...MetricOption) {
+	if c := Default(); c != nil {
+		c.Unique(bucket, value, opts...)
+	}
+}
+
+// NewTiming creates a new Timing bound to the default Client. If no default
+// Client has been set, sending the Timing does nothing.
+func NewTiming() Timing {
+	if c := Default(); c != nil {
+		return c.NewTiming()
+	}
+	return Timing{start: now()}
+}
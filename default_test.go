@@ -0,0 +1,36 @@
+package statsd
+
+import "testing"
+
+func TestDefaultUnset(t *testing.T) {
+	SetDefault(nil)
+	if Default() != nil {
+		t.Fatal("Default() should be nil")
+	}
+	Count(testKey, 1)
+	Increment(testKey)
+	Decrement(testKey)
+	Gauge(testKey, 1)
+	SendTiming(testKey, 1)
+	Histogram(testKey, 1)
+	Unique(testKey, "1")
+	NewTiming().Send(testKey)
+}
+
+func TestDefault(t *testing.T) {
+	testOutput(t, "test_key:5|c\ntest_key:1|c\ntest_key:-1|c\ntest_key:2|g\ntest_key:3|ms\ntest_key:4|h\ntest_key:foo|s", func(c *Client) {
+		SetDefault(c)
+		defer SetDefault(nil)
+
+		if Default() != c {
+			t.Fatal("Default() should return the Client set with SetDefault")
+		}
+		Count(testKey, 5)
+		Increment(testKey)
+		Decrement(testKey)
+		Gauge(testKey, 2)
+		SendTiming(testKey, 3)
+		Histogram(testKey, 4)
+		Unique(testKey, "foo")
+	})
+}
@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DSN configures the Client with a single connection string, which is easier
+// to pass through flags and configuration systems than many options:
+//
+//	udp://statsd.local:8125?prefix=app.&rate=0.5&tag_format=datadog
+//	unix:///var/run/datadog/dsd.socket?length_prefix=true
+//
+// The scheme is the network and the host (or the path for unix networks) is
+// the address. The following query parameters are supported: prefix, rate,
+// tag_format ("influxdb" or "datadog"), tags (e.g. "env:prod,team:core"),
+// max_packet_size, flush_period, timeout, dial_timeout, write_timeout (Go
+// durations, e.g. "100ms") and length_prefix.
+//
+// If the DSN is malformed, New returns an error. The connection settings are
+// ignored in Client.Clone().
+func DSN(dsn string) Option {
+	opts, err := parseDSN(dsn)
+	return Option(func(c *config) {
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		for _, o := range opts {
+			o(c)
+		}
+	})
+}
+
+func parseDSN(dsn string) ([]Option, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, &ConfigError{Option: "DSN", Value: dsn, Reason: err.Error()}
+	}
+	if u.Scheme == "" || u.Opaque != "" {
+		return nil, &ConfigError{Option: "DSN", Value: dsn, Reason: "want network://address"}
+	}
+	opts := []Option{Network(u.Scheme)}
+	switch u.Scheme {
+	case "unix", "unixgram", "unixpacket", "npipe":
+		opts = append(opts, Address(u.Host+u.Path))
+	default:
+		opts = append(opts, Address(u.Host))
+	}
+
+	for k, vs := range u.Query() {
+		v := vs[len(vs)-1]
+		var opt Option
+		switch k {
+		case "prefix":
+			opt = ReplacePrefix(v)
+		case "rate":
+			var rate float64
+			if rate, err = strconv.ParseFloat(v, 32); err == nil {
+				opt = SampleRate(float32(rate))
+			}
+		case "tag_format":
+			var tf TagFormat
+			if tf, err = parseTagFormat(v); err == nil {
+				opt = TagsFormat(tf)
+			}
+		case "tags":
+			var tags []string
+			if tags, err = parseTagList(v); err == nil {
+				opt = Tags(tags...)
+			}
+		case "max_packet_size":
+			var n int
+			if n, err = strconv.Atoi(v); err == nil {
+				opt = MaxPacketSize(n)
+			}
+		case "flush_period":
+			var d time.Duration
+			if d, err = time.ParseDuration(v); err == nil {
+				opt = FlushPeriod(d)
+			}
+		case "timeout":
+			var d time.Duration
+			if d, err = time.ParseDuration(v); err == nil {
+				opt = Timeout(d)
+			}
+		case "dial_timeout":
+			var d time.Duration
+			if d, err = time.ParseDuration(v); err == nil {
+				opt = DialTimeout(d)
+			}
+		case "write_timeout":
+			var d time.Duration
+			if d, err = time.ParseDuration(v); err == nil {
+				opt = WriteTimeout(d)
+			}
+		case "length_prefix":
+			var b bool
+			if b, err = strconv.ParseBool(v); err == nil {
+				opt = LengthPrefix(b)
+			}
+		default:
+			err = errors.New("unknown parameter")
+		}
+		if err != nil {
+			return nil, &ConfigError{Option: "DSN parameter " + k, Value: v, Reason: err.Error()}
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
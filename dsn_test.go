@@ -0,0 +1,64 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDSN(t *testing.T) {
+	var network, addr string
+	dialTimeout = func(n, a string, _ time.Duration) (net.Conn, error) {
+		network, addr = n, a
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(DSN("tcp://statsd.local:8125?prefix=app.&rate=0.5&tag_format=datadog&tags=env:prod&flush_period=0s&max_packet_size=512&timeout=1s"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if network != "tcp" || addr != "statsd.local:8125" {
+		t.Errorf("dialed %s://%s, want tcp://statsd.local:8125", network, addr)
+	}
+	if c.conn.maxPacketSize != 512 || c.conn.flushPeriod != 0 || c.conn.dialTimeout != time.Second || c.conn.writeTimeout != time.Second {
+		t.Errorf("invalid connection settings: %d, %v, %v, %v", c.conn.maxPacketSize, c.conn.flushPeriod, c.conn.dialTimeout, c.conn.writeTimeout)
+	}
+	randFloat = func() float32 { return 0.1 }
+	c.Increment(testKey)
+	c.Close()
+	if got, want := getOutput(c), "app.test_key:1|c|@0.5|#env:prod\n"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+
+	c, err = New(DSN("unix:///var/run/datadog/dsd.socket?length_prefix=true&dial_timeout=10s&write_timeout=200ms"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if network != "unix" || addr != "/var/run/datadog/dsd.socket" || !c.conn.lengthPrefix {
+		t.Errorf("dialed %s://%s, want unix:///var/run/datadog/dsd.socket with length prefix", network, addr)
+	}
+	if c.conn.dialTimeout != 10*time.Second || c.conn.writeTimeout != 200*time.Millisecond {
+		t.Errorf("invalid timeouts: %v, %v", c.conn.dialTimeout, c.conn.writeTimeout)
+	}
+}
+
+func TestDSNErrors(t *testing.T) {
+	for _, dsn := range []string{
+		"statsd.local:8125",
+		"udp://statsd.local:8125?rate=half",
+		"udp://statsd.local:8125?tag_format=graphite",
+		"udp://statsd.local:8125?tags=:env",
+		"udp://statsd.local:8125?max_packet_size=big",
+		"udp://statsd.local:8125?flush_period=1",
+		"udp://statsd.local:8125?timeout=1",
+		"udp://statsd.local:8125?length_prefix=maybe",
+		"udp://statsd.local:8125?unknown=1",
+		"udp://%zz",
+	} {
+		c, err := New(DSN(dsn))
+		if err == nil || c != nil {
+			t.Errorf("New(DSN(%q)) = %v, %v, want an error", dsn, c, err)
+		}
+	}
+}
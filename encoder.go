@@ -0,0 +1,118 @@
+package statsd
+
+// An Encoder appends metrics in the wire format to byte slices without a
+// connection, e.g. for load generators or relays. Its output is the same as
+// the one of a Client configured with the same options, except that the
+// metrics are not sampled, only marked with their sample rate, and that the
+// MetricHooks are not run. The connection options are ignored.
+//
+// An Encoder is safe for concurrent use unless its Serializer is not.
+type Encoder struct {
+	serializer Serializer
+	tagFormat  TagFormat
+	rate       float32
+	rates      map[Type]float32
+	prefix     string
+	tags       string
+}
+
+// NewEncoder returns a new Encoder configured by the options. If the options
+// are invalid, NewEncoder returns an error.
+func NewEncoder(opts ...Option) (*Encoder, error) {
+	conf, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &conn{
+		tagFormat: conf.Conn.TagFormat,
+		sortTags:  conf.Conn.SortTags,
+		tagPolicy: conf.Conn.TagPolicy,
+	}
+	e := &Encoder{
+		serializer: conf.Conn.Serializer,
+		tagFormat:  conf.Conn.TagFormat,
+		rate:       conf.Client.Rate,
+		rates:      conf.Client.Rates,
+		prefix:     conf.Client.Prefix,
+		tags:       r.renderTags(conf.Client.Tags),
+	}
+	if e.serializer == nil {
+		e.serializer = newSerializer(conf.Conn)
+	}
+	return e, nil
+}
+
+// AppendCount appends the count n of bucket to buf and returns the extended
+// buffer.
+func (e *Encoder) AppendCount(buf []byte, bucket string, n interface{}, opts ...MetricOption) []byte {
+	return e.append(buf, COUNT, bucket, n, opts)
+}
+
+// AppendGauge appends the gauge value of bucket to buf and returns the
+// extended buffer.
+func (e *Encoder) AppendGauge(buf []byte, bucket string, value interface{}, opts ...MetricOption) []byte {
+	return e.append(buf, GAUGE, bucket, value, opts)
+}
+
+// AppendTiming appends the timing value of bucket to buf and returns the
+// extended buffer.
+func (e *Encoder) AppendTiming(buf []byte, bucket string, value interface{}, opts ...MetricOption) []byte {
+	return e.append(buf, TIMINGS, bucket, value, opts)
+}
+
+// AppendHistogram appends the histogram value of bucket to buf and returns the
+// extended buffer.
+func (e *Encoder) AppendHistogram(buf []byte, bucket string, value interface{}, opts ...MetricOption) []byte {
+	return e.append(buf, HISTOGRAM, bucket, value, opts)
+}
+
+// AppendUnique appends the set value of bucket to buf and returns the extended
+// buffer.
+func (e *Encoder) AppendUnique(buf []byte, bucket string, value string, opts ...MetricOption) []byte {
+	return e.append(buf, SET, bucket, value, opts)
+}
+
+// AppendMetric appends m to buf as Client.Send would send it and returns the
+// extended buffer.
+func (e *Encoder) AppendMetric(buf []byte, m *Metric) []byte {
+	em := GetMetric()
+	*em = *m
+	if em.Rate == 0 {
+		em.Rate = e.rateFor(em.Type)
+	}
+	if em.Prefix == "" {
+		em.Prefix = e.prefix
+	}
+	if em.Tags == "" {
+		em.Tags = e.tags
+	}
+	em.tagFormat = e.tagFormat
+	buf = e.serializer.AppendMetric(buf, em)
+	em.Release()
+	return buf
+}
+
+func (e *Encoder) append(buf []byte, t Type, bucket string, value interface{}, opts []MetricOption) []byte {
+	m := GetMetric()
+	m.Type = t
+	m.Prefix = e.prefix
+	m.Bucket = bucket
+	m.Value = value
+	m.Rate = e.rateFor(t)
+	m.Tags = e.tags
+	m.tagFormat = e.tagFormat
+	for _, o := range opts {
+		o(m)
+	}
+	buf = e.serializer.AppendMetric(buf, m)
+	m.Release()
+	return buf
+}
+
+// rateFor returns the sample rate of the metrics of the given type.
+func (e *Encoder) rateFor(t Type) float32 {
+	if rate, ok := e.rates[t]; ok {
+		return rate
+	}
+	return e.rate
+}
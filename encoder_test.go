@@ -0,0 +1,39 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	opts := []Option{Prefix("app."), TagsFormat(Datadog), Tags("a", "b"), SampleRateFor(TIMINGS, 0.5)}
+	e, err := NewEncoder(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf []byte
+	buf = e.AppendCount(buf, testKey, 1)
+	buf = e.AppendGauge(buf, testKey, -2.5)
+	buf = e.AppendTiming(buf, testKey, 3, Timestamp(time.Unix(1e9, 0)))
+	buf = e.AppendHistogram(buf, testKey, 4)
+	buf = e.AppendUnique(buf, testKey, "foo")
+	buf = e.AppendMetric(buf, &Metric{Type: COUNT, Bucket: testKey, Value: 5, Tags: "|#c:d"})
+
+	testClient(t, func(c *Client) {
+		randFloat = func() float32 { return 0.1 }
+		c.Count(testKey, 1)
+		c.Gauge(testKey, -2.5)
+		c.Timing(testKey, 3, Timestamp(time.Unix(1e9, 0)))
+		c.Histogram(testKey, 4)
+		c.Unique(testKey, "foo")
+		c.Send(&Metric{Type: COUNT, Bucket: testKey, Value: 5, Tags: "|#c:d"})
+		c.Close()
+		if got, want := string(buf), getOutput(c)+"\n"; got != want {
+			t.Errorf("Invalid output, got:\n%q\nwant:\n%q", got, want)
+		}
+	}, append(opts, MaxPacketSize(1000))...)
+
+	if _, err := NewEncoder(SampleRate(2)); err == nil {
+		t.Error("NewEncoder should fail with invalid options")
+	}
+}
@@ -0,0 +1,146 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NewFromEnv returns a new Client configured with the given options and then
+// with the following environment variables, which override the options:
+//   - STATSD_ADDR: the address of the StatsD daemon, e.g. "10.0.0.1:8125"
+//   - DD_AGENT_HOST and DD_DOGSTATSD_PORT: the host and port (8125 by default)
+//     of the Datadog agent, used if STATSD_ADDR is not set. The tags format
+//     defaults to Datadog when they are used.
+//   - STATSD_PREFIX: the prefix, replacing the one set with options
+//   - STATSD_TAG_FORMAT: the format of tags, "influxdb" or "datadog"
+//   - STATSD_TAGS: tags separated by commas or spaces, e.g.
+//     "env:prod,team:core", added once if EnvTags is used too
+//   - STATSD_SAMPLE_RATE: the sample rate, e.g. "0.5"
+//
+// An error is returned without a Client if a variable is malformed. Otherwise,
+// the errors are the ones of New.
+func NewFromEnv(opts ...Option) (*Client, error) {
+	envOpts, err := envOptions()
+	if err != nil {
+		return nil, err
+	}
+	return New(append(opts, envOpts...)...)
+}
+
+// envOptions returns the options set by the environment variables.
+func envOptions() ([]Option, error) {
+	var opts []Option
+	if addr := os.Getenv("STATSD_ADDR"); addr != "" {
+		opts = append(opts, Address(addr))
+	} else if host := os.Getenv("DD_AGENT_HOST"); host != "" {
+		port := os.Getenv("DD_DOGSTATSD_PORT")
+		if port == "" {
+			port = "8125"
+		}
+		opts = append(opts, Address(net.JoinHostPort(host, port)), Option(func(c *config) {
+			if c.Conn.TagFormat == 0 {
+				c.Conn.TagFormat = Datadog
+			}
+		}))
+	}
+	if prefix := os.Getenv("STATSD_PREFIX"); prefix != "" {
+		opts = append(opts, ReplacePrefix(prefix))
+	}
+	if s := os.Getenv("STATSD_TAG_FORMAT"); s != "" {
+		tf, err := parseTagFormat(s)
+		if err != nil {
+			return nil, &ConfigError{Option: "STATSD_TAG_FORMAT", Value: s, Reason: err.Error()}
+		}
+		opts = append(opts, TagsFormat(tf))
+	}
+	if s := os.Getenv("STATSD_TAGS"); s != "" {
+		tags, err := parseTagList(s)
+		if err != nil {
+			return nil, &ConfigError{Option: "STATSD_TAGS", Value: s, Reason: err.Error()}
+		}
+		opts = append(opts, Option(func(c *config) {
+			// EnvTags already added them.
+			if !c.envTags {
+				Tags(tags...)(c)
+			}
+		}))
+	}
+	if s := os.Getenv("STATSD_SAMPLE_RATE"); s != "" {
+		rate, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, &ConfigError{Option: "STATSD_SAMPLE_RATE", Value: s, Reason: err.Error()}
+		}
+		opts = append(opts, SampleRate(float32(rate)))
+	}
+	return opts, nil
+}
+
+// EnvTags appends the tags of the following environment variables to the tags
+// sent with every metrics, as the official Datadog client does:
+//   - DD_TAGS and STATSD_TAGS: tags separated by commas or spaces, e.g.
+//     "env:prod,team:core". The tags without a value, e.g. "canary", are sent
+//     as bare tags with the Datadog format.
+//   - DD_ENV, DD_SERVICE and DD_VERSION: the env, service and version tags of
+//     the Datadog unified service tagging.
+//
+// The variables are read when EnvTags is called. New returns a ConfigError if
+// a tag has no key.
+func EnvTags() Option {
+	var tags []string
+	for _, env := range []string{"DD_TAGS", "STATSD_TAGS"} {
+		s := os.Getenv(env)
+		t, err := parseTagList(s)
+		if err != nil {
+			err = &ConfigError{Option: env, Value: s, Reason: err.Error()}
+			return Option(func(c *config) {
+				c.setErr(err)
+			})
+		}
+		tags = append(tags, t...)
+	}
+	for _, t := range [][2]string{{"DD_ENV", "env"}, {"DD_SERVICE", "service"}, {"DD_VERSION", "version"}} {
+		if v := os.Getenv(t[0]); v != "" {
+			tags = append(tags, t[1], v)
+		}
+	}
+	return Option(func(c *config) {
+		Tags(tags...)(c)
+		c.envTags = true
+	})
+}
+
+// parseTagFormat parses the name of a tag format, case-insensitively.
+func parseTagFormat(s string) (TagFormat, error) {
+	switch strings.ToLower(s) {
+	case "influxdb":
+		return InfluxDB, nil
+	case "datadog":
+		return Datadog, nil
+	}
+	return 0, fmt.Errorf("unknown tag format %q", s)
+}
+
+// parseTagList parses "key:value" or "key=value" tags separated by commas or
+// spaces into key-value pairs as accepted by Tags. The tags without a value,
+// e.g. "canary", get an empty value.
+func parseTagList(s string) ([]string, error) {
+	var tags []string
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, t := range fields {
+		i := strings.IndexAny(t, ":=")
+		switch {
+		case i < 0:
+			tags = append(tags, t, "")
+		case i == 0:
+			return nil, fmt.Errorf("tag %q has no key", t)
+		default:
+			tags = append(tags, t[:i], t[i+1:])
+		}
+	}
+	return tags, nil
+}
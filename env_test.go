@@ -0,0 +1,144 @@
+package statsd
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	var addr string
+	dialTimeout = func(_, a string, _ time.Duration) (net.Conn, error) {
+		addr = a
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+	defer setenv(t, map[string]string{
+		"STATSD_ADDR":        "10.0.0.1:9125",
+		"DD_AGENT_HOST":      "10.0.0.2",
+		"STATSD_PREFIX":      "env_app",
+		"STATSD_TAG_FORMAT":  "InfluxDB",
+		"STATSD_TAGS":        "env:prod, team=core",
+		"STATSD_SAMPLE_RATE": "0.5",
+	})()
+
+	c, err := NewFromEnv(Prefix("app"), FlushPeriod(0))
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	if addr != "10.0.0.1:9125" {
+		t.Errorf("Address = %q, want %q", addr, "10.0.0.1:9125")
+	}
+	randFloat = func() float32 { return 0.1 }
+	c.Increment(testKey)
+	c.Close()
+	if got, want := getOutput(c), "env_app.test_key,env=prod,team=core:1|c|@0.5"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
+
+func TestNewFromEnvDatadog(t *testing.T) {
+	var addr string
+	dialTimeout = func(_, a string, _ time.Duration) (net.Conn, error) {
+		addr = a
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+	defer setenv(t, map[string]string{
+		"DD_AGENT_HOST": "10.0.0.2",
+		"STATSD_TAGS":   "env:prod",
+	})()
+
+	c, err := NewFromEnv(FlushPeriod(0))
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	if addr != "10.0.0.2:8125" {
+		t.Errorf("Address = %q, want %q", addr, "10.0.0.2:8125")
+	}
+	c.Increment(testKey)
+	c.Close()
+	if got, want := getOutput(c), "test_key:1|c|#env:prod"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+
+	os.Setenv("DD_DOGSTATSD_PORT", "8126")
+	defer os.Unsetenv("DD_DOGSTATSD_PORT")
+	if _, err := NewFromEnv(TagsFormat(InfluxDB)); err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	if addr != "10.0.0.2:8126" {
+		t.Errorf("Address = %q, want %q", addr, "10.0.0.2:8126")
+	}
+}
+
+func TestNewFromEnvErrors(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"STATSD_SAMPLE_RATE": "half"},
+		{"STATSD_TAGS": "env:prod,:core"},
+		{"STATSD_TAG_FORMAT": "graphite"},
+	} {
+		unset := setenv(t, env)
+		c, err := NewFromEnv()
+		if err == nil || c != nil {
+			t.Errorf("NewFromEnv() with %v = %v, %v, want an error", env, c, err)
+		}
+		unset()
+	}
+}
+
+func TestEnvTags(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"DD_TAGS":     "env:prod team:core,bare",
+		"STATSD_TAGS": "region=eu",
+		"DD_SERVICE":  "api",
+	})()
+
+	testOutput(t, "test_key:1|c|#env:prod,team:core,bare,region:eu,service:api", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), EnvTags())
+}
+
+func TestEnvTagsFromEnv(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"STATSD_ADDR": testAddr,
+		"STATSD_TAGS": "env:prod,canary",
+	})()
+
+	c, err := NewFromEnv(TagsFormat(Datadog), EnvTags(), Mute(true))
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	defer c.Close()
+	if got, want := c.Config().Tags, []string{"env", "prod", "canary", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Config().Tags = %q, want %q", got, want)
+	}
+}
+
+func TestEnvTagsError(t *testing.T) {
+	defer setenv(t, map[string]string{"DD_TAGS": "env:prod,:core"})()
+
+	c, err := New(EnvTags())
+	if err == nil {
+		c.Close()
+		t.Fatal("New(EnvTags()) returned no error")
+	}
+	if ce, ok := err.(*ConfigError); !ok || ce.Option != "DD_TAGS" {
+		t.Errorf("New(EnvTags()) = %v, want a ConfigError of DD_TAGS", err)
+	}
+}
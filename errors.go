@@ -0,0 +1,221 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrClosed is reported to the ErrorHandler for the metrics sent after the
+// Client is closed, which are dropped. It is also returned by Client.Flush
+// after Close.
+var ErrClosed = errors.New("statsd: client is closed")
+
+// A ConfigError is returned by New when the Client is misconfigured, i.e.
+// when an option (or the environment variable or DSN parameter setting it) has
+// an invalid value.
+type ConfigError struct {
+	// Option is the name of the invalid option, e.g. "Timeout".
+	Option string
+	// Value is the invalid value.
+	Value interface{}
+	// Reason explains why the value is invalid.
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("statsd: invalid %s %v: %s", e.Option, e.Value, e.Reason)
+}
+
+// validate returns a *ConfigError if the configuration is invalid.
+func (c *config) validate() error {
+	if c.err != nil {
+		return c.err
+	}
+	if !knownNetwork(c.Conn.Network) {
+		return &ConfigError{Option: "Network", Value: c.Conn.Network, Reason: "unknown network"}
+	}
+	if c.Conn.FallbackNetwork != "" && !knownNetwork(c.Conn.FallbackNetwork) {
+		return &ConfigError{Option: "FallbackNetwork", Value: c.Conn.FallbackNetwork, Reason: "unknown network"}
+	}
+	if c.Conn.Addr == "" {
+		return &ConfigError{Option: "Address", Value: `""`, Reason: "empty address"}
+	}
+	for _, addr := range c.Conn.Failover {
+		if addr == "" {
+			return &ConfigError{Option: "Failover", Value: `""`, Reason: "empty address"}
+		}
+	}
+	for _, addr := range c.Conn.Shards {
+		if addr == "" {
+			return &ConfigError{Option: "Shards", Value: `""`, Reason: "empty address"}
+		}
+	}
+	for t, addr := range c.Conn.Routes {
+		if addr == "" {
+			return &ConfigError{Option: "Route", Value: `""`, Reason: "empty address"}
+		}
+		if t.suffix() == "" {
+			return &ConfigError{Option: "Route", Value: t, Reason: "unknown metric type"}
+		}
+	}
+	if err := checkDuration("Timeout", c.Conn.Timeout); err != nil {
+		return err
+	}
+	if err := checkDuration("DialTimeout", c.Conn.DialTimeout); err != nil {
+		return err
+	}
+	if err := checkDuration("WriteTimeout", c.Conn.WriteTimeout); err != nil {
+		return err
+	}
+	if c.Conn.MuteAfter < 0 {
+		return &ConfigError{Option: "AutoMute", Value: c.Conn.MuteAfter, Reason: "negative number of failures"}
+	}
+	if c.Conn.MuteAfter > 0 && c.Conn.ProbePeriod <= 0 {
+		return &ConfigError{Option: "AutoMute", Value: c.Conn.ProbePeriod, Reason: "probe period must be positive"}
+	}
+	if err := checkDuration("BufferTTL", c.Conn.BufferTTL); err != nil {
+		return err
+	}
+	if err := checkDuration("MaxConnAge", c.Conn.MaxConnAge); err != nil {
+		return err
+	}
+	if err := checkDuration("FlushPeriod", c.Conn.FlushPeriod); err != nil {
+		return err
+	}
+	if c.Conn.SRV != "" && c.Conn.SRVRefresh <= 0 {
+		return &ConfigError{Option: "SRVRefresh", Value: c.Conn.SRVRefresh, Reason: "non-positive duration"}
+	}
+	if err := checkDuration("ClientTelemetry", c.Conn.TelemetryInterval); err != nil {
+		return err
+	}
+	if err := checkDuration("PollPeriod", c.Conn.PollPeriod); err != nil {
+		return err
+	}
+	if err := checkDuration("ConnectRetry", c.Conn.ConnectRetry); err != nil {
+		return err
+	}
+	if c.Conn.FlushJitter < 0 || c.Conn.FlushJitter > 1 {
+		return &ConfigError{Option: "FlushJitter", Value: c.Conn.FlushJitter, Reason: "fraction must be between 0 and 1"}
+	}
+	if c.Conn.MaxPacketSize < 0 {
+		return &ConfigError{Option: "MaxPacketSize", Value: c.Conn.MaxPacketSize, Reason: "negative size"}
+	}
+	if c.Conn.TopSeries < 0 {
+		return &ConfigError{Option: "TopSeries", Value: c.Conn.TopSeries, Reason: "negative number of series"}
+	}
+	if c.Conn.TopSeries > 0 && c.Conn.TopSeriesWindow <= 0 {
+		return &ConfigError{Option: "TopSeries", Value: c.Conn.TopSeriesWindow, Reason: "window must be positive"}
+	}
+	if c.Conn.InitialBufferSize < 0 {
+		return &ConfigError{Option: "InitialBufferSize", Value: c.Conn.InitialBufferSize, Reason: "negative size"}
+	}
+	if c.Conn.LineCacheSize < 0 {
+		return &ConfigError{Option: "LineCache", Value: c.Conn.LineCacheSize, Reason: "negative size"}
+	}
+	if c.Conn.SendBufferSize < 0 {
+		return &ConfigError{Option: "SendBufferSize", Value: c.Conn.SendBufferSize, Reason: "negative size"}
+	}
+	if c.Conn.TrafficClass < 0 || c.Conn.TrafficClass > 255 {
+		return &ConfigError{Option: "TrafficClass", Value: c.Conn.TrafficClass, Reason: "must be between 0 and 255"}
+	}
+	if c.Conn.TrafficClass != 0 && !trafficClassSupported {
+		return &ConfigError{Option: "TrafficClass", Value: c.Conn.TrafficClass, Reason: "not supported on this platform"}
+	}
+	if c.Conn.MTU < 0 {
+		return &ConfigError{Option: "MTU", Value: c.Conn.MTU, Reason: "negative MTU"}
+	}
+	for _, p := range c.Conn.Percentiles {
+		if p <= 0 || p > 100 {
+			return &ConfigError{Option: "TimingSummaries", Value: p, Reason: "percentile must be in ]0, 100]"}
+		}
+	}
+	if c.Conn.SketchAccuracy < 0 || c.Conn.SketchAccuracy >= 1 {
+		return &ConfigError{Option: "Sketches", Value: c.Conn.SketchAccuracy, Reason: "accuracy must be in [0, 1["}
+	}
+	for _, p := range c.Conn.SketchQuantiles {
+		if p <= 0 || p > 100 {
+			return &ConfigError{Option: "Sketches", Value: p, Reason: "percentile must be in ]0, 100]"}
+		}
+	}
+	if err := checkRate("SampleRate", c.Client.Rate); err != nil {
+		return err
+	}
+	for _, rate := range c.Client.Rates {
+		if err := checkRate("SampleRateFor", rate); err != nil {
+			return err
+		}
+	}
+	switch c.Conn.TagFormat {
+	case 0, InfluxDB, Datadog:
+	default:
+		return &ConfigError{Option: "TagsFormat", Value: c.Conn.TagFormat, Reason: "unknown format"}
+	}
+	switch c.Client.Priority {
+	case 0, HighPriority, LowPriority:
+	default:
+		return &ConfigError{Option: "DefaultPriority", Value: c.Client.Priority, Reason: "unknown priority"}
+	}
+	if c.Client.Naming > Dotted {
+		return &ConfigError{Option: "NamingConvention", Value: c.Client.Naming, Reason: "unknown convention"}
+	}
+	if c.Client.TimingUnit > FloatMilliseconds {
+		return &ConfigError{Option: "TimingUnit", Value: c.Client.TimingUnit, Reason: "unknown unit"}
+	}
+	switch c.Conn.TagPolicy {
+	case KeepLast, KeepFirst, KeepAll:
+	default:
+		return &ConfigError{Option: "TagDedup", Value: c.Conn.TagPolicy, Reason: "unknown policy"}
+	}
+	for _, t := range c.Client.Tags {
+		if err := checkTag(c.Conn.TagFormat, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func knownNetwork(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram", "unixpacket", "npipe":
+		return true
+	}
+	return false
+}
+
+func checkDuration(option string, d time.Duration) error {
+	if d < 0 {
+		return &ConfigError{Option: option, Value: d, Reason: "negative duration"}
+	}
+	return nil
+}
+
+func checkRate(option string, rate float32) error {
+	if rate < 0 || rate > 1 {
+		return &ConfigError{Option: option, Value: rate, Reason: "rate must be between 0 and 1"}
+	}
+	return nil
+}
+
+// checkTag returns an error if the tag contains characters reserved by the
+// wire format.
+func checkTag(tf TagFormat, t tag) error {
+	if t.K == "" {
+		return &ConfigError{Option: "Tags", Value: `""`, Reason: "empty key"}
+	}
+	reserved := ",|\n"
+	switch tf {
+	case InfluxDB:
+		reserved += "= "
+	case Datadog:
+		reserved += "#"
+	}
+	if strings.ContainsAny(t.K, reserved) || (tf == Datadog && strings.Contains(t.K, ":")) {
+		return &ConfigError{Option: "Tags", Value: fmt.Sprintf("%q", t.K), Reason: "reserved character in key"}
+	}
+	if strings.ContainsAny(t.V, reserved) {
+		return &ConfigError{Option: "Tags", Value: fmt.Sprintf("%q", t.V), Reason: "reserved character in value of key " + t.K}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package statsd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		opts   []Option
+		option string
+	}{
+		{[]Option{Network("ip")}, "Network"},
+		{[]Option{Network("")}, "Network"},
+		{[]Option{Address("")}, "Address"},
+		{[]Option{Route(TIMINGS, "")}, "Route"},
+		{[]Option{Route(Type(42), ":8126")}, "Route"},
+		{[]Option{Shards([]string{":8125", ""})}, "Shards"},
+		{[]Option{Failover([]string{":8126", ""})}, "Failover"},
+		{[]Option{FallbackNetwork("ip")}, "FallbackNetwork"},
+		{[]Option{Timeout(-1)}, "Timeout"},
+		{[]Option{DialTimeout(-1)}, "DialTimeout"},
+		{[]Option{WriteTimeout(-1)}, "WriteTimeout"},
+		{[]Option{MaxConnAge(-1)}, "MaxConnAge"},
+		{[]Option{BufferTTL(-1)}, "BufferTTL"},
+		{[]Option{AutoMute(-1, time.Second)}, "AutoMute"},
+		{[]Option{AutoMute(3, 0)}, "AutoMute"},
+		{[]Option{FlushPeriod(-1)}, "FlushPeriod"},
+		{[]Option{ClientTelemetry(-1, "")}, "ClientTelemetry"},
+		{[]Option{PollPeriod(-1)}, "PollPeriod"},
+		{[]Option{FlushJitter(1.5)}, "FlushJitter"},
+		{[]Option{MaxPacketSize(-1)}, "MaxPacketSize"},
+		{[]Option{InitialBufferSize(-1)}, "InitialBufferSize"},
+		{[]Option{TopSeries(-1, time.Minute)}, "TopSeries"},
+		{[]Option{TopSeries(10, 0)}, "TopSeries"},
+		{[]Option{LineCache(-1)}, "LineCache"},
+		{[]Option{TimingUnit(42)}, "TimingUnit"},
+		{[]Option{NamingConvention(42)}, "NamingConvention"},
+		{[]Option{SendBufferSize(-1)}, "SendBufferSize"},
+		{[]Option{TrafficClass(256)}, "TrafficClass"},
+		{[]Option{MTU(-1)}, "MTU"},
+		{[]Option{TimingSummaries(0)}, "TimingSummaries"},
+		{[]Option{Sketches(1)}, "Sketches"},
+		{[]Option{Sketches(0.01, 101)}, "Sketches"},
+		{[]Option{SampleRate(1.5)}, "SampleRate"},
+		{[]Option{SampleRateFor(TIMINGS, -0.1)}, "SampleRateFor"},
+		{[]Option{TagsFormat(42)}, "TagsFormat"},
+		{[]Option{TagDedup(42)}, "TagDedup"},
+		{[]Option{DefaultPriority(42)}, "DefaultPriority"},
+		{[]Option{TagsFormat(Datadog), Tags("", "value")}, "Tags"},
+		{[]Option{TagsFormat(Datadog), Tags("key:1", "value")}, "Tags"},
+		{[]Option{TagsFormat(Datadog), Tags("key", "a,b")}, "Tags"},
+		{[]Option{TagsFormat(InfluxDB), Tags("key", "a=b")}, "Tags"},
+		{[]Option{TagsFormat(InfluxDB), Tags("key", "a b")}, "Tags"},
+		{[]Option{Tags("key", "a|b")}, "Tags"},
+		{[]Option{DSN("udp://localhost:8125?rate=half")}, "DSN parameter rate"},
+	}
+	for _, test := range tests {
+		c, err := New(test.opts...)
+		if c != nil {
+			t.Errorf("New() should not return a Client when the %s option is invalid", test.option)
+		}
+		var cerr *ConfigError
+		if !errors.As(err, &cerr) {
+			t.Errorf("New() = %v, want a *ConfigError", err)
+			continue
+		}
+		if cerr.Option != test.option {
+			t.Errorf("ConfigError.Option = %q, want %q (%v)", cerr.Option, test.option, err)
+		}
+	}
+}
+
+func TestValidConfig(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Close()
+	},
+		// A zero size disables the buffering, as documented in doc.go.
+		MaxPacketSize(0),
+		SampleRate(0),
+		TagsFormat(Datadog),
+		Tags("url", "http://example.com", "empty", ""),
+	)
+}
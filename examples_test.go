@@ -15,6 +15,8 @@ var (
 
 func ping(url string) {}
 
+func query(q string) error { return nil }
+
 func Example() {
 	c, err := statsd.New() // Connect to the UDP port 8125 by default.
 	if err != nil {
@@ -100,6 +102,11 @@ func ExampleSampleRate() {
 	c, err = statsd.New(statsd.SampleRate(0.2)) // Send metrics 20% of the time.
 }
 
+func ExampleSampleRateFor() {
+	// Send every counter but only 10% of the timings.
+	c, err = statsd.New(statsd.SampleRateFor(statsd.TIMINGS, 0.1))
+}
+
 func ExamplePrefix() {
 	c, err := statsd.New(statsd.Prefix("my_app"))
 	if err != nil {
@@ -108,6 +115,19 @@ func ExamplePrefix() {
 	c.Increment("foo.bar") // Increments: my_app.foo.bar
 }
 
+func ExampleReplacePrefix() {
+	c, err := statsd.New(statsd.Prefix("my_app"))
+	if err != nil {
+		log.Print(err)
+	}
+
+	appStats := c.Clone(statsd.Prefix("http"))
+	appStats.Increment("foo.bar") // Increments: my_app.http.foo.bar
+
+	sharedStats := c.Clone(statsd.ReplacePrefix("shared"))
+	sharedStats.Increment("foo.bar") // Increments: shared.foo.bar
+}
+
 func ExampleTags() {
 	c, err = statsd.New(
 		statsd.TagsFormat(statsd.InfluxDB),
@@ -120,3 +140,42 @@ func ExampleClient_NewTiming() {
 	defer c.NewTiming().Send("homepage.response_time")
 	ping("http://example.com/")
 }
+
+func ExampleClient_Observe() {
+	// Send the time taken by the query, and increment db.query.success or
+	// db.query.error.
+	err := c.Observe("db.query", func() error {
+		return query("SELECT 1")
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func ExampleSetDefault() {
+	c, err := statsd.New()
+	if err != nil {
+		log.Print(err)
+	}
+	statsd.SetDefault(c)
+
+	// Anywhere in the program.
+	statsd.Increment("foo.counter")
+}
+
+func ExampleRegister() {
+	business, err := statsd.New(statsd.Address("192.168.0.5:8125"))
+	if err != nil {
+		log.Print(err)
+	}
+	statsd.Register("business", business)
+
+	// Anywhere in the program.
+	statsd.Get("business").Increment("orders")
+}
+
+func ExampleTimestamp() {
+	start := time.Now()
+	// Run a batch job and send its results once it is done.
+	c.Count("job.processed", 1000, statsd.Timestamp(start))
+}
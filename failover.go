@@ -0,0 +1,73 @@
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+// errFailover is the error reported to OnDisconnect when the Client moves to
+// another address of the failover list.
+var errFailover = errors.New("statsd: failing over to another address")
+
+// Failover tuning.
+const (
+	// failoverThreshold is the number of consecutive failures after which the
+	// next address of the failover list is used.
+	failoverThreshold = 3
+	// failoverRetryPeriod is the period at which the primary address is tried
+	// again once failed over.
+	failoverRetryPeriod = 30 * time.Second
+)
+
+// failed records a failure to send to the current address and moves to the
+// next address of the failover list after failoverThreshold consecutive
+// failures.
+func (c *conn) failed() {
+	c.failedFlushes++
+	c.muteFailed()
+	if len(c.failover) < 2 {
+		return
+	}
+	c.failures++
+	if c.failures < failoverThreshold {
+		return
+	}
+	c.failures = 0
+	c.failoverIdx = (c.failoverIdx + 1) % len(c.failover)
+	c.logf("failing over from %s to %s", c.addr, c.failover[c.failoverIdx])
+	if c.w != nil {
+		c.disconnect(errFailover)
+	}
+	c.addr = c.failover[c.failoverIdx]
+	c.failedOverAt = c.clock.Now()
+}
+
+// succeeded records a successful send to the current address.
+func (c *conn) succeeded() {
+	c.failures = 0
+	c.failedFlushes = 0
+}
+
+// tryPrimary reconnects to the primary address if it is reachable again after
+// a failover. It is tried at most once per failoverRetryPeriod.
+func (c *conn) tryPrimary() {
+	if c.failoverIdx == 0 || c.clock.Now().Sub(c.failedOverAt) < failoverRetryPeriod {
+		return
+	}
+	c.failedOverAt = c.clock.Now()
+	w, addr := c.w, c.addr
+	c.addr = c.failover[0]
+	if err := c.dial(); err != nil {
+		c.w, c.addr = w, addr
+		return
+	}
+	if w != nil {
+		w.Close()
+		if c.onDisconnect != nil {
+			c.onDisconnect(addr, errFailover)
+		}
+	}
+	c.failoverIdx = 0
+	c.failures = 0
+	c.logf("back to the primary address %s", c.addr)
+}
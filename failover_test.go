@@ -0,0 +1,63 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFailover(t *testing.T) {
+	up := map[string]bool{":8125": false, ":8126": true}
+	conns := map[string]*testBuffer{}
+	dialTimeout = func(_, addr string, _ time.Duration) (net.Conn, error) {
+		if !up[addr] {
+			return nil, errors.New("down")
+		}
+		conns[addr] = &testBuffer{}
+		return conns[addr], nil
+	}
+	date := testDate
+	now = func() time.Time { return date }
+	defer func() {
+		dialTimeout = net.DialTimeout
+		now = time.Now
+	}()
+
+	c, _ := New(FlushPeriod(0), Failover([]string{":8126"}), ErrorHandler(func(error) {}))
+	flush := func(n int) {
+		c.Count(testKey, n)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+	}
+	flush(1)
+	flush(2)
+	if c.conn.addr != ":8125" {
+		t.Errorf("addr = %q after 2 failures, want the primary address", c.conn.addr)
+	}
+	flush(3)
+	if c.conn.addr != ":8126" {
+		t.Fatalf("addr = %q after 3 failures, want the failover address", c.conn.addr)
+	}
+	flush(4)
+	// The buffer is kept while the addresses are down.
+	if got, want := conns[":8126"].buf.String(), "test_key:1|c\ntest_key:2|c\ntest_key:3|c\ntest_key:4|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+
+	up[":8125"] = true
+	date = date.Add(failoverRetryPeriod - time.Second)
+	flush(5)
+	if c.conn.addr != ":8126" {
+		t.Errorf("addr = %q, want the primary address not to be retried yet", c.conn.addr)
+	}
+	date = date.Add(time.Second)
+	flush(6)
+	if c.conn.addr != ":8125" {
+		t.Errorf("addr = %q, want the primary address to be used again", c.conn.addr)
+	}
+	if got, want := conns[":8125"].buf.String(), "test_key:6|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFallbackNetwork(t *testing.T) {
+	var dialed []string
+	dialTimeout = func(network, addr string, _ time.Duration) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		if network == "udp" {
+			return nil, errors.New("port blocked")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(FlushPeriod(0), FallbackNetwork("tcp"), FallbackAddress(":8126"))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	if got, want := getOutput(c), "test_key:1|c\n"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	if want := []string{"udp :8125", "tcp :8126"}; len(dialed) != 2 || dialed[0] != want[0] || dialed[1] != want[1] {
+		t.Errorf("dialed %q, want %q", dialed, want)
+	}
+	c.Close()
+}
+
+func TestFallbackNetworkFailure(t *testing.T) {
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(FlushPeriod(0), FallbackNetwork("tcp"))
+	if err == nil || err.Error() != "unreachable" {
+		t.Errorf("New() = %v, want the error of the primary network", err)
+	}
+	if c.conn.network != "udp" || c.conn.sendLastEndl {
+		t.Errorf("network = %q, want udp to be kept", c.conn.network)
+	}
+}
@@ -0,0 +1,125 @@
+package statsd
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// formatCacheSize is the number of bucket names cached by the formatted
+// metric methods, e.g. CountF.
+const formatCacheSize = 1024
+
+// maxFormatArgs is the maximum number of arguments of the bucket names which
+// are cached.
+const maxFormatArgs = 4
+
+// A formatKey identifies a bucket name rendered by fmt.Sprintf.
+type formatKey struct {
+	format string
+	n      int
+	args   [maxFormatArgs]interface{}
+}
+
+type formatEntry struct {
+	key  formatKey
+	name string
+}
+
+// formatCache is an LRU cache of the bucket names rendered by fmt.Sprintf,
+// shared by a Client and its clones.
+type formatCache struct {
+	mu      sync.Mutex
+	entries map[formatKey]*list.Element
+	lru     *list.List
+}
+
+func newFormatCache() *formatCache {
+	return &formatCache{
+		entries: make(map[formatKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// sprintf returns fmt.Sprintf(format, args...), cached if the arguments are
+// few and of basic types.
+func (fc *formatCache) sprintf(format string, args []interface{}) string {
+	key, ok := newFormatKey(format, args)
+	if !ok {
+		return fmt.Sprintf(format, args...)
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if e, ok := fc.entries[key]; ok {
+		fc.lru.MoveToFront(e)
+		return e.Value.(*formatEntry).name
+	}
+	name := fmt.Sprintf(format, args...)
+	if fc.lru.Len() >= formatCacheSize {
+		e := fc.lru.Back()
+		fc.lru.Remove(e)
+		delete(fc.entries, e.Value.(*formatEntry).key)
+	}
+	fc.entries[key] = fc.lru.PushFront(&formatEntry{key: key, name: name})
+	return name
+}
+
+// newFormatKey returns the key of the bucket name. ok is false if the name
+// cannot be cached, e.g. because an argument is not comparable.
+func newFormatKey(format string, args []interface{}) (key formatKey, ok bool) {
+	if len(args) > maxFormatArgs {
+		return key, false
+	}
+	for _, a := range args {
+		switch a.(type) {
+		case string, bool, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64, float32, float64:
+		default:
+			return key, false
+		}
+	}
+	key.format = format
+	key.n = len(args)
+	copy(key.args[:], args)
+	return key, true
+}
+
+// CountF adds n to the bucket named fmt.Sprintf(format, args...). The names
+// are cached so that sending metrics to a small set of formatted buckets, e.g.
+// "http.%s.%d", does not render them every time.
+func (c *Client) CountF(format string, n interface{}, args ...interface{}) {
+	c.sendF(COUNT, format, n, args)
+}
+
+// IncrementF increments the bucket named fmt.Sprintf(format, args...), like
+// CountF.
+func (c *Client) IncrementF(format string, args ...interface{}) {
+	c.sendF(COUNT, format, 1, args)
+}
+
+// GaugeF records an absolute value for the bucket named
+// fmt.Sprintf(format, args...), like CountF.
+func (c *Client) GaugeF(format string, value interface{}, args ...interface{}) {
+	c.sendF(GAUGE, format, value, args)
+}
+
+// TimingF sends a timing value to the bucket named
+// fmt.Sprintf(format, args...), like CountF.
+func (c *Client) TimingF(format string, value interface{}, args ...interface{}) {
+	c.sendF(TIMINGS, format, c.timingValue(value), args)
+}
+
+// HistogramF sends an histogram value to the bucket named
+// fmt.Sprintf(format, args...), like CountF.
+func (c *Client) HistogramF(format string, value interface{}, args ...interface{}) {
+	c.sendF(HISTOGRAM, format, value, args)
+}
+
+// sendF sends the metric to the bucket named fmt.Sprintf(format, args...),
+// which is not rendered if the Client is muted.
+func (c *Client) sendF(t Type, format string, value interface{}, args []interface{}) {
+	if c.muted && !c.conn.record {
+		return
+	}
+	c.send(t, c.conn.formats.sprintf(format, args), value, nil)
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFormattedMetrics(t *testing.T) {
+	want := "http.get.200:1|c\nhttp.get.200:2|c\nqueue.jobs:3|g\ndb.users:1500|ms\nsize.small:4|h"
+	testOutput(t, want, func(c *Client) {
+		c.IncrementF("http.%s.%d", "get", 200)
+		c.CountF("http.%s.%d", 2, "get", 200)
+		c.GaugeF("queue.%s", 3, "jobs")
+		c.TimingF("db.%s", 1500*time.Microsecond, "users")
+		c.HistogramF("size.%s", 4, "small")
+		if got := len(c.conn.formats.entries); got != 4 {
+			t.Errorf("%d cached names, want 4", got)
+		}
+	}, TimingUnit(Microseconds))
+}
+
+func TestFormatCache(t *testing.T) {
+	fc := newFormatCache()
+	for i := 0; i < formatCacheSize+10; i++ {
+		if got, want := fc.sprintf("key.%d", []interface{}{i}), fmt.Sprintf("key.%d", i); got != want {
+			t.Fatalf("sprintf() = %q, want %q", got, want)
+		}
+	}
+	if got := fc.lru.Len(); got != formatCacheSize {
+		t.Errorf("%d cached names, want %d", got, formatCacheSize)
+	}
+	key, _ := newFormatKey("key.%d", []interface{}{0})
+	if _, ok := fc.entries[key]; ok {
+		t.Error("The least recently used name should be evicted")
+	}
+
+	// The names with arguments which are not comparable are not cached.
+	if got, want := fc.sprintf("key.%v", []interface{}{[]int{1}}), "key.[1]"; got != want {
+		t.Errorf("sprintf() = %q, want %q", got, want)
+	}
+	if got := fc.lru.Len(); got != formatCacheSize {
+		t.Errorf("%d cached names, want %d", got, formatCacheSize)
+	}
+}
+
+func BenchmarkCountF(b *testing.B) {
+	serv := newServer(b, "udp", testAddr, func([]byte) {})
+	c, err := New(Address(serv.addr), FlushPeriod(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.CountF("http.%s.%d", 1, "get", 200)
+	}
+	c.Close()
+	serv.Close()
+}
@@ -0,0 +1,130 @@
+package statsd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// GraphiteSerializer is the Serializer of the Graphite plaintext protocol:
+//
+//	<path> <value> <timestamp>
+//
+// Graphite has no metric types, so all the metrics are sent as values, the
+// counts being scaled by their sample rate, and the sets are skipped. The tags
+// are sent in the Graphite format, e.g. "path;tag=value". Use it with the
+// Network("tcp") option and the address of carbon, usually ":2003".
+type GraphiteSerializer struct {
+	// TagFormat is the format the tags of the metrics have been rendered in.
+	TagFormat TagFormat
+}
+
+// AppendMetric implements the Serializer interface.
+func (s *GraphiteSerializer) AppendMetric(buf []byte, m *Metric) []byte {
+	if m.Type == SET {
+		return buf
+	}
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = now()
+	}
+	if values, ok := m.Value.([]float64); ok {
+		for _, v := range values {
+			buf = s.appendLine(buf, m, v, ts.Unix())
+		}
+		return buf
+	}
+	return s.appendLine(buf, m, m.Value, ts.Unix())
+}
+
+func (s *GraphiteSerializer) appendLine(buf []byte, m *Metric, value interface{}, ts int64) []byte {
+	buf = append(buf, m.Prefix...)
+	buf = append(buf, m.Bucket...)
+	for _, t := range splitTags(s.TagFormat, m.Tags) {
+		buf = append(buf, ';')
+		buf = append(buf, t.K...)
+		buf = append(buf, '=')
+		buf = append(buf, t.V...)
+	}
+	buf = append(buf, ' ')
+	if m.Type == COUNT && m.Rate > 0 && m.Rate < 1 {
+		v, _ := toFloat64(value)
+		buf = strconv.AppendFloat(buf, v/float64(m.Rate), 'f', -1, 64)
+	} else {
+		buf = appendNumber(buf, value)
+	}
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, ts, 10)
+	return append(buf, '\n')
+}
+
+// A Framer is a Serializer which encodes the payloads as a whole before they
+// are written, e.g. to batch the metrics in a binary frame.
+//
+// Frame appends the encoding of the payload p, made of the lines appended by
+// AppendMetric, to dst and returns the extended buffer. The last line of p
+// may not be terminated by '\n'.
+type Framer interface {
+	Serializer
+	Frame(dst, p []byte) []byte
+}
+
+// GraphitePickleSerializer is the Serializer of the Graphite pickle protocol,
+// which sends the metrics in batches to carbon more efficiently than the
+// plaintext protocol. Use it with the Network("tcp") option and the address of
+// the pickle receiver of carbon, usually ":2004".
+type GraphitePickleSerializer struct {
+	GraphiteSerializer
+}
+
+// Pickle opcodes, see the pickle module of Python.
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleBinUnicode = 'X'
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleAppends    = 'e'
+	pickleStop       = '.'
+)
+
+// Frame implements the Framer interface. The payload is sent as a pickled
+// list of (path, (timestamp, value)) tuples, prefixed by its length.
+func (s *GraphitePickleSerializer) Frame(dst, p []byte) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0, pickleProto, 2, pickleEmptyList, pickleMark)
+	for len(p) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			line, p = p, nil
+		}
+		fields := bytes.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		value, err1 := strconv.ParseFloat(string(fields[1]), 64)
+		ts, err2 := strconv.ParseFloat(string(fields[2]), 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		dst = append(dst, pickleBinUnicode, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(dst[len(dst)-4:], uint32(len(fields[0])))
+		dst = append(dst, fields[0]...)
+		dst = appendPickleFloat(dst, ts)
+		dst = appendPickleFloat(dst, value)
+		dst = append(dst, pickleTuple2, pickleTuple2)
+	}
+	dst = append(dst, pickleAppends, pickleStop)
+	binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
+
+func appendPickleFloat(dst []byte, f float64) []byte {
+	dst = append(dst, pickleBinFloat, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(dst[len(dst)-8:], math.Float64bits(f))
+	return dst
+}
@@ -0,0 +1,46 @@
+package statsd
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestGraphiteSerializer(t *testing.T) {
+	now = func() time.Time { return testDate }
+	defer func() { now = time.Now }()
+	randFloat = func() float32 { return 0.1 }
+	defer func() { randFloat = rand.Float32 }()
+
+	want := "test_key 1 1445532780\n" +
+		"test_key;a=b 4 1445532780\n" +
+		"test_key -2.5 1445532780\n" +
+		"test_key 3 1445532780\n" +
+		"test_key 1 1000000000\n"
+	testOutput(t, want, func(c *Client) {
+		c.Increment(testKey)
+		c.Clone(Tags("a", "b"), SampleRate(0.5)).Count(testKey, 2)
+		c.Gauge(testKey, -2.5)
+		c.Timing(testKey, 3)
+		c.Unique(testKey, "skipped")
+		c.Increment(testKey, Timestamp(time.Unix(1e9, 0)))
+	}, Network("tcp"), TagsFormat(InfluxDB), WireFormat(&GraphiteSerializer{TagFormat: InfluxDB}))
+}
+
+func TestGraphitePickleSerializer(t *testing.T) {
+	now = func() time.Time { return testDate }
+	defer func() { now = time.Now }()
+
+	// The frame is checked against pickle.loads of Python.
+	want := "\x00\x00\x00@\x80\x02](X\a\x00\x00\x00a.b;x=yGA\xd0\xeeP9@\x00\x00G?\xf8\x00\x00\x00\x00\x00\x00\x86\x86" +
+		"X\x01\x00\x00\x00cGA\xd0\xeeP9\x80\x00\x00G\xc0\x00\x00\x00\x00\x00\x00\x00\x86\x86e."
+	s := &GraphitePickleSerializer{}
+	if got := string(s.Frame(nil, []byte("a.b;x=y 1.5 1136214245\nc -2 1136214246"))); got != want {
+		t.Errorf("Frame() = %q, want %q", got, want)
+	}
+
+	want = string(s.Frame(nil, []byte("test_key 1 1445532780\n")))
+	testOutput(t, want, func(c *Client) {
+		c.Increment(testKey)
+	}, Network("tcp"), WireFormat(&GraphitePickleSerializer{}))
+}
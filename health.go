@@ -0,0 +1,46 @@
+package statsd
+
+import "time"
+
+// Health is the state of the connections of a Client, e.g. to report it in a
+// health check handler.
+type Health struct {
+	// Connected is whether the Client is connected to all its StatsD
+	// daemons.
+	Connected bool
+	// AutoMuted is whether the AutoMute option muted the Client for one of
+	// its StatsD daemons.
+	AutoMuted bool
+	// LastFlush is the time of the last successful flush, or of the oldest
+	// one if the Client sends to several StatsD daemons. It is zero if
+	// nothing has been sent yet.
+	LastFlush time.Time
+	// ConsecutiveFailures is the number of consecutive failed flushes, the
+	// highest one if the Client sends to several StatsD daemons.
+	ConsecutiveFailures int
+	// QueueBytes is the number of bytes in the overflow queue.
+	QueueBytes int
+}
+
+// Health returns the health of the connections of the Client, which are shared
+// by its clones. A muted Client is never connected.
+func (c *Client) Health() Health {
+	if c.muted {
+		return Health{}
+	}
+	h := Health{Connected: true}
+	for i, cn := range c.conn.conns() {
+		cn.wmu.Lock()
+		h.Connected = h.Connected && cn.w != nil && !cn.closed
+		h.AutoMuted = h.AutoMuted || cn.isAutoMuted()
+		if i == 0 || cn.lastFlush.Before(h.LastFlush) {
+			h.LastFlush = cn.lastFlush
+		}
+		if cn.failedFlushes > h.ConsecutiveFailures {
+			h.ConsecutiveFailures = cn.failedFlushes
+		}
+		h.QueueBytes += cn.queueSize
+		cn.wmu.Unlock()
+	}
+	return h
+}
@@ -0,0 +1,52 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	clk := &fakeClock{t: testDate}
+	c, err := New(OverflowQueue(100), UseClock(clk), FlushPeriod(0), ErrorHandler(func(error) {}))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.Flush()
+	c.Increment(testKey)
+	c.Flush()
+	if got, want := c.Health(), (Health{ConsecutiveFailures: 2, QueueBytes: 26}); got != want {
+		t.Errorf("Health() = %+v, want %+v", got, want)
+	}
+
+	down = false
+	clk.Advance(time.Second)
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	want := Health{Connected: true, LastFlush: testDate.Add(time.Second)}
+	if got := c.Health(); got != want {
+		t.Errorf("Health() = %+v, want %+v", got, want)
+	}
+	c.Close()
+	if c.Health().Connected {
+		t.Error("Health().Connected = true, want false once the Client is closed")
+	}
+
+	muted, _ := New(Mute(true))
+	if got := muted.Health(); got != (Health{}) {
+		t.Errorf("Health() = %+v, want the zero Health for a muted Client", got)
+	}
+}
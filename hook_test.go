@@ -0,0 +1,62 @@
+package statsd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricHook(t *testing.T) {
+	testOutput(t, "app.test_key:1|c|#tag1:value1,env:prod\napp.renamed:2|c|#tag1:value1,env:prod", func(c *Client) {
+		c.Increment(testKey)
+		c.Count("old_key", 2)
+		c.Count("debug.key", 3)
+	},
+		Prefix("app"),
+		TagsFormat(Datadog),
+		Tags("tag1", "value1"),
+		MetricHook(func(m *Metric) bool {
+			return !strings.HasPrefix(m.Bucket, "debug.")
+		}),
+		MetricHook(func(m *Metric) bool {
+			if m.Bucket == "old_key" {
+				m.Bucket = "renamed"
+			}
+			m.AddTag("env", "prod")
+			return true
+		}),
+	)
+}
+
+func TestCloneMetricHook(t *testing.T) {
+	var names []string
+	testOutput(t, "test_key,tag1=value1:1|c\nhttp.test_key,tag1=value1,route=home:1|c", func(c *Client) {
+		clone := c.Clone(Prefix("http"), MetricHook(func(m *Metric) bool {
+			m.AddTag("route", "home")
+			return true
+		}))
+		c.Increment(testKey)
+		clone.Increment(testKey)
+	},
+		TagsFormat(InfluxDB),
+		MetricHook(func(m *Metric) bool {
+			names = append(names, m.Name())
+			m.AddTag("tag1", "value1")
+			return true
+		}),
+	)
+	if want := "test_key,http.test_key"; strings.Join(names, ",") != want {
+		t.Errorf("hook called with %q, want %q", names, want)
+	}
+}
+
+func TestRewriteRule(t *testing.T) {
+	testOutput(t, "app.http.latency.home:1|ms\napp.http.home.requests:1|c\napp.db.latency:2|ms", func(c *Client) {
+		c.Timing("http.home.latency", 1)
+		c.Increment("http.home.requests")
+		c.Timing("database.latency", 2)
+	},
+		Prefix("app"),
+		RewriteRule(`^http\.(\w+)\.latency$`, "http.latency.$1"),
+		RewriteRule(`^database\.`, "db."),
+	)
+}
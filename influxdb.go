@@ -0,0 +1,77 @@
+package statsd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// InfluxDBSerializer is the Serializer of the InfluxDB line protocol, to write
+// the metrics straight to InfluxDB without a StatsD daemon like Telegraf:
+//
+//	<measurement>,<tag>=<value> value=<value> <timestamp>
+//
+// The measurement is the name of the metric and the value is a float field,
+// or a string field for sets. The counts are scaled by their sample rate. The
+// timestamps are in nanoseconds.
+type InfluxDBSerializer struct {
+	// TagFormat is the format the tags of the metrics have been rendered in.
+	TagFormat TagFormat
+	// Field is the key of the value field. If it is empty, "value" is used.
+	Field string
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringFieldEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// AppendMetric implements the Serializer interface.
+func (s *InfluxDBSerializer) AppendMetric(buf []byte, m *Metric) []byte {
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = now()
+	}
+	if values, ok := m.Value.([]float64); ok {
+		for _, v := range values {
+			buf = s.appendLine(buf, m, v, ts.UnixNano())
+		}
+		return buf
+	}
+	return s.appendLine(buf, m, m.Value, ts.UnixNano())
+}
+
+func (s *InfluxDBSerializer) appendLine(buf []byte, m *Metric, value interface{}, ts int64) []byte {
+	if _, ok := value.(string); !ok {
+		if _, ok := toFloat64(value); !ok {
+			return buf
+		}
+	}
+	buf = append(buf, measurementEscaper.Replace(m.Prefix+m.Bucket)...)
+	for _, t := range splitTags(s.TagFormat, m.Tags) {
+		buf = append(buf, ',')
+		buf = append(buf, tagEscaper.Replace(t.K)...)
+		buf = append(buf, '=')
+		buf = append(buf, tagEscaper.Replace(t.V)...)
+	}
+	buf = append(buf, ' ')
+	if s.Field == "" {
+		buf = append(buf, "value"...)
+	} else {
+		buf = append(buf, tagEscaper.Replace(s.Field)...)
+	}
+	buf = append(buf, '=')
+	if str, ok := value.(string); ok {
+		buf = append(buf, '"')
+		buf = append(buf, stringFieldEscaper.Replace(str)...)
+		buf = append(buf, '"')
+	} else if m.Type == COUNT && m.Rate > 0 && m.Rate < 1 {
+		v, _ := toFloat64(value)
+		buf = strconv.AppendFloat(buf, v/float64(m.Rate), 'f', -1, 64)
+	} else {
+		buf = appendNumber(buf, value)
+	}
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, ts, 10)
+	return append(buf, '\n')
+}
@@ -0,0 +1,30 @@
+package statsd
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestInfluxDBSerializer(t *testing.T) {
+	now = func() time.Time { return testDate }
+	defer func() { now = time.Now }()
+	randFloat = func() float32 { return 0.1 }
+	defer func() { randFloat = rand.Float32 }()
+
+	want := "test_key value=1 1445532780000000000\n" +
+		"test_key,a=b,c=d\\ e value=4 1445532780000000000\n" +
+		"my\\ key value=-2.5 1445532780000000000\n" +
+		"test_key value=\"a \\\"b\\\"\" 1445532780000000000\n" +
+		"test_key value=3 1445532780000000000\n" +
+		"test_key value=4 1445532780000000000\n" +
+		"test_key value=1 1000000000000000000\n"
+	testOutput(t, want, func(c *Client) {
+		c.Increment(testKey)
+		c.Clone(Tags("a", "b", "c", "d e"), SampleRate(0.5)).Count(testKey, 2)
+		c.Gauge("my key", -2.5)
+		c.Unique(testKey, `a "b"`)
+		c.Send(&Metric{Type: TIMINGS, Bucket: testKey, Value: []float64{3, 4}})
+		c.Increment(testKey, Timestamp(time.Unix(1e9, 0)))
+	}, Network("tcp"), TagsFormat(Datadog), WireFormat(&InfluxDBSerializer{TagFormat: Datadog}))
+}
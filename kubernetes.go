@@ -0,0 +1,78 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// Stubbed out for testing.
+	podinfoDir        = "/etc/podinfo"
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// kubernetesTags are the tags added by KubernetesTags, with the environment
+// variables and the downward API files they are read from, by order of
+// preference.
+var kubernetesTags = []struct {
+	tag  string
+	envs []string
+	file string
+}{
+	{"pod_name", []string{"POD_NAME", "KUBERNETES_POD_NAME"}, "name"},
+	{"kube_namespace", []string{"POD_NAMESPACE", "KUBERNETES_NAMESPACE"}, "namespace"},
+	{"kube_node", []string{"NODE_NAME", "KUBERNETES_NODE_NAME"}, "nodename"},
+}
+
+// KubernetesTags appends the pod_name, kube_namespace and kube_node tags to
+// the tags sent with every metrics when the process runs in a Kubernetes pod.
+// The values are read from the first of these sources:
+//   - the POD_NAME, POD_NAMESPACE and NODE_NAME environment variables, or the
+//     same names prefixed with KUBERNETES_ instead of POD_ and NODE_,
+//     usually set with the downward API,
+//   - the name, namespace and nodename files of a downward API volume mounted
+//     on /etc/podinfo,
+//   - for the namespace, the namespace file of the service account,
+//   - for the pod name, the hostname, which is the pod name by default.
+//
+// The tags which cannot be found are not added.
+func KubernetesTags() Option {
+	return Option(func(c *config) {
+		inPod := os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+		for _, t := range kubernetesTags {
+			v := lookupKubernetes(t.envs, filepath.Join(podinfoDir, t.file))
+			if v == "" && t.tag == "kube_namespace" {
+				v = readTrimmed(filepath.Join(serviceAccountDir, "namespace"))
+			}
+			if v == "" && t.tag == "pod_name" && inPod {
+				v, _ = hostname()
+			}
+			if v != "" {
+				c.Client.Tags = append(c.Client.Tags, tag{K: t.tag, V: v})
+			}
+		}
+	})
+}
+
+// lookupKubernetes returns the value of the first environment variable set
+// among envs, or else the content of the file.
+func lookupKubernetes(envs []string, file string) string {
+	for _, env := range envs {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return readTrimmed(file)
+}
+
+// readTrimmed returns the content of the file without the surrounding spaces,
+// or an empty string if it cannot be read.
+func readTrimmed(file string) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
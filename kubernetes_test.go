@@ -0,0 +1,52 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKubernetesTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "nodename"), []byte("node-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "namespace"), []byte("default\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	podinfoDir = dir
+	serviceAccountDir = filepath.Join(dir, "serviceaccount")
+	hostname = func() (string, error) { return "web-7d4b9-xk2p", nil }
+	defer func() {
+		podinfoDir = "/etc/podinfo"
+		serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+		hostname = os.Hostname
+	}()
+	defer setenv(t, map[string]string{
+		"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+		"POD_NAMESPACE":           "shop",
+	})()
+
+	testOutput(t, "test_key:1|c|#pod_name:web-7d4b9-xk2p,kube_namespace:shop,kube_node:node-1", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), KubernetesTags())
+}
+
+func TestKubernetesTagsOutsidePod(t *testing.T) {
+	podinfoDir = "/nonexistent"
+	serviceAccountDir = "/nonexistent"
+	defer func() {
+		podinfoDir = "/etc/podinfo"
+		serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	}()
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+
+	testOutput(t, "test_key:1|c", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), KubernetesTags())
+}
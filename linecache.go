@@ -0,0 +1,44 @@
+package statsd
+
+// A lineKey identifies the series whose lines are cached by a
+// StatsDSerializer.
+type lineKey struct {
+	prefix, bucket, tags string
+	typ                  Type
+	customType           string
+	rate                 float32
+}
+
+// A cachedLine is the line of a series without its value: the value goes at
+// split, between the name and the type of the metric.
+type cachedLine struct {
+	b     []byte
+	split int
+}
+
+// line returns the cached line of the series of m. The line is rendered and
+// cached if it is not in the cache yet. The cache is cleared when it holds
+// CacheSize lines.
+func (s *StatsDSerializer) line(m *Metric) cachedLine {
+	k := lineKey{
+		prefix:     m.Prefix,
+		bucket:     m.Bucket,
+		tags:       m.Tags,
+		typ:        m.Type,
+		customType: m.CustomType,
+		rate:       m.Rate,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.cache[k]; ok {
+		return l
+	}
+	if s.cache == nil || len(s.cache) >= s.CacheSize {
+		s.cache = make(map[lineKey]cachedLine, s.CacheSize)
+	}
+	b := s.appendName(nil, m)
+	l := cachedLine{split: len(b)}
+	l.b = s.appendType(b, m)
+	s.cache[k] = l
+	return l
+}
@@ -0,0 +1,182 @@
+package statsd
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"sync"
+)
+
+// A Listener receives metrics from StatsD clients, e.g. to build a sidecar
+// aggregator or to test the metrics sent by a Client.
+type Listener struct {
+	handler func(line string)
+	l       net.Listener
+	pc      net.PacketConn
+	path    string
+	packets bool
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// Listen starts a Listener receiving metrics on the given network ("udp",
+// "tcp", "unix", "unixgram", etc.) and address, and calling handler with each
+// metric received. The handler is called from the goroutines of the Listener,
+// concurrently for stream networks.
+//
+// With the "systemd" network, the Listener receives the metrics on a socket
+// inherited through systemd socket activation (LISTEN_FDS): addr is the
+// FileDescriptorName of the socket, or empty to use the first one.
+//
+// The lines which are not metrics, like the DogStatsD events, are skipped: use
+// ListenLines and Parse to handle them.
+func Listen(network, addr string, handler func(*Metric)) (*Listener, error) {
+	return ListenLines(network, addr, func(line string) {
+		if m, err := ParseMetric(line); err == nil {
+			handler(m)
+		}
+	})
+}
+
+// ListenLines starts a Listener like Listen, except that handler is called
+// with each line received, without parsing it.
+func ListenLines(network, addr string, handler func(line string)) (*Listener, error) {
+	l := &Listener{
+		handler: handler,
+		conns:   map[net.Conn]struct{}{},
+	}
+	var err error
+	switch network {
+	case "systemd":
+		l.pc, l.l, err = activatedSocket(addr, func(bool) bool { return true })
+		if l.l != nil {
+			l.packets = l.l.Addr().Network() == "unixpacket"
+		}
+	case "udp", "udp4", "udp6", "unixgram":
+		l.pc, err = net.ListenPacket(network, addr)
+		if network == "unixgram" {
+			l.path = addr
+		}
+	default:
+		l.l, err = net.Listen(network, addr)
+		l.packets = network == "unixpacket"
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.wg.Add(1)
+	if l.l != nil {
+		go l.accept()
+	} else {
+		go l.readPackets()
+	}
+	return l, nil
+}
+
+// Addr returns the address the Listener listens on.
+func (l *Listener) Addr() net.Addr {
+	if l.l != nil {
+		return l.l.Addr()
+	}
+	return l.pc.LocalAddr()
+}
+
+// Close stops the Listener. The handler is not called anymore once Close
+// returns.
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	for c := range l.conns {
+		c.Close()
+	}
+	l.mu.Unlock()
+
+	var err error
+	if l.l != nil {
+		err = l.l.Close()
+	} else {
+		err = l.pc.Close()
+	}
+	l.wg.Wait()
+	if l.path != "" {
+		os.Remove(l.path)
+	}
+	return err
+}
+
+// handle calls the handler with each line of the payload p.
+func (l *Listener) handle(p []byte) {
+	for len(p) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			line, p = p, nil
+		}
+		if len(line) > 0 {
+			l.handler(string(line))
+		}
+	}
+}
+
+func (l *Listener) readPackets() {
+	defer l.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		l.handle(buf[:n])
+	}
+}
+
+func (l *Listener) accept() {
+	defer l.wg.Done()
+	for {
+		c, err := l.l.Accept()
+		if err != nil {
+			return
+		}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			c.Close()
+			return
+		}
+		l.conns[c] = struct{}{}
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go l.readStream(c)
+	}
+}
+
+func (l *Listener) readStream(c net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, c)
+		l.mu.Unlock()
+		c.Close()
+	}()
+	if l.packets {
+		// The messages are not delimited by new lines.
+		buf := make([]byte, 65536)
+		for {
+			n, err := c.Read(buf)
+			if err != nil {
+				return
+			}
+			l.handle(buf[:n])
+		}
+	}
+	sc := bufio.NewScanner(c)
+	for sc.Scan() {
+		l.handle(sc.Bytes())
+	}
+}
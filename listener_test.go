@@ -0,0 +1,84 @@
+package statsd
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListen(t *testing.T) {
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []*Metric
+			received := make(chan struct{}, 10)
+			l, err := Listen(network, "127.0.0.1:0", func(m *Metric) {
+				mu.Lock()
+				got = append(got, m)
+				mu.Unlock()
+				received <- struct{}{}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			c, err := New(Network(network), Address(l.Addr().String()), FlushPeriod(0), TagsFormat(Datadog), Tags("a", "b"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Increment(testKey)
+			c.Gauge(testKey, 2.5)
+			c.Close()
+			for i := 0; i < 2; i++ {
+				select {
+				case <-received:
+				case <-time.After(time.Second):
+					t.Fatal("the metrics were not received")
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			want := []*Metric{
+				{Type: COUNT, Bucket: testKey, Value: 1.0, Rate: 1, Tags: "|#a:b", tagFormat: Datadog},
+				{Type: GAUGE, Bucket: testKey, Value: 2.5, Rate: 1, Tags: "|#a:b", tagFormat: Datadog},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Invalid metrics, got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestListenLines(t *testing.T) {
+	lines := make(chan string, 10)
+	l, err := ListenLines("udp", "127.0.0.1:0", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("_sc|db|0\ntest_key:1|c\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"_sc|db|0", "test_key:1|c"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("Invalid line, got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q was not received", want)
+		}
+	}
+}
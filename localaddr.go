@@ -0,0 +1,74 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// dialFrom dials addr on the network from the local address local, which is
+// either an address, an IP or the name of a network interface.
+func dialFrom(network, local, addr string, timeout time.Duration) (net.Conn, error) {
+	la, err := resolveLocalAddr(network, local)
+	if err != nil {
+		return nil, err
+	}
+	d := net.Dialer{Timeout: timeout, LocalAddr: la}
+	return d.Dial(network, addr)
+}
+
+// resolveLocalAddr resolves the local address local on the network. If the host
+// of local is the name of a network interface, the first address of the
+// interface matching the network is used.
+func resolveLocalAddr(network, local string) (net.Addr, error) {
+	switch network {
+	case "unix", "unixgram", "unixpacket":
+		return net.ResolveUnixAddr(network, local)
+	}
+	host, port := local, "0"
+	if h, p, err := net.SplitHostPort(local); err == nil {
+		host, port = h, p
+	}
+	if net.ParseIP(host) == nil {
+		if ifi, err := net.InterfaceByName(host); err == nil {
+			ip, err := interfaceIP(ifi, network)
+			if err != nil {
+				return nil, err
+			}
+			host = ip.String()
+		}
+	}
+	hostport := net.JoinHostPort(host, port)
+	if strings.HasPrefix(network, "udp") {
+		return net.ResolveUDPAddr(network, hostport)
+	}
+	return net.ResolveTCPAddr(network, hostport)
+}
+
+// interfaceIP returns the first IP of the network interface ifi usable on the
+// network, preferring IPv4 unless the network is IPv6 only.
+func interfaceIP(ifi *net.Interface, network string) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var v6 net.IP
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip := ipnet.IP.To4(); ip != nil {
+			if !strings.HasSuffix(network, "6") {
+				return ip, nil
+			}
+		} else if v6 == nil && !ipnet.IP.IsLinkLocalUnicast() {
+			v6 = ipnet.IP
+		}
+	}
+	if v6 != nil && !strings.HasSuffix(network, "4") {
+		return v6, nil
+	}
+	return nil, fmt.Errorf("statsd: no %s address on interface %s", network, ifi.Name)
+}
@@ -0,0 +1,46 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLocalAddr(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := New(Address(l.LocalAddr().String()), FlushPeriod(0), LocalAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+
+	got := c.conn.w.(net.Conn).LocalAddr().(*net.UDPAddr).IP
+	if !got.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("local IP = %v, want 127.0.0.1", got)
+	}
+}
+
+func TestResolveLocalAddrInterface(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagLoopback == 0 || ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		a, err := resolveLocalAddr("udp4", ifi.Name)
+		if err != nil {
+			t.Fatalf("resolveLocalAddr(%q) = %v", ifi.Name, err)
+		}
+		if ip := a.(*net.UDPAddr).IP; !ip.IsLoopback() {
+			t.Errorf("resolveLocalAddr(%q) = %v, want a loopback address", ifi.Name, a)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
@@ -0,0 +1,15 @@
+package statsd
+
+// A Logger logs the internal events of a Client, e.g. reconnections, dropped
+// metrics and configuration warnings. The *log.Logger of the standard library
+// is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf logs an internal event if the connection has a Logger.
+func (c *conn) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf("statsd: "+format, v...)
+	}
+}
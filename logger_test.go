@@ -0,0 +1,53 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type testLogger []string
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestEventLogger(t *testing.T) {
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	var l testLogger
+	c, _ := New(FlushPeriod(0), EventLogger(&l), Tags("key", "value"), OverflowQueue(1))
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	down = false
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	c.Close()
+
+	want := []string{
+		"statsd: the tags are ignored because no TagsFormat is set",
+		"statsd: dropped 1 metrics which could not be sent to :8125",
+		"statsd: reconnected to udp :8125",
+	}
+	if len(l) != len(want) {
+		t.Fatalf("logged %q, want %q", l, want)
+	}
+	for i := range want {
+		if l[i] != want[i] {
+			t.Errorf("logged %q, want %q", l[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMaxConnAge(t *testing.T) {
+	var conns []*testBuffer
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		conns = append(conns, &testBuffer{})
+		return conns[len(conns)-1], nil
+	}
+	date := testDate
+	now = func() time.Time { return date }
+	defer func() {
+		dialTimeout = net.DialTimeout
+		now = time.Now
+	}()
+
+	c, err := New(FlushPeriod(0), MaxConnAge(time.Minute), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush := func(n int) {
+		c.Count(testKey, n)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+	}
+	flush(1)
+	date = date.Add(time.Minute - time.Second)
+	flush(2)
+	if len(conns) != 1 {
+		t.Fatalf("%d connections dialed, want 1", len(conns))
+	}
+	date = date.Add(time.Second)
+	flush(3)
+	if len(conns) != 2 {
+		t.Fatalf("%d connections dialed, want 2", len(conns))
+	}
+	if got, want := conns[0].buf.String(), "test_key:1|ctest_key:2|c"; got != want {
+		t.Errorf("Invalid output of the first connection, got %q, want %q", got, want)
+	}
+	if got, want := conns[1].buf.String(), "test_key:3|c"; got != want {
+		t.Errorf("Invalid output of the second connection, got %q, want %q", got, want)
+	}
+}
@@ -1,5 +1,10 @@
 package statsd
 
+import (
+	"sync"
+	"time"
+)
+
 type Type uint8
 
 const (
@@ -7,6 +12,10 @@ const (
 	GAUGE
 	TIMINGS
 	HISTOGRAM
+	SET
+	// CUSTOM is the type of the metrics with the type suffix of their
+	// CustomType field, for the types which are not supported natively.
+	CUSTOM
 )
 
 var (
@@ -17,8 +26,107 @@ var (
 	SET_S       = "|s"
 )
 
+// suffix returns the type suffix of the wire format, e.g. "|c" for COUNT.
+func (t Type) suffix() string {
+	switch t {
+	case COUNT:
+		return COUNT_S
+	case GAUGE:
+		return GAUGE_S
+	case TIMINGS:
+		return TIMINGS_S
+	case HISTOGRAM:
+		return HISTOGRAM_S
+	case SET:
+		return SET_S
+	}
+	return ""
+}
+
+// A Metric is a single StatsD metric. It can be built ahead of time and sent
+// with Client.Send.
 type Metric struct {
 	Type   Type
+	Prefix string
 	Bucket string
-	Value  interface{}
+	// Value is a number, a string for sets or a []float64 of packed values.
+	Value interface{}
+	Rate  float32
+	// Tags are the tags rendered in the TagFormat of the Client.
+	Tags string
+	// Timestamp is the time the metric was measured at. If it is zero, the
+	// time of arrival is used by the server.
+	Timestamp time.Time
+	// CustomType is the type suffix of the CUSTOM metrics without the
+	// leading "|", e.g. "kv".
+	CustomType string
+	// Priority is the priority of the metric. If it is zero, the priority of
+	// the Client is used.
+	Priority Priority
+	// Delta is true for the gauges whose Value is a change of the current
+	// value, sent with its sign, e.g. "+5|g", instead of the new value.
+	Delta bool
+
+	tagFormat TagFormat
+}
+
+// suffix returns the type suffix of the wire format of the metric.
+func (m *Metric) suffix() string {
+	if m.Type == CUSTOM {
+		return "|" + m.CustomType
+	}
+	return m.Type.suffix()
+}
+
+// Name returns the full name of the metric, i.e. its bucket with the prefix.
+func (m *Metric) Name() string {
+	return m.Prefix + m.Bucket
+}
+
+// AddTag appends a tag to the tags of the metric, rendered in the TagFormat
+// of the Client sending it. It does nothing if the Client has no TagFormat.
+func (m *Metric) AddTag(k, v string) {
+	switch m.tagFormat {
+	case InfluxDB:
+		m.Tags += "," + k + "=" + v
+	case Datadog:
+		if m.Tags == "" {
+			m.Tags = "|#" + k + ":" + v
+		} else {
+			m.Tags += "," + k + ":" + v
+		}
+	}
+}
+
+// A MetricOption represents an option for a single metric. It must be used as
+// an argument to the metric methods of a Client (Count, Gauge, Timing, etc.).
+type MetricOption func(*Metric)
+
+// Timestamp sets the time the metric was measured at, so that delayed or
+// backfilled metrics carry their true timestamp instead of their time of
+// arrival.
+//
+// It uses the DogStatsD "|T<unix timestamp>" extension, so it must only be
+// used with servers that support it. The precision is one second.
+func Timestamp(t time.Time) MetricOption {
+	return MetricOption(func(m *Metric) {
+		m.Timestamp = t
+	})
+}
+
+var metricPool = sync.Pool{
+	New: func() interface{} { return new(Metric) },
+}
+
+// GetMetric returns a zeroed Metric from a pool. The Metric can be given back
+// to the pool with Release once it has been sent.
+func GetMetric() *Metric {
+	return metricPool.Get().(*Metric)
+}
+
+// Release zeroes the Metric and puts it back in the pool used by GetMetric.
+// The Metric must not be used afterward.
+func (m *Metric) Release() {
+	*m = Metric{}
+	metricPool.Put(m)
 }
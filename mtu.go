@@ -0,0 +1,78 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	// Worst-case scenario:
+	// IPv6 Header + UDP Header = 40 + 8 = 48
+	udpOverhead = 48
+	// IPv6 Header + TCP Header = 40 + 20 = 60
+	tcpOverhead = 60
+	// maxUDPPayload is the biggest payload a UDP packet can carry.
+	maxUDPPayload = 65507
+)
+
+// defaultPacketSize returns the maximum packet size used when it has not been
+// set with the MaxPacketSize option.
+func defaultPacketSize(conf connConfig) int {
+	mtu := conf.MTU
+	if mtu == 0 && conf.DetectMTU {
+		mtu, _ = detectMTU(conf.Network, conf.Addr)
+	}
+	if mtu > 0 {
+		return packetSize(conf.Network, mtu)
+	}
+	// Ethernet MTU - IPv6 Header - TCP Header = 1500 - 40 - 20 = 1440
+	if strings.HasPrefix(conf.Network, "udp") {
+		return 1000
+	}
+	return 1440
+}
+
+// packetSize returns the biggest payload which can be sent on the network
+// without IP fragmentation on a link with the given MTU.
+func packetSize(network string, mtu int) int {
+	if !strings.HasPrefix(network, "udp") {
+		return mtu - tcpOverhead
+	}
+	if n := mtu - udpOverhead; n < maxUDPPayload {
+		return n
+	}
+	return maxUDPPayload
+}
+
+// detectMTU returns the MTU of the interface used to reach addr.
+func detectMTU(network, addr string) (int, error) {
+	if !strings.HasPrefix(network, "udp") && !strings.HasPrefix(network, "tcp") {
+		return 0, errors.New("statsd: cannot detect the MTU of network " + network)
+	}
+	// Connecting a UDP socket does not send anything but selects the local
+	// address of the egress interface.
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return iface.MTU, nil
+			}
+		}
+	}
+	return 0, errors.New("statsd: no interface found for local address " + ip.String())
+}
@@ -0,0 +1,46 @@
+package statsd
+
+import "testing"
+
+func TestPacketSize(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want int
+	}{
+		{nil, 1000},
+		{[]Option{Network("tcp")}, 1440},
+		{[]Option{MTU(9000)}, 8952},
+		{[]Option{MTU(1500), Network("tcp")}, 1440},
+		{[]Option{MTU(65536)}, 65488},
+		{[]Option{MTU(1 << 17)}, 65507},
+		{[]Option{MTU(9000), MaxPacketSize(512)}, 512},
+		{[]Option{MaxPacketSize(0)}, 0},
+	}
+	for _, test := range tests {
+		testClient(t, func(c *Client) {
+			if got := c.conn.maxPacketSize; got != test.want {
+				t.Errorf("maxPacketSize = %d, want %d", got, test.want)
+			}
+		}, test.opts...)
+	}
+}
+
+func TestDetectMTU(t *testing.T) {
+	mtu, err := detectMTU("udp", "127.0.0.1:8125")
+	if err != nil {
+		t.Fatalf("detectMTU: %v", err)
+	}
+	if mtu <= 0 {
+		t.Errorf("detectMTU() = %d, want a positive MTU", mtu)
+	}
+
+	testClient(t, func(c *Client) {
+		if got, want := c.conn.maxPacketSize, packetSize("udp", mtu); got != want {
+			t.Errorf("maxPacketSize = %d, want %d", got, want)
+		}
+	}, Address("127.0.0.1:8125"), DetectMTU(true))
+
+	if _, err := detectMTU("unix", "/tmp/statsd.sock"); err == nil {
+		t.Error("detectMTU should fail on unix sockets")
+	}
+}
@@ -0,0 +1,123 @@
+package statsd
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A Convention is a naming convention of the buckets.
+type Convention uint8
+
+// The naming conventions of the buckets. The words of the buckets are split
+// at the characters which are neither letters nor digits and at the case
+// changes, e.g. "HTTPRequest.latency-ms" has the words "http", "request",
+// "latency" and "ms".
+const (
+	// SnakeCase joins the words of each dot-separated segment of the bucket
+	// with underscores, e.g. "http_request.latency_ms".
+	SnakeCase Convention = iota + 1
+	// CamelCase joins the words of each dot-separated segment of the bucket
+	// in camel case, e.g. "httpRequest.latencyMs".
+	CamelCase
+	// Dotted joins all the words of the bucket with dots, e.g.
+	// "http.request.latency.ms".
+	Dotted
+)
+
+// maxNamingCache is the maximum number of bucket names cached by
+// NamingConvention. The cache is cleared when it is full.
+const maxNamingCache = 10000
+
+// NamingConvention normalizes the bucket names to the Convention nc, so that
+// teams with mixed conventions converge without auditing every call site. All
+// the words are lowercased, except the first letters joined in CamelCase.
+//
+// The conventions are run as a metric hook, with the hooks and rules in the
+// order they have been added. The prefix of the Client is not normalized.
+func NamingConvention(nc Convention) Option {
+	var mu sync.Mutex
+	cache := make(map[string]string)
+	hook := MetricHook(func(m *Metric) bool {
+		mu.Lock()
+		name, ok := cache[m.Bucket]
+		if !ok {
+			name = normalizeName(nc, m.Bucket)
+			if len(cache) >= maxNamingCache {
+				cache = make(map[string]string)
+			}
+			cache[m.Bucket] = name
+		}
+		mu.Unlock()
+		m.Bucket = name
+		return true
+	})
+	return Option(func(c *config) {
+		c.Client.Naming = nc
+		hook(c)
+	})
+}
+
+// normalizeName returns the bucket name following the Convention nc.
+func normalizeName(nc Convention, name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 4)
+	for i, segment := range strings.Split(name, ".") {
+		words := splitWords(segment)
+		if len(words) == 0 {
+			continue
+		}
+		if i > 0 && b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		for j, w := range words {
+			w = strings.ToLower(w)
+			switch {
+			case j == 0:
+			case nc == SnakeCase:
+				b.WriteByte('_')
+			case nc == Dotted:
+				b.WriteByte('.')
+			case nc == CamelCase:
+				r := []rune(w)
+				r[0] = unicode.ToUpper(r[0])
+				w = string(r)
+			}
+			b.WriteString(w)
+		}
+	}
+	return b.String()
+}
+
+// splitWords splits s at the characters which are neither letters nor digits
+// and at the case changes: before an uppercase letter following a lowercase
+// letter or a digit, and before the last uppercase letter of an acronym
+// followed by a lowercase letter, e.g. "HTTPRequest".
+func splitWords(s string) []string {
+	var words []string
+	r := []rune(s)
+	start := -1
+	for i, c := range r {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			if start >= 0 {
+				words = append(words, string(r[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := r[i-1]
+		if unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			unicode.IsUpper(prev) && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(r[start:]))
+	}
+	return words
+}
@@ -0,0 +1,30 @@
+package statsd
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name                 string
+		snake, camel, dotted string
+	}{
+		{"HTTPRequest.latencyMs", "http_request.latency_ms", "httpRequest.latencyMs", "http.request.latency.ms"},
+		{"http_request.latency-ms", "http_request.latency_ms", "httpRequest.latencyMs", "http.request.latency.ms"},
+		{"api.p99Latency", "api.p99_latency", "api.p99Latency", "api.p99.latency"},
+		{"cache..Hits ", "cache.hits", "cache.hits", "cache.hits"},
+		{"already_snake", "already_snake", "alreadySnake", "already.snake"},
+	}
+	for _, tt := range tests {
+		for nc, want := range map[Convention]string{SnakeCase: tt.snake, CamelCase: tt.camel, Dotted: tt.dotted} {
+			if got := normalizeName(nc, tt.name); got != want {
+				t.Errorf("normalizeName(%d, %q) = %q, want %q", nc, tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestNamingConvention(t *testing.T) {
+	testOutput(t, "App.http_request:1|c\nApp.http_request:2|c", func(c *Client) {
+		c.Increment("httpRequest")
+		c.Count("HTTP-request", 2)
+	}, Prefix("App"), NamingConvention(SnakeCase))
+}
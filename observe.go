@@ -0,0 +1,23 @@
+package statsd
+
+// Observe calls fn and sends the time it took as a timing value to bucket,
+// then increments bucket.success if fn returned nil or bucket.error
+// otherwise. It returns the error returned by fn.
+//
+// If fn panics, the timing and bucket.error are sent before the panic goes on.
+// The options are used for the three metrics.
+func (c *Client) Observe(bucket string, fn func() error, opts ...MetricOption) (err error) {
+	t := c.NewTiming()
+	ok := false
+	defer func() {
+		t.Send(bucket, opts...)
+		if ok && err == nil {
+			c.Increment(bucket+".success", opts...)
+		} else {
+			c.Increment(bucket+".error", opts...)
+		}
+	}()
+	err = fn()
+	ok = true
+	return err
+}
@@ -0,0 +1,39 @@
+package statsd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	clk := &fakeClock{t: testDate}
+	testErr := errors.New("test error")
+	testOutput(t, "test_key:5|ms\ntest_key.success:1|c\ntest_key:5|ms\ntest_key.error:1|c", func(c *Client) {
+		if err := c.Observe(testKey, func() error {
+			clk.Advance(5 * time.Millisecond)
+			return nil
+		}); err != nil {
+			t.Errorf("Observe() = %v, want nil", err)
+		}
+		if err := c.Observe(testKey, func() error {
+			clk.Advance(5 * time.Millisecond)
+			return testErr
+		}); err != testErr {
+			t.Errorf("Observe() = %v, want %v", err, testErr)
+		}
+	}, UseClock(clk))
+}
+
+func TestObservePanic(t *testing.T) {
+	testOutput(t, "test_key:0|ms\ntest_key.error:1|c", func(c *Client) {
+		defer func() {
+			if r := recover(); r != "test panic" {
+				t.Errorf("recover() = %v, want the panic of the function", r)
+			}
+		}()
+		c.Observe(testKey, func() error {
+			panic("test panic")
+		})
+	}, UseClock(&fakeClock{t: testDate}))
+}
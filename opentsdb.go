@@ -0,0 +1,65 @@
+package statsd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// OpenTSDBSerializer is the Serializer of the telnet protocol of OpenTSDB:
+//
+//	put <metric> <timestamp> <value> <tag>=<value> ...
+//
+// OpenTSDB has no metric types, so all the metrics are sent as values, the
+// counts being scaled by their sample rate, and the sets are skipped. OpenTSDB
+// rejects the metrics without tags, so at least one tag must be set, e.g. with
+// the HostnameTag option. The spaces, which delimit the fields, are replaced
+// with underscores in the names and tags. Use it with the Network("tcp")
+// option and the address of a TSD, usually ":4242".
+type OpenTSDBSerializer struct {
+	// TagFormat is the format the tags of the metrics have been rendered in.
+	TagFormat TagFormat
+}
+
+var openTSDBEscaper = strings.NewReplacer(" ", "_")
+
+// AppendMetric implements the Serializer interface.
+func (s *OpenTSDBSerializer) AppendMetric(buf []byte, m *Metric) []byte {
+	if m.Type == SET {
+		return buf
+	}
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = now()
+	}
+	if values, ok := m.Value.([]float64); ok {
+		for _, v := range values {
+			buf = s.appendLine(buf, m, v, ts.Unix())
+		}
+		return buf
+	}
+	return s.appendLine(buf, m, m.Value, ts.Unix())
+}
+
+func (s *OpenTSDBSerializer) appendLine(buf []byte, m *Metric, value interface{}, ts int64) []byte {
+	v, ok := toFloat64(value)
+	if !ok {
+		return buf
+	}
+	buf = append(buf, "put "...)
+	buf = append(buf, openTSDBEscaper.Replace(m.Prefix+m.Bucket)...)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, ts, 10)
+	buf = append(buf, ' ')
+	if m.Type == COUNT && m.Rate > 0 && m.Rate < 1 {
+		buf = strconv.AppendFloat(buf, v/float64(m.Rate), 'f', -1, 64)
+	} else {
+		buf = appendNumber(buf, value)
+	}
+	for _, t := range splitTags(s.TagFormat, m.Tags) {
+		buf = append(buf, ' ')
+		buf = append(buf, openTSDBEscaper.Replace(t.K)...)
+		buf = append(buf, '=')
+		buf = append(buf, openTSDBEscaper.Replace(t.V)...)
+	}
+	return append(buf, '\n')
+}
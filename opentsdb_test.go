@@ -0,0 +1,29 @@
+package statsd
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestOpenTSDBSerializer(t *testing.T) {
+	now = func() time.Time { return testDate }
+	defer func() { now = time.Now }()
+	randFloat = func() float32 { return 0.1 }
+	defer func() { randFloat = rand.Float32 }()
+
+	want := "put test_key 1445532780 1 host=a\n" +
+		"put test_key 1445532780 4 host=a dc=eu_west\n" +
+		"put my_key 1445532780 -2.5 host=a\n" +
+		"put test_key 1445532780 3 host=a\n" +
+		"put test_key 1445532780 4 host=a\n" +
+		"put test_key 1000000000 1 host=a\n"
+	testOutput(t, want, func(c *Client) {
+		c.Increment(testKey)
+		c.Clone(Tags("dc", "eu west"), SampleRate(0.5)).Count(testKey, 2)
+		c.Gauge("my key", -2.5)
+		c.Unique(testKey, "skipped")
+		c.Send(&Metric{Type: TIMINGS, Bucket: testKey, Value: []float64{3, 4}})
+		c.Increment(testKey, Timestamp(time.Unix(1e9, 0)))
+	}, Network("tcp"), TagsFormat(Datadog), Tags("host", "a"), WireFormat(&OpenTSDBSerializer{TagFormat: Datadog}))
+}
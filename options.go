@@ -2,6 +2,9 @@ package statsd
 
 import (
 	"bytes"
+	"io"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -9,23 +12,107 @@ import (
 type config struct {
 	Conn   connConfig
 	Client clientConfig
+
+	// err is the first error encountered while applying the options.
+	err error
+	// envTags is set once the tags of STATSD_TAGS have been added, so that
+	// EnvTags and NewFromEnv do not add them twice.
+	envTags bool
+}
+
+// setErr records err unless an error has already been recorded.
+func (c *config) setErr(err error) {
+	if c.err == nil {
+		c.err = err
+	}
 }
 
 type clientConfig struct {
 	Muted  bool
 	Rate   float32
+	Rates  map[Type]float32
 	Prefix string
 	Tags   []tag
+	Hooks  []func(*Metric) bool
+	// Priority is the priority of the metrics sent by the Client.
+	Priority Priority
+	// TimingUnit is the unit of the durations sent as timing values.
+	TimingUnit TimeUnit
+	// Naming is the last naming convention set, only kept to be validated.
+	Naming Convention
+
+	EntityIDTag bool
 }
 
 type connConfig struct {
-	Addr          string
-	ErrorHandler  func(error)
-	FlushPeriod   time.Duration
-	Timeout       time.Duration
-	MaxPacketSize int
-	Network       string
-	TagFormat     TagFormat
+	Addr              string
+	ErrorHandler      func(error)
+	OnConnect         func(addr string)
+	OnDisconnect      func(addr string, err error)
+	OnAutoMute        func(addr string, muted bool)
+	Logger            Logger
+	FlushPeriod       time.Duration
+	FlushJitter       float64
+	Timeout           time.Duration
+	DialTimeout       time.Duration
+	DialTimeoutSet    bool
+	WriteTimeout      time.Duration
+	WriteTimeoutSet   bool
+	MaxConnAge        time.Duration
+	MuteAfter         int
+	ProbePeriod       time.Duration
+	Clock             Clock
+	MaxPacketSize     int
+	InitialBufferSize int
+	TopSeries         int
+	TopSeriesWindow   time.Duration
+	MaxPacketSizeSet  bool
+	MTU               int
+	DetectMTU         bool
+	Network           string
+	LazyConnect       bool
+	UDPCheck          bool
+	ConnectRetry      time.Duration
+	LengthPrefix      bool
+	TagFormat         TagFormat
+	SignedGauges      bool
+	LineCacheSize     int
+	Serializer        Serializer
+	DebugWriter       io.Writer
+	DryRun            io.Writer
+	Conn              WriteCloserWithTimeout
+	Redial            func() (WriteCloserWithTimeout, error)
+	SocketActivation  bool
+	SocketName        string
+	SpoolPath         string
+	SpoolSize         int64
+	BufferTTL         time.Duration
+	QueueSize         int
+	SortTags          bool
+	TagPolicy         TagPolicy
+	TCPNoDelay        bool
+	SendBufferSize    int
+	TrafficClass      int
+	LocalAddr         string
+	FallbackNetwork   string
+	FallbackAddr      string
+	Failover          []string
+	Shards            []string
+	SRV               string
+	SRVRefresh        time.Duration
+	PollPeriod        time.Duration
+	TimingSummaries   bool
+	Percentiles       []float64
+	SketchAccuracy    float64
+	SketchQuantiles   []float64
+	PackTimings       bool
+	TypeBuffers       bool
+	TelemetryInterval time.Duration
+	TelemetryPrefix   string
+	Routes            map[Type]string
+	Record            bool
+	ContainerID       string
+	DetectContainerID bool
 }
 
 // An Option represents an option for a Client. It must be used as an
@@ -41,7 +128,8 @@ func Address(addr string) Option {
 	})
 }
 
-// Timeout for connect/write to the StatsD daemon.
+// Timeout for connect/write to the StatsD daemon. DialTimeout and WriteTimeout
+// take precedence over it.
 //
 // By default, "5s" is used. This option is ignored in Client.Clone().
 func Timeout(timeout time.Duration) Option {
@@ -50,6 +138,116 @@ func Timeout(timeout time.Duration) Option {
 	})
 }
 
+// DialTimeout sets the timeout for connecting to the StatsD daemon, e.g. to
+// give more time to TCP connections over a WAN. A zero timeout means no
+// timeout.
+//
+// By default, the Timeout is used. This option is ignored in Client.Clone().
+func DialTimeout(timeout time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.DialTimeout = timeout
+		c.Conn.DialTimeoutSet = true
+	})
+}
+
+// WriteTimeout sets the timeout for writing to the StatsD daemon, e.g. to keep
+// the flushes short even when the daemon is slow. A zero timeout means no
+// timeout.
+//
+// By default, the Timeout is used. This option is ignored in Client.Clone().
+func WriteTimeout(timeout time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.WriteTimeout = timeout
+		c.Conn.WriteTimeoutSet = true
+	})
+}
+
+// MaxConnAge sets the maximum age of the connections to the StatsD daemon: a
+// connection older than d is closed and dialed again before the next flush, so
+// that long-lived TCP connections are spread across the backends of a load
+// balancer instead of sticking to one of them.
+//
+// By default, the connections are kept as long as they work. This option is
+// ignored in Client.Clone().
+func MaxConnAge(d time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.MaxConnAge = d
+	})
+}
+
+// UseClock sets the Clock of the Client, which is used for the Timings and to
+// schedule the flushes and the other periodic tasks. The deadlines of the
+// network operations still use the system clock.
+//
+// By default, the system clock is used. This option is ignored in
+// Client.Clone().
+func UseClock(clk Clock) Option {
+	return Option(func(c *config) {
+		c.Conn.Clock = clk
+	})
+}
+
+// OnConnect sets the function called with the address of the StatsD daemon
+// every time the Client connects to it, e.g. to flip a health flag. It is
+// called with the connection locked, so it must not use the Client.
+//
+// This option is ignored in Client.Clone().
+func OnConnect(f func(addr string)) Option {
+	return Option(func(c *config) {
+		c.Conn.OnConnect = f
+	})
+}
+
+// OnDisconnect sets the function called with the address of the StatsD daemon
+// every time the Client disconnects from it, with the error which caused the
+// disconnection or nil if the Client is closed. It is called with the
+// connection locked, so it must not use the Client.
+//
+// This option is ignored in Client.Clone().
+func OnDisconnect(f func(addr string, err error)) Option {
+	return Option(func(c *config) {
+		c.Conn.OnDisconnect = f
+	})
+}
+
+// AutoMute makes the Client mute itself after failures consecutive failed
+// flushes, e.g. because the StatsD daemon is down for a while. A muted Client
+// drops the metrics, which are reported as dropped by Client.Stats(), and
+// tries to connect every probe period instead of at every flush. It sends the
+// metrics again as soon as it connects.
+//
+// The transitions are reported to the function set by OnAutoMute and counted
+// by the AutoMutes statistic. This option is ignored in Client.Clone().
+func AutoMute(failures int, probe time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.MuteAfter = failures
+		c.Conn.ProbePeriod = probe
+	})
+}
+
+// OnAutoMute sets the function called with the address of the StatsD daemon
+// every time the AutoMute option mutes or unmutes the Client. It is called
+// with the connection locked, so it must not use the Client.
+//
+// This option is ignored in Client.Clone().
+func OnAutoMute(f func(addr string, muted bool)) Option {
+	return Option(func(c *config) {
+		c.Conn.OnAutoMute = f
+	})
+}
+
+// EventLogger sets the Logger of the internal events of the Client, like
+// reconnections, failovers, dropped metrics and configuration warnings. Unlike
+// the ErrorHandler, it is meant to get these events in the application logs.
+//
+// By default, the events are not logged. This option is ignored in
+// Client.Clone().
+func EventLogger(l Logger) Option {
+	return Option(func(c *config) {
+		c.Conn.Logger = l
+	})
+}
+
 // ErrorHandler sets the function called when an error happens when sending
 // metrics (e.g. the StatsD daemon is not listening anymore).
 //
@@ -73,13 +271,161 @@ func FlushPeriod(p time.Duration) Option {
 	})
 }
 
+// FlushJitter randomizes every flush period by up to the given fraction of
+// the period, e.g. with a flush period of 100ms and a jitter of 0.2, the
+// buffer is flushed every 80ms to 120ms. It prevents many Clients created at
+// the same time from flushing simultaneously and sending synchronized bursts
+// of packets to a shared StatsD daemon.
+//
+// The fraction must be between 0 and 1. By default, there is no jitter. This
+// option is ignored in Client.Clone().
+func FlushJitter(fraction float64) Option {
+	return Option(func(c *config) {
+		c.Conn.FlushJitter = fraction
+	})
+}
+
+// PollPeriod sets the period at which the functions registered by
+// Client.GaugeFunc are called.
+//
+// By default, they are called at every flush period. This option is ignored
+// in Client.Clone().
+func PollPeriod(d time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.PollPeriod = d
+	})
+}
+
+// TimingSummaries makes the Client aggregate the timings over each flush
+// period instead of sending every value, for backends like plain Graphite
+// without a StatsD daemon. At the end of each period, the timings of every
+// bucket and tags are sent as:
+//   - bucket.count, a count of the values, scaled by their sample rate,
+//   - bucket.min, bucket.max, bucket.sum and bucket.avg gauges,
+//   - a gauge for each of the given percentiles, e.g. bucket.p95 for 95 and
+//     bucket.p99_9 for 99.9.
+//
+// The summaries of the current period are also sent by Client.Flush() and
+// Client.Close(). This option is ignored in Client.Clone().
+func TimingSummaries(percentiles ...float64) Option {
+	return Option(func(c *config) {
+		c.Conn.TimingSummaries = true
+		c.Conn.Percentiles = percentiles
+	})
+}
+
+// Sketches makes the Client accumulate the histograms of each bucket and tags
+// in a DDSketch over each flush period instead of sending every value, for
+// backends accepting pre-aggregated distributions. The memory used by a sketch
+// only grows with the logarithm of the range of its values. At the end of each
+// period, the histograms are sent as with the TimingSummaries option, the
+// quantiles having the given relative accuracy, e.g. 0.01 for 1%.
+//
+// This option is ignored in Client.Clone().
+func Sketches(accuracy float64, percentiles ...float64) Option {
+	return Option(func(c *config) {
+		c.Conn.SketchAccuracy = accuracy
+		c.Conn.SketchQuantiles = percentiles
+	})
+}
+
+// PackTimings makes the Client send the timings of each bucket, tags and
+// sample rate accumulated over a flush period in a single line using the
+// packed value syntax of DogStatsD, e.g. "bucket:12:7:3|ms|@0.5". It
+// significantly reduces the bytes sent for hot timers. The timings with a
+// timestamp are not packed. It has no effect with TimingSummaries.
+//
+// This option is ignored in Client.Clone().
+func PackTimings() Option {
+	return Option(func(c *config) {
+		c.Conn.PackTimings = true
+	})
+}
+
+// TypeBuffers makes the Client buffer the metrics of each type separately
+// until the flush, so that the payloads hold runs of lines of the same type
+// instead of interleaved lines, e.g. for servers processing the metrics of
+// each type in batches. The buffer of a type is moved to the payloads when it
+// is full. The lines which are not sent by a metric method, e.g. by
+// Client.SendRaw, are not grouped.
+//
+// The consecutive lines of a bucket are packed into a multi-value line, e.g.
+// "b:1|ms:2|ms", unless the TagFormat is Datadog, a custom Serializer is used
+// or the metrics have a timestamp.
+//
+// This option is ignored in Client.Clone().
+func TypeBuffers() Option {
+	return Option(func(c *config) {
+		c.Conn.TypeBuffers = true
+	})
+}
+
+// ClientTelemetry makes the Client send its own statistics, see Client.Stats,
+// every interval: the packets_sent, bytes_sent, errors, metrics_dropped and
+// auto_mutes counts and the queue_bytes gauge, prefixed with prefix instead of
+// the prefix of the Client, e.g. "statsd.client.".
+//
+// Use Client.Stats to send them through another Client. This option is
+// ignored in Client.Clone().
+func ClientTelemetry(interval time.Duration, prefix string) Option {
+	return Option(func(c *config) {
+		c.Conn.TelemetryInterval = interval
+		c.Conn.TelemetryPrefix = prefix
+	})
+}
+
 // MaxPacketSize sets the maximum packet size in bytes sent by the Client.
 //
-// By default, it is 1440 to avoid IP fragmentation. This option is ignored in
-// Client.Clone().
+// By default, it is derived from the MTU set with the MTU or DetectMTU
+// options. Otherwise, it is 1000 for UDP and 1440 for the other networks to
+// avoid IP fragmentation. A zero size is valid and disables the buffering:
+// every metric is written in its own packet as soon as it is sent. A negative
+// size is rejected. This option is ignored in Client.Clone().
 func MaxPacketSize(n int) Option {
 	return Option(func(c *config) {
 		c.Conn.MaxPacketSize = n
+		c.Conn.MaxPacketSizeSet = true
+	})
+}
+
+// InitialBufferSize sets the initial capacity in bytes of the buffers the
+// metrics are written to before being flushed.
+//
+// By default, it is the maximum packet size plus 200 bytes so that a metric
+// can be appended to a full packet without growing the buffer. A smaller size
+// saves memory when the maximum packet size is big, e.g. with Unix domain
+// sockets, at the cost of growing the buffers the first times they fill up,
+// as they keep the capacity they grew to. This option is ignored in
+// Client.Clone().
+func InitialBufferSize(n int) Option {
+	return Option(func(c *config) {
+		c.Conn.InitialBufferSize = n
+	})
+}
+
+// MTU sets the MTU of the link to the StatsD daemon. The maximum packet size
+// is derived from it so that the packets are as big as possible without being
+// fragmented, e.g. 8952 bytes for UDP on a datacenter link with 9000 bytes
+// jumbo frames.
+//
+// It is ignored if the MaxPacketSize option is used. This option is ignored
+// in Client.Clone().
+func MTU(n int) Option {
+	return Option(func(c *config) {
+		c.Conn.MTU = n
+	})
+}
+
+// DetectMTU sets whether the MTU of the interface used to reach the StatsD
+// daemon is detected when the Client is created. The maximum packet size is
+// derived from it as with the MTU option. If the MTU cannot be detected, the
+// default maximum packet size is used.
+//
+// It is ignored if the MTU or MaxPacketSize options are used. This option is
+// ignored in Client.Clone().
+func DetectMTU(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.DetectMTU = b
 	})
 }
 
@@ -87,6 +433,9 @@ func MaxPacketSize(n int) Option {
 // net.Dial documentation (https://golang.org/pkg/net/#Dial) for the available
 // network options.
 //
+// On Windows, the "npipe" network can also be used to send the metrics through
+// a named pipe, e.g. `\\.\pipe\datadog-dogstatsd` for the Datadog agent.
+//
 // By default, network is udp. This option is ignored in Client.Clone().
 func Network(network string) Option {
 	return Option(func(c *config) {
@@ -94,6 +443,397 @@ func Network(network string) Option {
 	})
 }
 
+// ContainerID sets the ID of the container the Client runs in. It is sent
+// with every metric using the DogStatsD "|c:<container-id>" field so that the
+// Datadog agent can attribute the metrics to the correct container.
+//
+// This option is ignored in Client.Clone().
+func ContainerID(id string) Option {
+	return Option(func(c *config) {
+		c.Conn.ContainerID = id
+	})
+}
+
+// DetectContainerID sets whether the container ID is detected automatically
+// when it has not been set with the ContainerID option. The ID is read from the
+// DD_CONTAINER_ID environment variable or else from /proc/self/cgroup. If it
+// cannot be detected, no container ID is sent.
+//
+// By default, the container ID is not detected. This option is ignored in
+// Client.Clone().
+func DetectContainerID(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.DetectContainerID = b
+	})
+}
+
+// EntityIDTag sets whether the "dd.internal.entity_id" tag is added to the
+// tags sent with every metrics when the DD_ENTITY_ID environment variable is
+// set, as the official DogStatsD clients do. The Datadog agent uses this tag to
+// attribute the metrics to the pod the Client runs in.
+//
+// By default, the tag is not added. This option is ignored in Client.Clone().
+func EntityIDTag(b bool) Option {
+	return Option(func(c *config) {
+		c.Client.EntityIDTag = b
+	})
+}
+
+// FallbackNetwork sets the network used when the connection to the StatsD
+// daemon fails, e.g. "tcp" when the UDP port is blocked. Once the fallback
+// connection succeeds, it is used for the lifetime of the Client. The
+// fallback connection is made to the same address unless FallbackAddress is
+// used.
+//
+// By default, there is no fallback network. This option is ignored in
+// Client.Clone().
+func FallbackNetwork(network string) Option {
+	return Option(func(c *config) {
+		c.Conn.FallbackNetwork = network
+	})
+}
+
+// FallbackAddress sets the address of the StatsD daemon on the fallback
+// network set by FallbackNetwork.
+//
+// By default, the address set by Address is used. This option is ignored in
+// Client.Clone().
+func FallbackAddress(addr string) Option {
+	return Option(func(c *config) {
+		c.Conn.FallbackAddr = addr
+	})
+}
+
+// Failover sets the addresses of the StatsD daemons used when the one set by
+// Address is down. After 3 consecutive failures to send to the current address,
+// the Client moves to the next address of the list, wrapping around to the
+// primary address after the last one. Once failed over, the Client tries to
+// return to the primary address every 30 seconds.
+//
+// By default, only the address set by Address is used. This option is ignored
+// in Client.Clone().
+func Failover(addrs []string) Option {
+	return Option(func(c *config) {
+		c.Conn.Failover = addrs
+	})
+}
+
+// Shards spreads the metrics across several StatsD daemons: the name of a
+// metric is hashed on a consistent hash ring of the addresses to pick the
+// daemon it is sent to, so the metrics of a bucket, e.g. the timings of which
+// percentiles are computed, always aggregate on the same daemon. The hash ring
+// is compatible with libketama and statsd-proxy.
+//
+// The option overrides Address. This option is ignored in Client.Clone().
+func Shards(addrs []string) Option {
+	return Option(func(c *config) {
+		c.Conn.Shards = addrs
+	})
+}
+
+// SRV discovers the StatsD daemons by resolving the DNS SRV record name, e.g.
+// "_statsd._udp.example.com". The metrics are spread across the targets with
+// the highest priority as with the Shards option. The record is resolved again
+// periodically, every 30 seconds by default, see SRVRefresh. If it cannot be
+// resolved when the Client is created, Address is used until it can.
+//
+// This option overrides Shards. This option is ignored in Client.Clone().
+func SRV(name string) Option {
+	return Option(func(c *config) {
+		c.Conn.SRV = name
+	})
+}
+
+// SRVRefresh sets the period at which the SRV record set by the SRV option is
+// resolved again. The Go resolver does not expose the TTL of the records, so
+// the period should match it.
+//
+// By default, "30s" is used. This option is ignored in Client.Clone().
+func SRVRefresh(d time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.SRVRefresh = d
+	})
+}
+
+// Route sends the metrics of type t to the StatsD daemon at addr instead of
+// the one set by Address, e.g. the timings to a percentile-capable backend and
+// the counters to a cheaper one. The routed metrics are not sharded.
+//
+// This option can be used several times. This option is ignored in
+// Client.Clone().
+func Route(t Type, addr string) Option {
+	return Option(func(c *config) {
+		routes := make(map[Type]string, len(c.Conn.Routes)+1)
+		for k, v := range c.Conn.Routes {
+			routes[k] = v
+		}
+		routes[t] = addr
+		c.Conn.Routes = routes
+	})
+}
+
+// LazyConnect sets whether the connection is established on the first flush
+// instead of in New, so that New never returns a connection error. It is
+// useful for services starting before the StatsD daemon, e.g. a sidecar agent.
+//
+// The connection errors are then reported to the ErrorHandler on flushes. This
+// option is ignored in Client.Clone().
+func LazyConnect(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.LazyConnect = b
+	})
+}
+
+// ConnectRetry sets how long New retries to connect to the StatsD daemon
+// before returning the connection error. The attempts are spaced with an
+// exponential backoff, from 50ms up to 1s. It covers e.g. a local agent
+// starting a few seconds after the application.
+//
+// By default, New does not retry. This option is ignored if the LazyConnect
+// option is used and in Client.Clone().
+func ConnectRetry(maxWait time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.ConnectRetry = maxWait
+	})
+}
+
+// UDPCheck sets whether the UDP connections are checked when they are
+// established. The check writes two empty packets to get an error as soon as
+// possible if nothing is listening on the target port, which relies on ICMP
+// port unreachable messages: it can fail on networks filtering ICMP and give
+// false negatives behind some proxies.
+//
+// By default, the UDP connections are checked. This option is ignored in
+// Client.Clone().
+func UDPCheck(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.UDPCheck = b
+	})
+}
+
+// LocalAddr sets the local address the connection to the StatsD daemon is
+// made from, e.g. on multi-homed hosts where the metrics must leave via the
+// management network. The address is either an IP, an IP and a port, or the
+// name of a network interface whose first address is used, e.g. "eth1". For
+// unix networks, it is the path of the local socket.
+//
+// By default, the operating system chooses the local address. This option is
+// ignored in Client.Clone().
+func LocalAddr(addr string) Option {
+	return Option(func(c *config) {
+		c.Conn.LocalAddr = addr
+	})
+}
+
+// TCPNoDelay sets whether the Nagle's algorithm is disabled on TCP
+// connections, so that the payloads are sent as soon as they are flushed
+// instead of being coalesced by the operating system.
+//
+// By default, the Nagle's algorithm is disabled as in the net package. This
+// option is ignored in Client.Clone().
+func TCPNoDelay(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.TCPNoDelay = b
+	})
+}
+
+// SendBufferSize sets the size in bytes of the operating system's send buffer
+// of the socket (SO_SNDBUF). High-throughput senders can increase it to avoid
+// dropped or blocked writes during bursts.
+//
+// By default, the operating system's default is used. This option is ignored
+// in Client.Clone().
+func SendBufferSize(bytes int) Option {
+	return Option(func(c *config) {
+		c.Conn.SendBufferSize = bytes
+	})
+}
+
+// TrafficClass sets the type of service (IPv4) or traffic class (IPv6) byte
+// of the packets sent to the StatsD daemon, e.g. 0x20 (DSCP CS1) so that
+// network QoS deprioritizes the metrics relative to production traffic on
+// congested links. It only applies to UDP and TCP connections.
+//
+// This option is only supported on Linux, macOS, the BSDs and Solaris. This
+// option is ignored in Client.Clone().
+func TrafficClass(tos int) Option {
+	return Option(func(c *config) {
+		c.Conn.TrafficClass = tos
+	})
+}
+
+// LengthPrefix sets whether every payload sent by the Client is prefixed with
+// its length as a 4-byte little-endian integer. It must be used with the
+// "unix" stream network when the server is the Datadog agent, which expects
+// this framing on unix stream sockets:
+//
+//	statsd.New(
+//		statsd.Network("unix"),
+//		statsd.Address("/var/run/datadog/dsd.socket"),
+//		statsd.LengthPrefix(true),
+//	)
+//
+// By default, the payloads of stream networks are newline-delimited. This
+// option is ignored in Client.Clone().
+func LengthPrefix(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.LengthPrefix = b
+	})
+}
+
+// SignedGauges sets whether negative gauges are sent as is. By default, a
+// negative gauge is preceded by a zero gauge because the StatsD daemon
+// interprets signed values as relative changes.
+//
+// Datadog, Telegraf and statsite accept negative absolute gauges, with which
+// the extra zero sample creates misleading dips in graphs. This option is
+// ignored in Client.Clone().
+func SignedGauges(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.SignedGauges = b
+	})
+}
+
+// LineCache makes the Client cache the lines of up to n series without their
+// value, so that only the value and the timestamp of the metrics of the hot
+// series are rendered, e.g. when most of the calls hit a few hundred series.
+// The cache is cleared when it is full.
+//
+// It is ignored if the WireFormat option is used. This option is ignored in
+// Client.Clone().
+func LineCache(n int) Option {
+	return Option(func(c *config) {
+		c.Conn.LineCacheSize = n
+	})
+}
+
+// WireFormat sets the Serializer used to write the metrics on the wire, e.g.
+// to target a StatsD dialect without forking the Client. The metrics can also
+// be sent straight to Graphite with a GraphiteSerializer or a
+// GraphitePickleSerializer, to InfluxDB with an InfluxDBSerializer and to
+// OpenTSDB with an OpenTSDBSerializer.
+//
+// By default, a StatsDSerializer configured with the TagsFormat, ContainerID
+// and SignedGauges options is used. These options are ignored when a
+// Serializer is set. This option is ignored in Client.Clone().
+func WireFormat(s Serializer) Option {
+	return Option(func(c *config) {
+		c.Conn.Serializer = s
+	})
+}
+
+// DebugWriter sets a writer to which every flushed payload is copied, one
+// metric per line preceded by the time of the flush, e.g.:
+//
+//	2015-10-22T16:53:00.000Z app.requests:1|c
+//
+// It makes it easy to verify exactly what is sent, e.g. with
+// DebugWriter(os.Stderr). The errors of the writer are ignored. This option is
+// ignored in Client.Clone().
+func DebugWriter(w io.Writer) Option {
+	return Option(func(c *config) {
+		c.Conn.DebugWriter = w
+	})
+}
+
+// UseConn makes the Client send the payloads through the already established
+// connection w instead of dialing, e.g. a socket inherited through systemd
+// socket activation or taken from a pool. The Network option must be set to
+// the network of w, as it sets how the payloads are framed.
+//
+// Once w fails, the Client reconnects with the function set by Redial, or
+// stays disconnected if there is none. Client.Flush does not close w, unlike
+// the connections dialed by the Client. This option is ignored in
+// Client.Clone().
+func UseConn(w WriteCloserWithTimeout) Option {
+	return Option(func(c *config) {
+		c.Conn.Conn = w
+	})
+}
+
+// Redial sets the function called to get a new connection once the one set
+// by UseConn fails. It has no effect without UseConn.
+//
+// This option is ignored in Client.Clone().
+func Redial(f func() (WriteCloserWithTimeout, error)) Option {
+	return Option(func(c *config) {
+		c.Conn.Redial = f
+	})
+}
+
+// SocketActivation makes the Client send the payloads to the address set by
+// Address through a datagram socket inherited through systemd socket
+// activation, e.g. to send from the source address and port set in the socket
+// unit. name is the FileDescriptorName of the socket, or empty to use the first
+// inherited datagram socket. The Network option must be set to "udp" or
+// "unixgram", matching the socket.
+//
+// The Client stays disconnected, reporting the error, if the process did not
+// inherit such a socket. This option is ignored in Client.Clone().
+func SocketActivation(name string) Option {
+	return Option(func(c *config) {
+		c.Conn.SocketActivation = true
+		c.Conn.SocketName = name
+	})
+}
+
+// DryRun makes the Client write the payloads to w instead of sending them to
+// the StatsD daemon, e.g. ioutil.Discard or os.Stdout. Unlike Mute, the
+// metrics go through the whole serialization and flushing logic, so it can be
+// used to load-test the instrumentation overhead or to validate the output
+// format locally. Each line written to w ends with a new line.
+//
+// This option is ignored in Client.Clone().
+func DryRun(w io.Writer) Option {
+	return Option(func(c *config) {
+		c.Conn.DryRun = w
+	})
+}
+
+// OverflowQueue makes the Client keep the payloads it cannot send, e.g.
+// because the socket is temporarily blocked, in an in-memory queue of up to
+// maxBytes bytes. The queued payloads are sent once a payload has been sent
+// successfully again. The payloads which do not fit in the queue are spooled if
+// the Spool option is used, or else dropped.
+//
+// The high-water mark of the queue and the number of dropped metrics are
+// reported by Client.Stats(). This option is ignored in Client.Clone().
+func OverflowQueue(maxBytes int) Option {
+	return Option(func(c *config) {
+		c.Conn.QueueSize = maxBytes
+	})
+}
+
+// Spool makes the Client save the payloads it cannot send, because the StatsD
+// daemon is unreachable, to the file at path and replay them once a payload
+// has been sent successfully again. The payloads spooled by a previous process
+// are replayed as well, so that counters are not lost during e.g. an agent
+// restart.
+//
+// The file grows up to maxBytes: the payloads which do not fit are dropped and
+// ErrSpoolFull is reported to the ErrorHandler. If the file cannot be opened,
+// New returns an error. This option is ignored in Client.Clone().
+func Spool(path string, maxBytes int64) Option {
+	return Option(func(c *config) {
+		c.Conn.SpoolPath = path
+		c.Conn.SpoolSize = maxBytes
+	})
+}
+
+// BufferTTL makes the Client drop the payloads kept by the OverflowQueue and
+// Spool options, or kept in the buffer after a failed write without these
+// options, which are older than d when they are replayed, so that the metrics
+// of an outage do not distort the time series by arriving late. The dropped
+// metrics are reported by Client.Stats().
+//
+// By default, the payloads are replayed whatever their age. This option is
+// ignored in Client.Clone().
+func BufferTTL(d time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.BufferTTL = d
+	})
+}
+
 // Mute sets whether the Client is muted. All methods of a muted Client do
 // nothing and return immedialtly.
 //
@@ -105,6 +845,19 @@ func Mute(b bool) Option {
 	})
 }
 
+// Record sets whether a muted Client records the metrics in memory instead of
+// discarding them. The recorded metrics can be retrieved with
+// Client.Snapshot(), which is useful to inspect what would have been sent
+// during development or tests without any network.
+//
+// It has no effect if the Client is not muted. This option is ignored in
+// Client.Clone(): the clones of a recording Client record in the same memory.
+func Record(b bool) Option {
+	return Option(func(c *config) {
+		c.Conn.Record = b
+	})
+}
+
 // SampleRate sets the sample rate of the Client. It allows sending the metrics
 // less often which can be useful for performance intensive code paths.
 func SampleRate(rate float32) Option {
@@ -113,13 +866,89 @@ func SampleRate(rate float32) Option {
 	})
 }
 
+// SampleRateFor sets the sample rate of the Client for the metrics of the
+// given type, overriding the rate set with SampleRate for this type only. It
+// allows e.g. sending every counter while sampling timings.
+func SampleRateFor(t Type, rate float32) Option {
+	return Option(func(c *config) {
+		rates := make(map[Type]float32, len(c.Client.Rates)+1)
+		for k, v := range c.Client.Rates {
+			rates[k] = v
+		}
+		rates[t] = rate
+		c.Client.Rates = rates
+	})
+}
+
 // Prefix appends the prefix that will be used in every bucket name.
 //
+// The prefix can contain placeholders which are expanded when the Client is
+// created: {host} is replaced by the hostname (or the STATSD_HOSTNAME
+// environment variable if it is set), {env} by the STATSD_ENV (or
+// else DD_ENV) environment variable and {$NAME} by the NAME environment
+// variable, e.g. Prefix("my_app.{host}.{env}"). The dots of the values are
+// replaced by underscores.
+//
 // Note that when used in cloned, the prefix of the parent Client is not
-// replaced but is prepended to the given prefix.
+// replaced but is prepended to the given prefix, so a clone always stays in
+// the namespace of its parent. Use ReplacePrefix to replace it.
 func Prefix(p string) Option {
 	return Option(func(c *config) {
-		c.Client.Prefix += strings.TrimSuffix(p, ".") + "."
+		c.Client.Prefix += normalizePrefix(p)
+	})
+}
+
+// ReplacePrefix sets the prefix that will be used in every bucket name,
+// discarding any prefix previously set. An empty prefix removes the prefix.
+// The placeholders of the prefix are expanded as with Prefix.
+//
+// When used in Client.Clone(), the prefix of the parent Client is replaced.
+func ReplacePrefix(p string) Option {
+	return Option(func(c *config) {
+		if p == "" {
+			c.Client.Prefix = ""
+		} else {
+			c.Client.Prefix = normalizePrefix(p)
+		}
+	})
+}
+
+// normalizePrefix returns the prefix p with its placeholders expanded and
+// ending with a dot.
+func normalizePrefix(p string) string {
+	return strings.TrimSuffix(expandPrefix(p), ".") + "."
+}
+
+// MetricHook appends h to the hooks run on every metric before it is sent.
+// A hook can modify the metric, e.g. to rename it or to add tags with
+// Metric.AddTag, or veto it by returning false, in which case the metric is
+// not sent and the next hooks are not run.
+//
+// The hooks are run in the order they have been added. When used in
+// Client.Clone(), the hooks of the parent Client are run first.
+func MetricHook(h func(*Metric) bool) Option {
+	return Option(func(c *config) {
+		hooks := make([]func(*Metric) bool, len(c.Client.Hooks), len(c.Client.Hooks)+1)
+		copy(hooks, c.Client.Hooks)
+		c.Client.Hooks = append(hooks, h)
+	})
+}
+
+// RewriteRule rewrites the bucket names matching the regular expression
+// pattern, replacing the matches with replacement as in
+// regexp.Regexp.ReplaceAllString. It allows migrating metric naming schemes
+// gradually, e.g. RewriteRule(`^http\.(\w+)\.latency$`, "http.latency.$1").
+//
+// The rules are run as metric hooks, in the order they have been added. The
+// prefix of the Client is not rewritten. RewriteRule panics if pattern is not
+// a valid regular expression.
+func RewriteRule(pattern, replacement string) Option {
+	re := regexp.MustCompile(pattern)
+	return MetricHook(func(m *Metric) bool {
+		if re.MatchString(m.Bucket) {
+			m.Bucket = re.ReplaceAllString(m.Bucket, replacement)
+		}
+		return true
 	})
 }
 
@@ -134,10 +963,14 @@ func TagsFormat(tf TagFormat) Option {
 }
 
 // Tags appends the given tags to the tags sent with every metrics. If a tag
-// already exists, it is replaced.
+// already exists, it is replaced: when used in Client.Clone(), the value given
+// to the clone overrides the value inherited from its parent. If the same key
+// is given several times, the last value wins. The TagDedup option changes
+// which value is kept.
 //
 // The tags must be set as key-value pairs. If the number of tags is not even,
-// Tags panics.
+// Tags panics. With the Datadog format, a tag with an empty value is sent as a
+// bare tag, e.g. "canary".
 //
 // If the format of tags have not been set using the TagsFormat option, the tags
 // will be ignored.
@@ -147,29 +980,90 @@ func Tags(tags ...string) Option {
 	}
 
 	return Option(func(c *config) {
-		if len(tags) == 0 {
-			return
+		for i := 0; i < len(tags); i += 2 {
+			c.Client.Tags = append(c.Client.Tags, tag{K: tags[i], V: tags[i+1]})
+		}
+	})
+}
+
+// A TagPolicy tells which value of a tag is kept when the tag is set several
+// times, e.g. by a Client and its clone.
+type TagPolicy int
+
+const (
+	// KeepLast keeps the value set last, e.g. the value given to a clone. It
+	// is the default policy.
+	KeepLast TagPolicy = iota
+	// KeepFirst keeps the value set first, e.g. the value inherited from the
+	// parent of a clone.
+	KeepFirst
+	// KeepAll keeps every value, e.g. to send multi-valued Datadog tags.
+	KeepAll
+)
+
+// TagDedup sets how the tags set several times, e.g. by a Client and its
+// clone, are deduplicated.
+//
+// By default, KeepLast is used. This option is ignored in Client.Clone().
+func TagDedup(p TagPolicy) Option {
+	return Option(func(c *config) {
+		c.Conn.TagPolicy = p
+	})
+}
+
+// SortedTags sorts the tags sent with every metrics by key, which is required
+// by some InfluxDB setups and helps backends treating the tag order as
+// significant deduplicate series. The tags added with Metric.AddTag are not
+// sorted.
+//
+// This option is ignored in Client.Clone().
+func SortedTags() Option {
+	return Option(func(c *config) {
+		c.Conn.SortTags = true
+	})
+}
+
+// TagsMap appends the tags of the map m to the tags sent with every metrics,
+// as the Tags option does. The tags are appended in the order of their keys.
+func TagsMap(m map[string]string) Option {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return Option(func(c *config) {
+		for _, k := range keys {
+			c.Client.Tags = append(c.Client.Tags, tag{K: k, V: m[k]})
 		}
+	})
+}
 
-		newTags := make([]tag, len(tags)/2)
-		for i := 0; i < len(tags)/2; i++ {
-			newTags[i] = tag{K: tags[2*i], V: tags[2*i+1]}
+// HostnameTag appends a tag with the given key and the hostname as value to
+// the tags sent with every metrics. The hostname is resolved once, when the
+// option is applied. If the STATSD_HOSTNAME environment variable is set, it is
+// used instead of the hostname reported by the kernel.
+//
+// As with Tags, the tag is ignored if the format of tags has not been set.
+func HostnameTag(key string) Option {
+	return Option(func(c *config) {
+		if h := resolveHostname(); h != "" {
+			// As with Tags, the TagPolicy chooses the value of the key if it
+			// is already set.
+			c.Client.Tags = append(c.Client.Tags, tag{K: key, V: h})
 		}
+	})
+}
 
-		for _, newTag := range newTags {
-			exists := false
-			for j, _ := range c.Client.Tags {
-				if newTag.K == c.Client.Tags[j].K {
-					exists = true
-					c.Client.Tags[j].V = newTag.V
-				}
-			}
-			if !exists {
-				c.Client.Tags = append(c.Client.Tags, tag{
-					K: newTag.K,
-					V: newTag.V,
-				})
-			}
+// RemoveTag removes the tags with the given keys from the tags sent with
+// every metrics. Keys which are not set are ignored.
+//
+// It is mostly useful in Client.Clone() to drop tags inherited from the
+// parent Client.
+func RemoveTag(keys ...string) Option {
+	return Option(func(c *config) {
+		for _, k := range keys {
+			c.Client.Tags = removeTag(c.Client.Tags, k)
 		}
 	})
 }
@@ -178,6 +1072,73 @@ type tag struct {
 	K, V string
 }
 
+// setTag sets the value of the tag k, appending it if it does not exist yet.
+// The tags are copied, as they may be shared with the Client being cloned.
+func setTag(tags []tag, k, v string) []tag {
+	for i := range tags {
+		if tags[i].K == k {
+			tags = append([]tag(nil), tags...)
+			tags[i].V = v
+			return tags
+		}
+	}
+	return append(tags, tag{K: k, V: v})
+}
+
+// removeTag removes the tags k, preserving the order of the other tags. The
+// tags are not modified, as they may be shared with the Client being cloned.
+func removeTag(tags []tag, k string) []tag {
+	kept := make([]tag, 0, len(tags))
+	for _, t := range tags {
+		if t.K != k {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// dedupTags returns the tags with a single value per key, chosen by the policy
+// p. The tags keep the position of the first occurrence of their key.
+func dedupTags(tags []tag, p TagPolicy) []tag {
+	if p == KeepAll {
+		return tags
+	}
+	deduped := make([]tag, 0, len(tags))
+	idx := make(map[string]int, len(tags))
+	for _, t := range tags {
+		i, ok := idx[t.K]
+		switch {
+		case !ok:
+			idx[t.K] = len(deduped)
+			deduped = append(deduped, t)
+		case p == KeepLast:
+			deduped[i].V = t.V
+		}
+	}
+	return deduped
+}
+
+// hasKey returns whether the tags have a tag k.
+func hasKey(tags []tag, k string) bool {
+	for _, t := range tags {
+		if t.K == k {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag returns whether the tags s rendered in the format tf have a tag k.
+func hasTag(tf TagFormat, s, k string) bool {
+	switch tf {
+	case InfluxDB:
+		return strings.Contains(s, ","+k+"=")
+	case Datadog:
+		return strings.HasPrefix(s, "|#"+k+":") || strings.Contains(s, ","+k+":")
+	}
+	return false
+}
+
 func joinTags(tf TagFormat, tags []tag) string {
 	if len(tags) == 0 || tf == 0 {
 		return ""
@@ -186,6 +1147,33 @@ func joinTags(tf TagFormat, tags []tag) string {
 	return join(tags)
 }
 
+// renderTags renders the tags in the tag format of the connection, deduplicated
+// and sorted as configured.
+func (c *conn) renderTags(tags []tag) string {
+	return joinTags(c.tagFormat, c.effectiveTags(tags))
+}
+
+// effectiveTags returns the tags deduplicated and sorted as configured.
+func (c *conn) effectiveTags(tags []tag) []tag {
+	tags = dedupTags(tags, c.tagPolicy)
+	if c.sortTags {
+		tags = append([]tag(nil), tags...)
+		sort.SliceStable(tags, func(i, j int) bool {
+			return tags[i].K < tags[j].K
+		})
+	}
+	return tags
+}
+
+// appendTags appends tags to the tags s rendered in the format tf.
+func appendTags(tf TagFormat, s string, tags []tag) string {
+	t := joinTags(tf, tags)
+	if tf == Datadog && s != "" && t != "" {
+		return s + "," + t[len("|#"):]
+	}
+	return s + t
+}
+
 func splitTags(tf TagFormat, tags string) []tag {
 	if len(tags) == 0 || tf == 0 {
 		return nil
@@ -229,6 +1217,10 @@ var (
 					_ = buf.WriteByte(',')
 				}
 				_, _ = buf.WriteString(tag.K)
+				if tag.V == "" {
+					// Bare tag, e.g. "canary".
+					continue
+				}
 				_ = buf.WriteByte(':')
 				_, _ = buf.WriteString(tag.V)
 			}
@@ -241,8 +1233,11 @@ var (
 			pairs := strings.Split(s, ",")
 			tags := make([]tag, len(pairs))
 			for i, pair := range pairs {
-				kv := strings.Split(pair, "=")
-				tags[i] = tag{K: kv[0], V: kv[1]}
+				kv := strings.SplitN(pair, "=", 2)
+				tags[i] = tag{K: kv[0]}
+				if len(kv) == 2 {
+					tags[i].V = kv[1]
+				}
 			}
 			return tags
 		},
@@ -251,8 +1246,13 @@ var (
 			pairs := strings.Split(s, ",")
 			tags := make([]tag, len(pairs))
 			for i, pair := range pairs {
-				kv := strings.Split(pair, ":")
-				tags[i] = tag{K: kv[0], V: kv[1]}
+				// The values can contain colons, e.g. URLs, and the bare tags
+				// have no value.
+				kv := strings.SplitN(pair, ":", 2)
+				tags[i] = tag{K: kv[0]}
+				if len(kv) == 2 {
+					tags[i].V = kv[1]
+				}
 			}
 			return tags
 		},
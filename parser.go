@@ -0,0 +1,250 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An Event is a DogStatsD event, as returned by Parse.
+type Event struct {
+	Title          string
+	Text           string
+	Timestamp      time.Time
+	Hostname       string
+	AggregationKey string
+	Priority       string
+	SourceType     string
+	AlertType      string
+	// Tags are the tags of the event, e.g. "env:prod".
+	Tags []string
+}
+
+// A ServiceCheck is a DogStatsD service check, as returned by Parse.
+type ServiceCheck struct {
+	Name string
+	// Status is 0 for OK, 1 for WARNING, 2 for CRITICAL and 3 for UNKNOWN.
+	Status    int
+	Timestamp time.Time
+	Hostname  string
+	Message   string
+	// Tags are the tags of the service check, e.g. "env:prod".
+	Tags []string
+}
+
+// ErrSyntax is wrapped by the errors returned by Parse and ParseMetric.
+var ErrSyntax = errors.New("invalid syntax")
+
+// Parse parses a line of the StatsD wire format, with the DogStatsD extensions,
+// e.g. to build a relay or to check the metrics sent by a Client in tests. It
+// returns a *Metric, an *Event or a *ServiceCheck.
+func Parse(line string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(line, "_e{"):
+		return parseEvent(line)
+	case strings.HasPrefix(line, "_sc|"):
+		return parseServiceCheck(line)
+	}
+	return ParseMetric(line)
+}
+
+// ParseMetric parses a metric line of the StatsD wire format, with the
+// DogStatsD extensions. The value of the metric is a float64, a string for
+// sets or a []float64 for packed values. The signed gauges, e.g. "+5|g", are
+// Delta gauges. The other types, e.g. the Datadog distributions "d", are
+// CUSTOM metrics with their value kept as a string.
+//
+// The InfluxDB and Datadog tags are stored in the Tags field in their wire
+// format, so that the Metric can be sent again as is by a Client with the same
+// TagFormat. The prefix is not told apart from the bucket.
+func ParseMetric(line string) (*Metric, error) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return nil, parseError(line, "missing value")
+	}
+	m := &Metric{Bucket: line[:i], Rate: 1}
+	if j := strings.IndexByte(m.Bucket, ','); j >= 0 {
+		m.Bucket, m.Tags = m.Bucket[:j], m.Bucket[j:]
+		m.tagFormat = InfluxDB
+	}
+	fields := strings.Split(line[i+1:], "|")
+	if len(fields) < 2 {
+		return nil, parseError(line, "missing type")
+	}
+	switch fields[1] {
+	case "c":
+		m.Type = COUNT
+	case "g":
+		m.Type = GAUGE
+	case "ms":
+		m.Type = TIMINGS
+	case "h":
+		m.Type = HISTOGRAM
+	case "s":
+		m.Type = SET
+	case "":
+		return nil, parseError(line, "missing type")
+	default:
+		m.Type = CUSTOM
+		m.CustomType = fields[1]
+	}
+	if err := m.parseValue(fields[0]); err != nil {
+		return nil, parseError(line, err.Error())
+	}
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, "@"):
+			rate, err := strconv.ParseFloat(f[1:], 32)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, parseError(line, "invalid sample rate")
+			}
+			m.Rate = float32(rate)
+		case strings.HasPrefix(f, "#"):
+			if m.tagFormat == InfluxDB {
+				return nil, parseError(line, "mixed tag formats")
+			}
+			m.Tags = "|" + f
+			m.tagFormat = Datadog
+		case strings.HasPrefix(f, "T"):
+			ts, err := strconv.ParseInt(f[1:], 10, 64)
+			if err != nil {
+				return nil, parseError(line, "invalid timestamp")
+			}
+			m.Timestamp = time.Unix(ts, 0)
+		case strings.HasPrefix(f, "c:"):
+			// The container ID is set by the Client, not by the metric.
+		default:
+			return nil, parseError(line, "unknown field "+strconv.Quote(f))
+		}
+	}
+	return m, nil
+}
+
+// parseValue parses the value v of the metric.
+func (m *Metric) parseValue(v string) error {
+	if m.Type == SET || m.Type == CUSTOM {
+		// The values of the custom types are sent again as they are.
+		m.Value = v
+		return nil
+	}
+	if strings.IndexByte(v, ':') < 0 {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return errors.New("invalid value")
+		}
+		m.Value = n
+		m.Delta = m.Type == GAUGE && (v[0] == '+' || v[0] == '-')
+		return nil
+	}
+	parts := strings.Split(v, ":")
+	values := make([]float64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return errors.New("invalid packed value")
+		}
+		values[i] = n
+	}
+	m.Value = values
+	return nil
+}
+
+// parseEvent parses an event line: _e{<title length>,<text length>}:<title>|
+// <text>|d:<timestamp>|h:<hostname>|k:<aggregation key>|p:<priority>|
+// s:<source type>|t:<alert type>|#<tags>.
+func parseEvent(line string) (*Event, error) {
+	end := strings.Index(line, "}:")
+	if end < 0 {
+		return nil, parseError(line, "missing lengths")
+	}
+	lengths := strings.SplitN(line[len("_e{"):end], ",", 2)
+	if len(lengths) != 2 {
+		return nil, parseError(line, "missing lengths")
+	}
+	tl, err1 := strconv.Atoi(lengths[0])
+	xl, err2 := strconv.Atoi(lengths[1])
+	rest := line[end+len("}:"):]
+	if err1 != nil || err2 != nil || tl < 0 || xl < 0 || tl+1+xl > len(rest) || rest[tl] != '|' {
+		return nil, parseError(line, "invalid lengths")
+	}
+	e := &Event{
+		Title: rest[:tl],
+		// The new lines are escaped in the text.
+		Text: strings.Replace(rest[tl+1:tl+1+xl], `\n`, "\n", -1),
+	}
+	rest = rest[tl+1+xl:]
+	if rest == "" {
+		return e, nil
+	}
+	if rest[0] != '|' {
+		return nil, parseError(line, "invalid lengths")
+	}
+	for _, f := range strings.Split(rest[1:], "|") {
+		switch {
+		case strings.HasPrefix(f, "d:"):
+			ts, err := strconv.ParseInt(f[2:], 10, 64)
+			if err != nil {
+				return nil, parseError(line, "invalid timestamp")
+			}
+			e.Timestamp = time.Unix(ts, 0)
+		case strings.HasPrefix(f, "h:"):
+			e.Hostname = f[2:]
+		case strings.HasPrefix(f, "k:"):
+			e.AggregationKey = f[2:]
+		case strings.HasPrefix(f, "p:"):
+			e.Priority = f[2:]
+		case strings.HasPrefix(f, "s:"):
+			e.SourceType = f[2:]
+		case strings.HasPrefix(f, "t:"):
+			e.AlertType = f[2:]
+		case strings.HasPrefix(f, "#"):
+			e.Tags = strings.Split(f[1:], ",")
+		case strings.HasPrefix(f, "c:"):
+		default:
+			return nil, parseError(line, "unknown field "+strconv.Quote(f))
+		}
+	}
+	return e, nil
+}
+
+// parseServiceCheck parses a service check line: _sc|<name>|<status>|
+// d:<timestamp>|h:<hostname>|#<tags>|m:<message>.
+func parseServiceCheck(line string) (*ServiceCheck, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) < 3 || fields[1] == "" {
+		return nil, parseError(line, "missing name or status")
+	}
+	status, err := strconv.Atoi(fields[2])
+	if err != nil || status < 0 || status > 3 {
+		return nil, parseError(line, "invalid status")
+	}
+	sc := &ServiceCheck{Name: fields[1], Status: status}
+	for i, f := range fields[3:] {
+		switch {
+		case strings.HasPrefix(f, "d:"):
+			ts, err := strconv.ParseInt(f[2:], 10, 64)
+			if err != nil {
+				return nil, parseError(line, "invalid timestamp")
+			}
+			sc.Timestamp = time.Unix(ts, 0)
+		case strings.HasPrefix(f, "h:"):
+			sc.Hostname = f[2:]
+		case strings.HasPrefix(f, "#"):
+			sc.Tags = strings.Split(f[1:], ",")
+		case strings.HasPrefix(f, "m:"):
+			// The message is the last field and may contain pipes.
+			sc.Message = strings.Join(fields[3+i:], "|")[2:]
+			return sc, nil
+		case strings.HasPrefix(f, "c:"):
+		default:
+			return nil, parseError(line, "unknown field "+strconv.Quote(f))
+		}
+	}
+	return sc, nil
+}
+
+func parseError(line, reason string) error {
+	return fmt.Errorf("statsd: %w in %q: %s", ErrSyntax, line, reason)
+}
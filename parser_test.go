@@ -0,0 +1,135 @@
+package statsd
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMetric(t *testing.T) {
+	tests := []struct {
+		line string
+		want Metric
+	}{
+		{"test_key:1|c", Metric{Type: COUNT, Bucket: testKey, Value: 1.0, Rate: 1}},
+		{"test_key:-1.5|g", Metric{Type: GAUGE, Bucket: testKey, Value: -1.5, Rate: 1, Delta: true}},
+		{"test_key:+5|g", Metric{Type: GAUGE, Bucket: testKey, Value: 5.0, Rate: 1, Delta: true}},
+		{"test_key:1.5|g", Metric{Type: GAUGE, Bucket: testKey, Value: 1.5, Rate: 1}},
+		{"test_key:1:2|d|#a:b", Metric{Type: CUSTOM, CustomType: "d", Bucket: testKey, Value: "1:2", Rate: 1, Tags: "|#a:b", tagFormat: Datadog}},
+		{"test_key:+5|g+", Metric{Type: CUSTOM, CustomType: "g+", Bucket: testKey, Value: "+5", Rate: 1}},
+		{"test_key:12|ms|@0.5", Metric{Type: TIMINGS, Bucket: testKey, Value: 12.0, Rate: 0.5}},
+		{"test_key:12:7:3.5|ms", Metric{Type: TIMINGS, Bucket: testKey, Value: []float64{12, 7, 3.5}, Rate: 1}},
+		{"test_key:3|h|@0.1|#a:b,c:d", Metric{Type: HISTOGRAM, Bucket: testKey, Value: 3.0, Rate: 0.1, Tags: "|#a:b,c:d", tagFormat: Datadog}},
+		{"test_key:foo|s", Metric{Type: SET, Bucket: testKey, Value: "foo", Rate: 1}},
+		{"test_key,a=b,c=d:1|c", Metric{Type: COUNT, Bucket: testKey, Value: 1.0, Rate: 1, Tags: ",a=b,c=d", tagFormat: InfluxDB}},
+		{"test_key:1|c|T1136214245|c:abc", Metric{Type: COUNT, Bucket: testKey, Value: 1.0, Rate: 1, Timestamp: time.Unix(1136214245, 0)}},
+	}
+	for _, tt := range tests {
+		m, err := ParseMetric(tt.line)
+		if err != nil {
+			t.Errorf("ParseMetric(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, tt.want) {
+			t.Errorf("ParseMetric(%q) = %+v, want %+v", tt.line, *m, tt.want)
+		}
+	}
+}
+
+func TestParseMetricErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"test_key",
+		":1|c",
+		"test_key:1",
+		"test_key:1|",
+		"test_key:one|c",
+		"test_key:1:x|ms",
+		"test_key:1|c|@2",
+		"test_key:1|c|Tnow",
+		"test_key,a=b:1|c|#c:d",
+		"test_key:1|c|unknown",
+	} {
+		if _, err := ParseMetric(line); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseMetric(%q) = %v, want a syntax error", line, err)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	lines := []string{
+		"test_key:1|c|@0.5|#a:b",
+		"test_key:2:3|ms|#a:b",
+		"test_key:+5|g|#a:b",
+		"test_key:-5|g|#a:b",
+		"test_key:1:2|d|#a:b",
+		"test_key:3|kv|#a:b",
+	}
+	testOutput(t, strings.Join(lines, "\n"), func(c *Client) {
+		randFloat = func() float32 { return 0.1 }
+		defer func() { randFloat = rand.Float32 }()
+		for _, line := range lines {
+			m, err := ParseMetric(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Send(m)
+		}
+	}, TagsFormat(Datadog))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want interface{}
+	}{
+		{"test_key:1|c", &Metric{Type: COUNT, Bucket: testKey, Value: 1.0, Rate: 1}},
+		{`_e{5,10}:title|some\ntext|d:1136214245|h:host|k:key|p:low|s:app|t:warning|#a:b,c:d`, &Event{
+			Title:          "title",
+			Text:           "some\ntext",
+			Timestamp:      time.Unix(1136214245, 0),
+			Hostname:       "host",
+			AggregationKey: "key",
+			Priority:       "low",
+			SourceType:     "app",
+			AlertType:      "warning",
+			Tags:           []string{"a:b", "c:d"},
+		}},
+		{"_e{3,0}:a|b|", &Event{Title: "a|b"}},
+		{"_sc|db|2|d:1136214245|h:host|#a:b|m:down | again", &ServiceCheck{
+			Name:      "db",
+			Status:    2,
+			Timestamp: time.Unix(1136214245, 0),
+			Hostname:  "host",
+			Message:   "down | again",
+			Tags:      []string{"a:b"},
+		}},
+		{"_sc|db|0", &ServiceCheck{Name: "db"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		"_e{5}:title",
+		"_e{5,10}:title|text",
+		"_e{1,1}:a|b|x:y",
+		"_sc|db",
+		"_sc|db|4",
+		"_sc|db|0|x:y",
+	} {
+		if _, err := Parse(line); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) = %v, want a syntax error", line, err)
+		}
+	}
+}
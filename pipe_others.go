@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+// dialPipe always fails as named pipes are only available on Windows.
+func dialPipe(path string, timeout time.Duration) (WriteCloserWithTimeout, error) {
+	return nil, errors.New("statsd: named pipes are only supported on Windows")
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package statsd
+
+import "testing"
+
+func TestNamedPipeUnsupported(t *testing.T) {
+	c, err := New(Network("npipe"), Address(`\\.\pipe\datadog-dogstatsd`))
+	if err == nil {
+		t.Error("New should return an error")
+	}
+	if c.conn.w != nil {
+		t.Error("New() should not return a connected client")
+	}
+}
@@ -0,0 +1,55 @@
+//go:build windows
+// +build windows
+
+package statsd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// pipeBusyRetry is the delay between two attempts to open a busy named
+	// pipe.
+	pipeBusyRetry = 10 * time.Millisecond
+	// errorPipeBusy is the ERROR_PIPE_BUSY Windows error code, returned when
+	// all the instances of a named pipe are busy.
+	errorPipeBusy syscall.Errno = 231
+)
+
+// pipeConn is a client connection to a Windows named pipe.
+type pipeConn struct {
+	*os.File
+}
+
+// SetDeadline does nothing, as pipes opened synchronously do not support
+// deadlines.
+func (p pipeConn) SetDeadline(time.Time) error { return nil }
+
+// SetReadDeadline does nothing, as pipes opened synchronously do not support
+// deadlines.
+func (p pipeConn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline does nothing, as pipes opened synchronously do not support
+// deadlines.
+func (p pipeConn) SetWriteDeadline(time.Time) error { return nil }
+
+// dialPipe opens the named pipe at the given path (e.g.
+// `\\.\pipe\datadog-dogstatsd`), waiting up to timeout while all the pipe
+// instances are busy.
+func dialPipe(path string, timeout time.Duration) (WriteCloserWithTimeout, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err == nil {
+			return pipeConn{f}, nil
+		}
+		var errno syscall.Errno
+		if !errors.As(err, &errno) || errno != errorPipeBusy || timeout <= 0 || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(pipeBusyRetry)
+	}
+}
@@ -0,0 +1,58 @@
+package statsd
+
+// GaugeFunc registers f to be called periodically to send its result as the
+// value of the gauge bucket, e.g. to report queue depths or cache sizes
+// continuously. f is called at every flush period, or at every poll period if
+// the PollPeriod option is used. It must not block.
+//
+// The gauges registered by a Client and its clones are polled until the
+// Client is closed. GaugeFunc does nothing if the Client is muted.
+func (c *Client) GaugeFunc(bucket string, f func() float64, opts ...MetricOption) {
+	c.addPoller(func() {
+		c.Gauge(bucket, f(), opts...)
+	})
+}
+
+// addPoller registers f to be called at every poll, unless the Client is
+// muted.
+func (c *Client) addPoller(f func()) {
+	if c.muted {
+		return
+	}
+	c.conn.pollMu.Lock()
+	c.conn.pollers = append(c.conn.pollers, f)
+	c.conn.pollMu.Unlock()
+}
+
+// poll calls the functions registered by GaugeFunc and sends the heartbeats
+// of the ChangeGauges.
+func (c *conn) poll() {
+	c.pollMu.Lock()
+	pollers := c.pollers
+	gauges := make([]*ChangeGauge, 0, len(c.changeGauges))
+	for g := range c.changeGauges {
+		gauges = append(gauges, g)
+	}
+	c.pollMu.Unlock()
+	for _, p := range pollers {
+		p()
+	}
+	for _, g := range gauges {
+		g.beat()
+	}
+}
+
+// pollLoop periodically calls the functions registered by GaugeFunc until the
+// connection is closed.
+func (c *conn) pollLoop() {
+	ticker := c.clock.NewTicker(c.pollPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C():
+		}
+		c.poll()
+	}
+}
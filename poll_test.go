@@ -0,0 +1,39 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	testClient(t, func(c *Client) {
+		depth := 3.0
+		c.Clone(Prefix("queue.")).GaugeFunc("depth", func() float64 { return depth })
+		c.conn.poll()
+		depth = 5
+		c.conn.poll()
+		if got, want := c.PendingLines(), []string{"queue.depth:3|g", "queue.depth:5|g"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("PendingLines() = %q, want %q", got, want)
+		}
+		c.Close()
+	})
+}
+
+func TestGaugeFuncPollPeriod(t *testing.T) {
+	testClient(t, func(c *Client) {
+		polled := make(chan struct{}, 1)
+		c.GaugeFunc(testKey, func() float64 {
+			select {
+			case polled <- struct{}{}:
+			default:
+			}
+			return 1
+		})
+		select {
+		case <-polled:
+		case <-time.After(time.Second):
+			t.Error("GaugeFunc was not polled")
+		}
+		c.Close()
+	}, PollPeriod(time.Millisecond))
+}
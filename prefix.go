@@ -0,0 +1,54 @@
+package statsd
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	placeholderRegexp = regexp.MustCompile(`\{(host|env|\$[A-Za-z_][A-Za-z0-9_]*)\}`)
+
+	// Stubbed out for testing.
+	hostname = os.Hostname
+)
+
+// expandPrefix replaces the placeholders of the prefix p:
+//   - {host} by the hostname, see resolveHostname
+//   - {env} by the STATSD_ENV or else DD_ENV environment variable
+//   - {$NAME} by the NAME environment variable
+//
+// The dots of the values are replaced by underscores so that they do not add
+// levels to the bucket hierarchy.
+func expandPrefix(p string) string {
+	if !strings.Contains(p, "{") {
+		return p
+	}
+	return placeholderRegexp.ReplaceAllStringFunc(p, func(s string) string {
+		var v string
+		switch name := s[1 : len(s)-1]; name {
+		case "host":
+			v = resolveHostname()
+		case "env":
+			if v = os.Getenv("STATSD_ENV"); v == "" {
+				v = os.Getenv("DD_ENV")
+			}
+		default:
+			v = os.Getenv(name[1:])
+		}
+		return strings.Replace(v, ".", "_", -1)
+	})
+}
+
+// hostnameEnv is the environment variable overriding the hostname.
+const hostnameEnv = "STATSD_HOSTNAME"
+
+// resolveHostname returns the STATSD_HOSTNAME environment variable if it is
+// set or else the hostname reported by the kernel.
+func resolveHostname() string {
+	if h := os.Getenv(hostnameEnv); h != "" {
+		return h
+	}
+	h, _ := hostname()
+	return h
+}
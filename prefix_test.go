@@ -0,0 +1,56 @@
+package statsd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPrefixPlaceholders(t *testing.T) {
+	hostname = func() (string, error) { return "web1.example.com", nil }
+	defer func() { hostname = os.Hostname }()
+	os.Setenv("STATSD_ENV", "prod")
+	defer os.Unsetenv("STATSD_ENV")
+	os.Setenv("STATSD_TEST_REGION", "eu.west")
+	defer os.Unsetenv("STATSD_TEST_REGION")
+
+	testOutput(t, "app.web1_example_com.prod.eu_west.test_key:1|c\nother.prod.{unknown}.test_key:1|c", func(c *Client) {
+		c.Increment(testKey)
+		c.Clone(ReplacePrefix("other.{env}.{unknown}")).Increment(testKey)
+	}, Prefix("app.{host}.{env}.{$STATSD_TEST_REGION}."))
+}
+
+func TestPrefixEnvFallback(t *testing.T) {
+	os.Unsetenv("STATSD_ENV")
+	os.Setenv("DD_ENV", "staging")
+	defer os.Unsetenv("DD_ENV")
+
+	testOutput(t, "app.staging.test_key:1|c", func(c *Client) {
+		c.Increment(testKey)
+	}, Prefix("app.{env}"))
+}
+
+func TestHostnameTag(t *testing.T) {
+	hostname = func() (string, error) { return "web1.example.com", nil }
+	defer func() { hostname = os.Hostname }()
+
+	testOutput(t, "test_key:1|c|#tag1:value1,host:web1.example.com", func(c *Client) {
+		c.Increment(testKey)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"), HostnameTag("host"))
+
+	os.Setenv(hostnameEnv, "override")
+	defer os.Unsetenv(hostnameEnv)
+	testOutput(t, "test_key,host=override:1|c\noverride.test_key:1|c", func(c *Client) {
+		c.Increment(testKey)
+		c.Clone(RemoveTag("host"), ReplacePrefix("{host}")).Increment(testKey)
+	}, TagsFormat(InfluxDB), HostnameTag("host"))
+}
+
+func TestHostnameTagClone(t *testing.T) {
+	hostname = func() (string, error) { return "web1", nil }
+	defer func() { hostname = os.Hostname }()
+
+	testOutput(t, "test_key:1|c|#host:web1,a:1\ntest_key:2|c|#host:db1,a:1", func(c *Client) {
+		c.Clone(HostnameTag("host")).Count(testKey, 1)
+		c.Clone().Count(testKey, 2)
+	}, TagsFormat(Datadog), Tags("host", "db1", "a", "1"))
+}
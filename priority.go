@@ -0,0 +1,46 @@
+package statsd
+
+// A Priority is the priority of a metric. When the payloads cannot be sent,
+// the metrics with the LowPriority are dropped first, so that the critical
+// metrics survive the bursts and outages.
+type Priority uint8
+
+// The priorities of the metrics. The zero Priority is the priority of the
+// Client sending the metric, HighPriority by default.
+const (
+	HighPriority Priority = iota + 1
+	LowPriority
+)
+
+// DefaultPriority sets the priority of the metrics sent by the Client, e.g.
+// to send the metrics of a clone with the LowPriority.
+//
+// The metrics with the LowPriority are sent in their own payloads, after the
+// other ones at each flush. When the payloads cannot be sent, the low priority
+// payloads are evicted from the overflow queue of the OverflowQueue option to
+// make room for the other ones, and they are not spooled.
+//
+// By default, HighPriority is used.
+func DefaultPriority(p Priority) Option {
+	return Option(func(c *config) {
+		c.Client.Priority = p
+	})
+}
+
+// WithPriority sets the priority of a metric, overriding the one of the
+// Client.
+func WithPriority(p Priority) MetricOption {
+	return func(m *Metric) {
+		m.Priority = p
+	}
+}
+
+// appendLow appends the low priority metric m to its buffer, flushing it if it
+// is full.
+func (c *conn) appendLow(m *Metric) {
+	l := len(c.lowBuf)
+	c.lowBuf = c.serializer.AppendMetric(c.lowBuf, m)
+	if len(c.lowBuf) > c.maxPacketSize {
+		c.flushBuf(nil, &c.lowBuf, l, LowPriority)
+	}
+}
@@ -0,0 +1,60 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	testOutput(t, "b:1|c\nd:1|ca:1|c\nc:1|g\ne:1|kv", func(c *Client) {
+		c.Increment("a", WithPriority(LowPriority))
+		c.Increment("b")
+		low := c.Clone(DefaultPriority(LowPriority))
+		low.Gauge("c", 1)
+		low.Increment("d", WithPriority(HighPriority))
+		low.Custom("e", 1, "kv")
+	})
+}
+
+func TestLowPriorityEvicted(t *testing.T) {
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(OverflowQueue(26), FlushPeriod(0))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment("low", WithPriority(LowPriority))
+	c.Flush()
+	c.Count(testKey, 2)
+	c.Flush()
+	if s := c.Stats(); s.QueueBytes != 21 || s.Dropped != 0 {
+		t.Errorf("Stats() = %+v, want 21 queued bytes", s)
+	}
+	c.Count(testKey, 3)
+	c.Flush()
+	if s := c.Stats(); s.QueueBytes != 26 || s.Dropped != 1 {
+		t.Errorf("Stats() = %+v, want 26 queued bytes and the low priority metric dropped", s)
+	}
+	c.Increment("low", WithPriority(LowPriority))
+	c.Flush()
+	if s := c.Stats(); s.QueueBytes != 26 || s.Dropped != 2 {
+		t.Errorf("Stats() = %+v, want the low priority metric dropped", s)
+	}
+
+	down = false
+	c.Count(testKey, 4)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:4|ctest_key:2|ctest_key:3|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	c.Close()
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOverflowQueue(t *testing.T) {
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(OverflowQueue(26), FlushPeriod(0))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.Flush()
+	c.Count(testKey, 2)
+	c.Flush()
+	c.Count(testKey, 3)
+	c.Flush()
+	if metrics, _ := c.Pending(); metrics != 0 {
+		t.Errorf("Pending() = %d, want the queued metrics to be removed from the buffer", metrics)
+	}
+	if s := c.Stats(); s.QueueBytes != 26 || s.QueueHighWater != 26 || s.Dropped != 1 {
+		t.Errorf("Stats() = %+v, want 26 queued bytes and 1 dropped metric", s)
+	}
+
+	down = false
+	c.Count(testKey, 4)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:4|ctest_key:1|ctest_key:2|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	if s := c.Stats(); s.QueueBytes != 0 || s.QueueHighWater != 26 || s.Dropped != 1 {
+		t.Errorf("Stats() = %+v, want an empty queue", s)
+	}
+	c.Close()
+}
+
+func TestDroppedWithoutQueue(t *testing.T) {
+	testClient(t, func(c *Client) {
+		getBuffer(c).err = errors.New("test error")
+		c.Increment(testKey)
+		c.Count(testKey, 2)
+		c.Close()
+		if got := c.Stats().Dropped; got != 2 {
+			t.Errorf("Stats().Dropped = %d, want 2", got)
+		}
+	}, ErrorHandler(func(error) {}))
+}
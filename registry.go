@@ -0,0 +1,31 @@
+package statsd
+
+import "sync"
+
+var registry = struct {
+	sync.RWMutex
+	clients map[string]*Client
+}{clients: make(map[string]*Client)}
+
+// Register registers the Client under the given name so that it can be
+// retrieved anywhere in the program with Get. If a Client was already
+// registered under this name, it is replaced. Registering a nil Client removes
+// the name from the registry.
+func Register(name string, c *Client) {
+	registry.Lock()
+	if c == nil {
+		delete(registry.clients, name)
+	} else {
+		registry.clients[name] = c
+	}
+	registry.Unlock()
+}
+
+// Get returns the Client registered under the given name or nil if there is
+// none.
+func Get(name string) *Client {
+	registry.RLock()
+	c := registry.clients[name]
+	registry.RUnlock()
+	return c
+}
@@ -0,0 +1,25 @@
+package statsd
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	testOutput(t, "test_key:1|c", func(c *Client) {
+		if Get("app") != nil {
+			t.Fatal("Get() should return nil for an unknown name")
+		}
+
+		Register("app", c)
+		defer Register("app", nil)
+
+		got := Get("app")
+		if got != c {
+			t.Fatalf("Get() = %p, want %p", got, c)
+		}
+		got.Increment(testKey)
+
+		Register("app", nil)
+		if Get("app") != nil {
+			t.Error("Get() should return nil after the Client has been unregistered")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package statsd
+
+import "net"
+
+// A Relay receives metrics from StatsD clients and forwards them through a
+// Client, like a minimal statsd-proxy running in process. The options of the
+// Client are used to process the metrics on the way: Tags re-tags them,
+// MetricHook and RewriteRule rename or filter them and TimingSummaries,
+// Sketches or PackTimings aggregate them.
+type Relay struct {
+	l *Listener
+	c *Client
+}
+
+// NewRelay starts a Relay receiving metrics on the given network and address
+// and forwarding them through c, the networks being the ones of Listen, e.g.
+// "systemd" to run under a socket-activated unit. The prefix and the tags of c
+// are added to the ones of the metrics, and the metrics are not sampled again.
+//
+// Closing the Relay does not close c.
+func NewRelay(network, addr string, c *Client) (*Relay, error) {
+	r := &Relay{c: c}
+	l, err := Listen(network, addr, r.forward)
+	if err != nil {
+		return nil, err
+	}
+	r.l = l
+	return r, nil
+}
+
+// Addr returns the address the Relay listens on.
+func (r *Relay) Addr() net.Addr {
+	return r.l.Addr()
+}
+
+// Close stops the Relay.
+func (r *Relay) Close() error {
+	return r.l.Close()
+}
+
+// forward sends the received metric m through the Client.
+func (r *Relay) forward(m *Metric) {
+	c := r.c
+	if c.muted && !c.conn.record {
+		return
+	}
+	fm := GetMetric()
+	*fm = *m
+	fm.Prefix = c.prefix + m.Prefix
+	fm.Tags = c.relayTags(m)
+	c.emit(fm)
+}
+
+// relayTags returns the tags of the relayed metric m followed by the tags of
+// the Client, rendered in the TagFormat of the Client.
+func (c *Client) relayTags(m *Metric) string {
+	tf := c.conn.tagFormat
+	switch {
+	case m.Tags == "":
+		return c.tags
+	case tf == 0:
+		return ""
+	case m.tagFormat == tf && c.tags == "" && !c.conn.sortTags:
+		return m.Tags
+	}
+	return c.conn.renderTags(append(splitTags(m.tagFormat, m.Tags), splitTags(tf, c.tags)...))
+}
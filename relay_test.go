@@ -0,0 +1,140 @@
+package statsd
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelay(t *testing.T) {
+	lines := make(chan string, 10)
+	downstream, err := ListenLines("udp", "127.0.0.1:0", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer downstream.Close()
+
+	out, err := New(Address(downstream.Addr().String()), FlushPeriod(10*time.Millisecond),
+		Prefix("app."), TagsFormat(InfluxDB), Tags("relay", "r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	r, err := NewRelay("udp", "127.0.0.1:0", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	randFloat = func() float32 { return 0.1 }
+	defer func() { randFloat = rand.Float32 }()
+	in, err := New(Address(r.Addr().String()), FlushPeriod(0), TagsFormat(Datadog), Tags("a", "b", "relay", "r0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Increment(testKey)
+	in.Clone(SampleRate(0.5)).Timing(testKey, 3)
+	in.Close()
+
+	for _, want := range []string{"app.test_key,a=b,relay=r1:1|c", "app.test_key,a=b,relay=r1:3|ms|@0.5"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("Invalid line, got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q was not relayed", want)
+		}
+	}
+}
+
+func TestRelayTagsWithoutValue(t *testing.T) {
+	lines := make(chan string, 10)
+	downstream, err := ListenLines("udp", "127.0.0.1:0", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer downstream.Close()
+
+	out, err := New(Address(downstream.Addr().String()), FlushPeriod(10*time.Millisecond),
+		TagsFormat(Datadog), Tags("relay", "r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	r, err := NewRelay("udp", "127.0.0.1:0", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("requests:1|c|#canary,url:http://x")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-lines:
+		if want := "requests:1|c|#canary,url:http://x,relay:r1"; got != want {
+			t.Errorf("Invalid line, got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The metric was not relayed")
+	}
+}
+
+func TestRelayGaugeDeltasAndCustomTypes(t *testing.T) {
+	lines := make(chan string, 10)
+	downstream, err := ListenLines("udp", "127.0.0.1:0", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer downstream.Close()
+
+	out, err := New(Address(downstream.Addr().String()), FlushPeriod(10*time.Millisecond), TagsFormat(Datadog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	r, err := NewRelay("udp", "127.0.0.1:0", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	want := []string{"g:+5|g", "g:-3|g", "g:2|g", "latency:1:2.5|d|#a:b", "kv:3|kv"}
+	if _, err := conn.Write([]byte(strings.Join(want, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Errorf("Invalid line, got %q, want %q", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q was not relayed", w)
+		}
+	}
+	if got := r.c.Stats().Dropped; got != 0 {
+		t.Errorf("Stats().Dropped = %d, want 0", got)
+	}
+}
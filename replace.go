@@ -0,0 +1,66 @@
+package statsd
+
+import "strings"
+
+// ReplaceChars replaces the characters of the bucket names and of the tag
+// values which are keys of m with their value, or removes them if their value
+// is negative, e.g. map[rune]rune{'/': '.', ' ': '_'}, as the characters
+// reserved by the StatsD daemons differ.
+//
+// The replacements are run as a metric hook, with the hooks and rules in the
+// order they have been added. The prefix of the Client and the tag keys are
+// not modified, nor the tags without a value. The tags are rendered before the
+// hooks are run, so the separators of the TagFormat, e.g. the commas, cannot be
+// replaced in the tag values.
+func ReplaceChars(m map[rune]rune) Option {
+	replacements := make(map[rune]rune, len(m))
+	for old, r := range m {
+		replacements[old] = r
+	}
+	replace := func(r rune) rune {
+		if n, ok := replacements[r]; ok {
+			return n
+		}
+		return r
+	}
+	replaced := func(r rune) bool {
+		_, ok := replacements[r]
+		return ok
+	}
+	return MetricHook(func(m *Metric) bool {
+		// strings.Map does not allocate if no character is replaced.
+		m.Bucket = strings.Map(replace, m.Bucket)
+		if strings.IndexFunc(m.Tags, replaced) < 0 {
+			return true
+		}
+		m.Tags = mapTagValues(m.tagFormat, m.Tags, replace)
+		return true
+	})
+}
+
+// mapTagValues returns the tags s rendered in the format tf with the
+// characters of their values mapped by f. The tags are not split into pairs
+// and joined again, so that the tags without a value are kept as they are.
+func mapTagValues(tf TagFormat, s string, f func(rune) rune) string {
+	start, kv := len(","), byte('=')
+	if tf == Datadog {
+		start, kv = len("|#"), ':'
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:start])
+	for i, t := range strings.Split(s[start:], ",") {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		j := strings.IndexByte(t, kv)
+		if j < 0 {
+			// The bare tags have no value.
+			b.WriteString(t)
+			continue
+		}
+		b.WriteString(t[:j+1])
+		b.WriteString(strings.Map(f, t[j+1:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,19 @@
+package statsd
+
+import "testing"
+
+func TestReplaceChars(t *testing.T) {
+	testOutput(t, "a/b.c.d_x:1|c|#path:.api.v1,code:2xx\na/b.ok:1|c|#path:ok", func(c *Client) {
+		c.WithTags("path", "/api/v1", "code", "2xx!").Increment("c/d x!")
+		c.WithTags("path", "ok").Increment("ok")
+	}, Prefix("a/b"), TagsFormat(Datadog), ReplaceChars(map[rune]rune{'/': '.', ' ': '_', '!': -1}))
+}
+
+func TestReplaceCharsTagValues(t *testing.T) {
+	testOutput(t, "req:1|c|#url:http:..x.a_b,canary!", func(c *Client) {
+		c.WithTags("url", "http://x/a b", "canary!", "").Increment("req")
+	}, TagsFormat(Datadog), ReplaceChars(map[rune]rune{'/': '.', ' ': '_'}))
+	testOutput(t, "req,url=http:..x.a:1|c", func(c *Client) {
+		c.WithTags("url", "http://x/a").Increment("req")
+	}, TagsFormat(InfluxDB), ReplaceChars(map[rune]rune{'/': '.'}))
+}
@@ -0,0 +1,151 @@
+package statsd
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// newChildConn returns a connection to addr configured as c, used to route
+// some of the metrics to another StatsD daemon.
+func (c *conn) newChildConn(conf connConfig, addr string) (*conn, error) {
+	c.nchildren++
+	conf.Addr = addr
+	conf.Shards = nil
+	conf.SRV = ""
+	conf.Failover = nil
+	conf.Routes = nil
+	if conf.SpoolPath != "" {
+		conf.SpoolPath = fmt.Sprintf("%s.%d", conf.SpoolPath, c.nchildren)
+	}
+	return newConn(conf, false)
+}
+
+// conns returns the connection and its child connections.
+func (c *conn) conns() []*conn {
+	c.routeMu.RLock()
+	conns := make([]*conn, 0, 1+len(c.shards)+len(c.children))
+	conns = append(conns, c)
+	conns = append(conns, c.shards...)
+	conns = append(conns, c.children...)
+	c.routeMu.RUnlock()
+	return conns
+}
+
+// setShards spreads the metrics across the StatsD daemons at addrs. The
+// connection itself is used for its own address and child connections are
+// made to the other ones. The child connections to the daemons which are not
+// in addrs anymore are closed.
+func (c *conn) setShards(conf connConfig, addrs []string) error {
+	c.routeMu.RLock()
+	old := make(map[string]*conn, len(c.shards)+1)
+	for _, s := range c.shards {
+		old[s.addr] = s
+	}
+	c.routeMu.RUnlock()
+	old[c.addr] = c
+
+	var err error
+	conns := make([]*conn, len(addrs))
+	var shards []*conn
+	for i, addr := range addrs {
+		s, ok := old[addr]
+		if ok {
+			delete(old, addr)
+		} else {
+			var serr error
+			if s, serr = c.newChildConn(conf, addr); s == nil {
+				return serr
+			}
+			if err == nil {
+				err = serr
+			}
+		}
+		conns[i] = s
+		if s != c {
+			shards = append(shards, s)
+		}
+	}
+
+	c.logf("sharding across %v", addrs)
+	c.routeMu.Lock()
+	c.shards = shards
+	if len(addrs) == 1 && conns[0] == c {
+		c.ring = nil
+	} else {
+		c.ring = newHashRing(addrs, conns)
+	}
+	c.routeMu.Unlock()
+
+	for _, s := range old {
+		if s != c {
+			s.close()
+		}
+	}
+	return err
+}
+
+// pick returns the connection the metric m must be sent to.
+func (c *conn) pick(m *Metric) *conn {
+	if to, ok := c.routes[m.Type]; ok {
+		return to
+	}
+	c.routeMu.RLock()
+	r := c.ring
+	c.routeMu.RUnlock()
+	if r != nil {
+		return r.get(m.Name())
+	}
+	return c
+}
+
+// Number of points of each server on the hash ring, as in libketama.
+const ringPointsPerServer = 160
+
+// A hashRing is a consistent hash ring compatible with libketama and the
+// hashring package used by statsd-proxy.
+type hashRing struct {
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash uint32
+	conn *conn
+}
+
+// newHashRing returns the hash ring of the servers addrs, which are reached
+// through conns.
+func newHashRing(addrs []string, conns []*conn) *hashRing {
+	r := &hashRing{points: make([]ringPoint, 0, len(addrs)*ringPointsPerServer)}
+	for i, addr := range addrs {
+		for j := 0; j < ringPointsPerServer/4; j++ {
+			d := md5.Sum([]byte(addr + "-" + strconv.Itoa(j)))
+			for k := 0; k < 4; k++ {
+				r.points = append(r.points, ringPoint{hash: ketamaHash(d[k*4:]), conn: conns[i]})
+			}
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// get returns the connection of the server owning key.
+func (r *hashRing) get(key string) *conn {
+	d := md5.Sum([]byte(key))
+	h := ketamaHash(d[:])
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].conn
+}
+
+// ketamaHash returns the hash made of the first 4 bytes of the digest d.
+func ketamaHash(d []byte) uint32 {
+	return uint32(d[3])<<24 | uint32(d[2])<<16 | uint32(d[1])<<8 | uint32(d[0])
+}
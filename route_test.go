@@ -0,0 +1,98 @@
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	conns := map[string]*testBuffer{}
+	dialTimeout = func(_, addr string, _ time.Duration) (net.Conn, error) {
+		conns[addr] = &testBuffer{}
+		return conns[addr], nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	addrs := []string{"10.0.0.1:8125", "10.0.0.2:8125", "10.0.0.3:8125"}
+	c, err := New(FlushPeriod(0), Shards(addrs))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Increment("bucket" + strconv.Itoa(i))
+		c.Increment("bucket" + strconv.Itoa(i))
+	}
+	if metrics, _ := c.Pending(); metrics != 200 {
+		t.Errorf("Pending() = %d, want 200", metrics)
+	}
+	c.Close()
+
+	seen := map[string]string{}
+	total := 0
+	for _, addr := range addrs {
+		out := conns[addr].buf.String()
+		if out == "" {
+			t.Errorf("no metric sent to %s", addr)
+			continue
+		}
+		for _, line := range strings.Split(out, "\n") {
+			total++
+			bucket := strings.SplitN(line, ":", 2)[0]
+			if other, ok := seen[bucket]; ok && other != addr {
+				t.Errorf("%s sent to %s and %s", bucket, other, addr)
+			}
+			seen[bucket] = addr
+		}
+	}
+	if total != 200 {
+		t.Errorf("%d metrics sent, want 200", total)
+	}
+}
+
+func TestHashRingStable(t *testing.T) {
+	a, b, c := &conn{addr: "a"}, &conn{addr: "b"}, &conn{addr: "c"}
+	r2 := newHashRing([]string{"a", "b"}, []*conn{a, b})
+	r3 := newHashRing([]string{"a", "b", "c"}, []*conn{a, b, c})
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := "bucket" + strconv.Itoa(i)
+		if got := r3.get(key); got != r2.get(key) {
+			if got != c {
+				t.Fatalf("%s moved from %s to %s", key, r2.get(key).addr, got.addr)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 500 {
+		t.Errorf("%d keys moved to the new server, want about a third", moved)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	conns := map[string]*testBuffer{}
+	dialTimeout = func(_, addr string, _ time.Duration) (net.Conn, error) {
+		conns[addr] = &testBuffer{}
+		return conns[addr], nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(FlushPeriod(0), Route(TIMINGS, ":8126"), Route(HISTOGRAM, ":8126"))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.Timing(testKey, 5)
+	c.Histogram(testKey, 6)
+	c.Gauge(testKey, 7)
+	c.Close()
+
+	if got, want := conns[":8125"].buf.String(), "test_key:1|c\ntest_key:7|g"; got != want {
+		t.Errorf("Invalid output to :8125, got %q, want %q", got, want)
+	}
+	if got, want := conns[":8126"].buf.String(), "test_key:5|ms\ntest_key:6|h"; got != want {
+		t.Errorf("Invalid output to :8126, got %q, want %q", got, want)
+	}
+}
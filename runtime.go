@@ -0,0 +1,161 @@
+//go:build go1.16
+// +build go1.16
+
+package statsd
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"sync"
+)
+
+// DefaultRuntimeMetrics are the runtime/metrics samples sent by
+// Client.RuntimeMetrics when no name is given.
+var DefaultRuntimeMetrics = []string{
+	"/gc/cycles/total:gc-cycles",
+	"/gc/heap/goal:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/sched/goroutines:goroutines",
+	"/sched/latencies:seconds",
+	"/gc/pauses:seconds",
+}
+
+// RuntimeMetrics sends the given samples of the runtime/metrics package at
+// every poll, see GaugeFunc. If no name is given, DefaultRuntimeMetrics are
+// sent. The samples unsupported by the running Go version are ignored.
+//
+// The bucket of a sample is its name prefixed with "runtime", with slashes and
+// colons replaced by dots and dashes by underscores, e.g.
+// "/gc/heap/goal:bytes" is sent as "runtime.gc.heap.goal.bytes". The samples
+// are sent as:
+//   - gauges for the non-cumulative values,
+//   - counts of the increase since the previous poll for the cumulative
+//     values,
+//   - timings in milliseconds for the histograms in seconds and histograms
+//     otherwise, sending one sampled value per bucket of the histogram.
+func (c *Client) RuntimeMetrics(names ...string) {
+	if len(names) == 0 {
+		names = DefaultRuntimeMetrics
+	}
+	descs := make(map[string]metrics.Description)
+	for _, d := range metrics.All() {
+		descs[d.Name] = d
+	}
+	r := &runtimeCollector{c: c}
+	for _, name := range names {
+		d, ok := descs[name]
+		if !ok || d.Kind == metrics.KindBad {
+			continue
+		}
+		r.samples = append(r.samples, metrics.Sample{Name: name})
+		r.metrics = append(r.metrics, runtimeMetric{
+			bucket:     runtimeBucket(name),
+			cumulative: d.Cumulative,
+			seconds:    strings.HasSuffix(name, ":seconds"),
+		})
+	}
+	if len(r.samples) == 0 {
+		return
+	}
+	r.collect(false)
+	c.addPoller(func() { r.collect(true) })
+}
+
+// runtimeCollector sends runtime/metrics samples.
+type runtimeCollector struct {
+	c       *Client
+	mu      sync.Mutex
+	samples []metrics.Sample
+	metrics []runtimeMetric
+}
+
+// runtimeMetric holds the state of a runtime/metrics sample.
+type runtimeMetric struct {
+	bucket     string
+	cumulative bool
+	seconds    bool
+	last       float64
+	lastCounts []uint64
+}
+
+// collect reads the samples and sends them if send is true. Otherwise, only
+// the values of the cumulative samples are recorded.
+func (r *runtimeCollector) collect(send bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metrics.Read(r.samples)
+	for i, s := range r.samples {
+		m := &r.metrics[i]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			m.send(r.c, float64(s.Value.Uint64()), send)
+		case metrics.KindFloat64:
+			m.send(r.c, s.Value.Float64(), send)
+		case metrics.KindFloat64Histogram:
+			m.sendHistogram(r.c, s.Value.Float64Histogram(), send)
+		}
+	}
+}
+
+func (m *runtimeMetric) send(c *Client, v float64, send bool) {
+	if !m.cumulative {
+		if send {
+			c.Gauge(m.bucket, v)
+		}
+		return
+	}
+	if send && v > m.last {
+		c.Count(m.bucket, v-m.last)
+	}
+	m.last = v
+}
+
+func (m *runtimeMetric) sendHistogram(c *Client, h *metrics.Float64Histogram, send bool) {
+	if len(m.lastCounts) != len(h.Counts) {
+		m.lastCounts = make([]uint64, len(h.Counts))
+	}
+	t := HISTOGRAM
+	if m.seconds {
+		t = TIMINGS
+	}
+	for i, n := range h.Counts {
+		delta := n - m.lastCounts[i]
+		m.lastCounts[i] = n
+		if !send || delta == 0 {
+			continue
+		}
+		v := bucketValue(h.Buckets[i], h.Buckets[i+1])
+		if m.seconds {
+			v *= 1000
+		}
+		// A single value sampled at 1/delta stands for the delta values of the
+		// bucket.
+		sm := GetMetric()
+		sm.Type = t
+		sm.Prefix = c.prefix
+		sm.Bucket = m.bucket
+		sm.Value = v
+		sm.Rate = 1 / float32(delta)
+		sm.Tags = c.tags
+		c.emit(sm)
+	}
+}
+
+// bucketValue returns the value representing the histogram bucket between the
+// boundaries lo and hi.
+func bucketValue(lo, hi float64) float64 {
+	switch {
+	case math.IsInf(lo, -1):
+		return hi
+	case math.IsInf(hi, 1):
+		return lo
+	}
+	return (lo + hi) / 2
+}
+
+// runtimeBucket returns the bucket of the runtime/metrics sample name.
+func runtimeBucket(name string) string {
+	return "runtime" + strings.NewReplacer("/", ".", ":", ".", "-", "_").Replace(name)
+}
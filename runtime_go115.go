@@ -0,0 +1,11 @@
+//go:build !go1.16
+// +build !go1.16
+
+package statsd
+
+// DefaultRuntimeMetrics are the runtime/metrics samples sent by
+// Client.RuntimeMetrics when no name is given.
+var DefaultRuntimeMetrics []string
+
+// RuntimeMetrics does nothing: the runtime/metrics package requires Go 1.16.
+func (c *Client) RuntimeMetrics(names ...string) {}
@@ -0,0 +1,42 @@
+//go:build go1.16
+// +build go1.16
+
+package statsd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeMetrics(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.RuntimeMetrics("/gc/cycles/total:gc-cycles", "/sched/goroutines:goroutines", "/gc/pauses:seconds", "/unknown:bytes")
+		runtime.GC()
+		c.conn.poll()
+
+		var cycles, goroutines, pauses bool
+		for _, line := range c.PendingLines() {
+			switch {
+			case strings.HasPrefix(line, "runtime.gc.cycles.total.gc_cycles:") && strings.HasSuffix(line, "|c"):
+				cycles = true
+			case strings.HasPrefix(line, "runtime.sched.goroutines.goroutines:") && strings.HasSuffix(line, "|g"):
+				goroutines = true
+			case strings.HasPrefix(line, "runtime.gc.pauses.seconds:") && strings.Contains(line, "|ms"):
+				pauses = true
+			default:
+				t.Errorf("unexpected metric %q", line)
+			}
+		}
+		if !cycles || !goroutines || !pauses {
+			t.Errorf("PendingLines() = %q, want the GC cycles, goroutines and GC pauses", c.PendingLines())
+		}
+		c.Close()
+	})
+}
+
+func TestRuntimeBucket(t *testing.T) {
+	if got, want := runtimeBucket("/gc/heap/goal:bytes"), "runtime.gc.heap.goal.bytes"; got != want {
+		t.Errorf("runtimeBucket() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package statsd
+
+import (
+	"strconv"
+	"sync"
+)
+
+// A Serializer appends the wire representation of metrics to the buffer of a
+// Client.
+//
+// AppendMetric appends the metric m to buf and returns the extended buffer.
+// Every line appended must be terminated by '\n'. It is called with the
+// connection locked, so it does not have to be safe for concurrent use unless
+// the same Serializer is shared by several Clients.
+type Serializer interface {
+	AppendMetric(buf []byte, m *Metric) []byte
+}
+
+// StatsDSerializer is the Serializer of the StatsD line format, optionally
+// with the InfluxDB or Datadog tags. It is the Serializer used by default.
+type StatsDSerializer struct {
+	// TagFormat is the format the tags of the metrics have been rendered in.
+	TagFormat TagFormat
+	// ContainerID is sent with the DogStatsD "|c:" field if it is not empty.
+	ContainerID string
+	// SignedGauges disables sending a zero gauge before a negative gauge.
+	SignedGauges bool
+	// CacheSize is the number of series whose lines are cached without their
+	// value, so that the lines of the hot series are rendered only once. The
+	// cache is cleared when it is full. A zero size disables the cache.
+	CacheSize int
+
+	// mu guards cache: a StatsDSerializer may be shared by several Clients.
+	mu    sync.Mutex
+	cache map[lineKey]cachedLine
+}
+
+// newSerializer returns the default Serializer: a StatsDSerializer configured
+// by the options.
+func newSerializer(conf connConfig) Serializer {
+	s := &StatsDSerializer{
+		TagFormat:    conf.TagFormat,
+		ContainerID:  conf.ContainerID,
+		SignedGauges: conf.SignedGauges,
+		CacheSize:    conf.LineCacheSize,
+	}
+	if s.ContainerID == "" && conf.DetectContainerID {
+		s.ContainerID = detectContainerID()
+	}
+	return s
+}
+
+// AppendMetric implements the Serializer interface.
+func (s *StatsDSerializer) AppendMetric(buf []byte, m *Metric) []byte {
+	// To set a gauge to a negative value we must first set it to 0.
+	// https://github.com/etsy/statsd/blob/master/docs/metric_types.md#gauges
+	if m.Type == GAUGE && !m.Delta && !s.SignedGauges && isNegative(m.Value) {
+		buf = s.appendLine(buf, m, 0)
+	}
+	return s.appendLine(buf, m, m.Value)
+}
+
+func (s *StatsDSerializer) appendLine(buf []byte, m *Metric, value interface{}) []byte {
+	if s.CacheSize > 0 {
+		l := s.line(m)
+		buf = append(buf, l.b[:l.split]...)
+		buf = s.appendValue(buf, m, value)
+		buf = append(buf, l.b[l.split:]...)
+	} else {
+		buf = s.appendName(buf, m)
+		buf = s.appendValue(buf, m, value)
+		buf = s.appendType(buf, m)
+	}
+	if !m.Timestamp.IsZero() {
+		buf = append(buf, "|T"...)
+		buf = strconv.AppendInt(buf, m.Timestamp.Unix(), 10)
+	}
+	if s.ContainerID != "" {
+		buf = append(buf, "|c:"...)
+		buf = append(buf, s.ContainerID...)
+	}
+	return append(buf, '\n')
+}
+
+// appendName appends the part of the line before the value: the name of the
+// metric and its InfluxDB tags.
+func (s *StatsDSerializer) appendName(buf []byte, m *Metric) []byte {
+	buf = append(buf, m.Prefix...)
+	buf = append(buf, m.Bucket...)
+	if s.TagFormat == InfluxDB {
+		buf = append(buf, m.Tags...)
+	}
+	return append(buf, ':')
+}
+
+func (s *StatsDSerializer) appendValue(buf []byte, m *Metric, value interface{}) []byte {
+	if v, ok := value.(string); ok && (m.Type == SET || m.Type == CUSTOM) {
+		return append(buf, v...)
+	}
+	if m.Delta && m.Type == GAUGE && !isNegative(value) {
+		buf = append(buf, '+')
+	}
+	return appendNumber(buf, value)
+}
+
+// appendType appends the part of the line after the value: the type of the
+// metric, its sample rate and its Datadog tags.
+func (s *StatsDSerializer) appendType(buf []byte, m *Metric) []byte {
+	buf = append(buf, m.suffix()...)
+	switch m.Type {
+	case COUNT, TIMINGS, HISTOGRAM, CUSTOM:
+		if m.Rate != 1 {
+			buf = append(buf, "|@"...)
+			buf = strconv.AppendFloat(buf, float64(m.Rate), 'f', -1, 32)
+		}
+	}
+	if s.TagFormat == Datadog {
+		buf = append(buf, m.Tags...)
+	}
+	return buf
+}
+
+func appendNumber(buf []byte, v interface{}) []byte {
+	switch n := v.(type) {
+	case int:
+		return strconv.AppendInt(buf, int64(n), 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(n), 10)
+	case int64:
+		return strconv.AppendInt(buf, n, 10)
+	case uint64:
+		return strconv.AppendUint(buf, n, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(n), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(n), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(n), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(n), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(n), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(n), 10)
+	case float64:
+		return strconv.AppendFloat(buf, n, 'f', -1, 64)
+	case float32:
+		return strconv.AppendFloat(buf, float64(n), 'f', -1, 32)
+	case []float64:
+		// Packed values, as supported by DogStatsD.
+		for i, f := range n {
+			if i > 0 {
+				buf = append(buf, ':')
+			}
+			buf = strconv.AppendFloat(buf, f, 'f', -1, 64)
+		}
+	}
+	return buf
+}
+
+func isNegative(v interface{}) bool {
+	switch n := v.(type) {
+	case int:
+		return n < 0
+	case uint:
+		return n < 0
+	case int64:
+		return n < 0
+	case uint64:
+		return n < 0
+	case int32:
+		return n < 0
+	case uint32:
+		return n < 0
+	case int16:
+		return n < 0
+	case uint16:
+		return n < 0
+	case int8:
+		return n < 0
+	case uint8:
+		return n < 0
+	case float64:
+		return n < 0
+	case float32:
+		return n < 0
+	}
+	return false
+}
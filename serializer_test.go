@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// kvSerializer is a statsite-like Serializer sending the metrics as key/value
+// pairs.
+type kvSerializer struct{}
+
+func (kvSerializer) AppendMetric(buf []byte, m *Metric) []byte {
+	buf = append(buf, m.Prefix...)
+	buf = append(buf, m.Bucket...)
+	buf = append(buf, ':')
+	buf = appendNumber(buf, m.Value)
+	buf = append(buf, "|kv|"...)
+	buf = strconv.AppendInt(buf, int64(m.Type), 10)
+	return append(buf, '\n')
+}
+
+func TestWireFormat(t *testing.T) {
+	testOutput(t, "app.test_key:5|kv|0\napp.test_key:-3|kv|1", func(c *Client) {
+		c.Count(testKey, 5)
+		c.Gauge(testKey, -3)
+	}, Prefix("app"), WireFormat(kvSerializer{}))
+}
+
+func TestStatsDSerializer(t *testing.T) {
+	s := &StatsDSerializer{TagFormat: Datadog, ContainerID: "abc"}
+	m := &Metric{Type: GAUGE, Prefix: "app.", Bucket: testKey, Value: -2, Rate: 0.5, Tags: "|#tag1:value1"}
+	got := string(s.AppendMetric(nil, m))
+	want := "app.test_key:0|g|#tag1:value1|c:abc\napp.test_key:-2|g|#tag1:value1|c:abc\n"
+	if got != want {
+		t.Errorf("AppendMetric() = %q, want %q", got, want)
+	}
+
+	s = &StatsDSerializer{TagFormat: InfluxDB, SignedGauges: true}
+	m = &Metric{Type: HISTOGRAM, Bucket: testKey, Value: 1.5, Rate: 0.5, Tags: ",tag1=value1"}
+	got = string(s.AppendMetric([]byte("x\n"), m))
+	want = "x\ntest_key,tag1=value1:1.5|h|@0.5\n"
+	if got != want {
+		t.Errorf("AppendMetric() = %q, want %q", got, want)
+	}
+}
+
+func TestLineCache(t *testing.T) {
+	metrics := []*Metric{
+		{Type: COUNT, Prefix: "app.", Bucket: testKey, Value: 1, Rate: 1, Tags: "|#tag1:value1"},
+		{Type: COUNT, Prefix: "app.", Bucket: testKey, Value: 2, Rate: 0.5, Tags: "|#tag1:value1"},
+		{Type: GAUGE, Prefix: "app.", Bucket: testKey, Value: -2, Rate: 1, Tags: "|#tag1:value1"},
+		{Type: SET, Bucket: testKey, Value: "foo", Rate: 1},
+		{Type: CUSTOM, CustomType: "kv", Bucket: testKey, Value: 3, Rate: 1},
+		{Type: COUNT, Prefix: "app.", Bucket: testKey, Value: 3, Rate: 1, Tags: "|#tag1:value1", Timestamp: testDate},
+	}
+	for _, tf := range []TagFormat{InfluxDB, Datadog} {
+		s := &StatsDSerializer{TagFormat: tf, ContainerID: "abc"}
+		cached := &StatsDSerializer{TagFormat: tf, ContainerID: "abc", CacheSize: 2}
+		for i := 0; i < 2; i++ {
+			for _, m := range metrics {
+				got := string(cached.AppendMetric(nil, m))
+				want := string(s.AppendMetric(nil, m))
+				if got != want {
+					t.Errorf("AppendMetric() = %q, want %q", got, want)
+				}
+				if len(cached.cache) > cached.CacheSize {
+					t.Errorf("the cache holds %d lines, want at most %d", len(cached.cache), cached.CacheSize)
+				}
+			}
+		}
+	}
+}
+
+func TestLineCacheConcurrent(t *testing.T) {
+	s := &StatsDSerializer{CacheSize: 10}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m := &Metric{Type: COUNT, Bucket: "key" + strconv.Itoa((i+j)%20), Value: 1, Rate: 1}
+				if got, want := string(s.AppendMetric(nil, m)), m.Bucket+":1|c\n"; got != want {
+					t.Errorf("AppendMetric() = %q, want %q", got, want)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLineCacheOption(t *testing.T) {
+	testOutput(t, "test_key:1|c\ntest_key:2|c\nfoo:1|g", func(c *Client) {
+		c.Count(testKey, 1)
+		c.Count(testKey, 2)
+		c.Gauge("foo", 1)
+	}, LineCache(100))
+}
@@ -0,0 +1,95 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+)
+
+// A ddSketch is a DDSketch: a quantile sketch with relative-error guarantees
+// whose memory only grows with the logarithm of the range of the values. See
+// https://arxiv.org/abs/1908.10693.
+type ddSketch struct {
+	gamma    float64
+	logGamma float64
+	pos      map[int]float64 // Counts of the positive values by bin index.
+	neg      map[int]float64 // Counts of the negative values by bin index.
+	zero     float64
+	count    float64
+}
+
+// minSketchValue is the smallest magnitude not counted as zero.
+const minSketchValue = 1e-9
+
+// newDDSketch returns a sketch whose quantiles have the given relative
+// accuracy, e.g. 0.01 for 1%.
+func newDDSketch(accuracy float64) *ddSketch {
+	gamma := (1 + accuracy) / (1 - accuracy)
+	return &ddSketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		pos:      make(map[int]float64),
+		neg:      make(map[int]float64),
+	}
+}
+
+// add adds the value v with the weight w.
+func (s *ddSketch) add(v, w float64) {
+	s.count += w
+	switch {
+	case v > minSketchValue:
+		s.pos[s.index(v)] += w
+	case v < -minSketchValue:
+		s.neg[s.index(-v)] += w
+	default:
+		s.zero += w
+	}
+}
+
+func (s *ddSketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the value representing the bin i.
+func (s *ddSketch) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// quantile returns the q-quantile of the values, q being between 0 and 1.
+func (s *ddSketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := q * (s.count - 1)
+
+	var n float64
+	for _, i := range sortedBins(s.neg, true) {
+		if n += s.neg[i]; n > rank {
+			return -s.value(i)
+		}
+	}
+	if n += s.zero; n > rank {
+		return 0
+	}
+	bins := sortedBins(s.pos, false)
+	for _, i := range bins {
+		if n += s.pos[i]; n > rank {
+			return s.value(i)
+		}
+	}
+	return s.value(bins[len(bins)-1])
+}
+
+// sortedBins returns the indexes of the bins in ascending order, or
+// descending order if desc is true.
+func sortedBins(bins map[int]float64, desc bool) []int {
+	idx := make([]int, 0, len(bins))
+	for i := range bins {
+		idx = append(idx, i)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.IntSlice(idx)))
+	} else {
+		sort.Ints(idx)
+	}
+	return idx
+}
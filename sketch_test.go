@@ -0,0 +1,61 @@
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDDSketchQuantile(t *testing.T) {
+	s := newDDSketch(0.01)
+	for i := 1; i <= 1000; i++ {
+		s.add(float64(i), 1)
+	}
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		want := 1 + q*999
+		if got := s.quantile(q); math.Abs(got-want) > 0.01*want+1 {
+			t.Errorf("quantile(%v) = %v, want %v ± 1%%", q, got, want)
+		}
+	}
+}
+
+func TestDDSketchNegativeAndZero(t *testing.T) {
+	s := newDDSketch(0.01)
+	for _, v := range []float64{-10, -1, 0, 0, 5} {
+		s.add(v, 1)
+	}
+	tests := map[float64]float64{0: -10, 0.25: -1, 0.5: 0, 0.75: 0, 1: 5}
+	for q, want := range tests {
+		if got := s.quantile(q); math.Abs(got-want) > 0.01*math.Abs(want) {
+			t.Errorf("quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestSketches(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Timing(testKey, 3)
+		for i := 1; i <= 100; i++ {
+			c.Histogram(testKey, i)
+		}
+		c.conn.mu.Lock()
+		c.conn.flushAggregates()
+		c.conn.mu.Unlock()
+
+		lines := c.PendingLines()
+		want := []string{"test_key:3|ms", "test_key.count:100|c", "test_key.min:1|g", "test_key.max:100|g", "test_key.sum:5050|g", "test_key.avg:50.5|g"}
+		if len(lines) != len(want)+1 {
+			t.Fatalf("PendingLines() = %q", lines)
+		}
+		for i, w := range want {
+			if lines[i] != w {
+				t.Errorf("line %d = %q, want %q", i, lines[i], w)
+			}
+		}
+		var p50 float64
+		if _, err := fmt.Sscanf(lines[len(want)], "test_key.p50:%g|g", &p50); err != nil || math.Abs(p50-50) > 1 {
+			t.Errorf("p50 = %q, want about 50", lines[len(want)])
+		}
+		c.Close()
+	}, Sketches(0.01, 50))
+}
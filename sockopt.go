@@ -0,0 +1,31 @@
+package statsd
+
+// noDelaySetter is implemented by the TCP connections.
+type noDelaySetter interface {
+	SetNoDelay(noDelay bool) error
+}
+
+// writeBufferSetter is implemented by the TCP, UDP and unix connections.
+type writeBufferSetter interface {
+	SetWriteBuffer(bytes int) error
+}
+
+// setSockopts applies the socket options of the connection to the freshly
+// dialed c.w. The options which are not supported by the connection are
+// ignored.
+func (c *conn) setSockopts() error {
+	if s, ok := c.w.(noDelaySetter); ok {
+		if err := s.SetNoDelay(c.noDelay); err != nil {
+			return err
+		}
+	}
+	if s, ok := c.w.(writeBufferSetter); ok && c.sendBufferSize > 0 {
+		if err := s.SetWriteBuffer(c.sendBufferSize); err != nil {
+			return err
+		}
+	}
+	if c.trafficClass > 0 {
+		return setTrafficClass(c.w, c.trafficClass)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+type tunableBuffer struct {
+	testBuffer
+	noDelay     bool
+	writeBuffer int
+}
+
+func (c *tunableBuffer) SetNoDelay(noDelay bool) error {
+	c.noDelay = noDelay
+	return nil
+}
+
+func (c *tunableBuffer) SetWriteBuffer(bytes int) error {
+	c.writeBuffer = bytes
+	return nil
+}
+
+func TestSockopts(t *testing.T) {
+	tests := []struct {
+		opts        []Option
+		noDelay     bool
+		writeBuffer int
+	}{
+		{nil, true, 0},
+		{[]Option{TCPNoDelay(false)}, false, 0},
+		{[]Option{SendBufferSize(1 << 20)}, true, 1 << 20},
+	}
+	for _, test := range tests {
+		var w *tunableBuffer
+		dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+			w = &tunableBuffer{}
+			return w, nil
+		}
+		c, err := New(append([]Option{Network("tcp"), FlushPeriod(0)}, test.opts...)...)
+		dialTimeout = net.DialTimeout
+		if err != nil {
+			t.Fatalf("New() = %v", err)
+		}
+		if w.noDelay != test.noDelay {
+			t.Errorf("noDelay = %t, want %t", w.noDelay, test.noDelay)
+		}
+		if w.writeBuffer != test.writeBuffer {
+			t.Errorf("writeBuffer = %d, want %d", w.writeBuffer, test.writeBuffer)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,105 @@
+package statsd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// ErrSpoolFull is reported to the ErrorHandler when a payload cannot be
+// spooled because the spool file has reached its maximum size.
+var ErrSpoolFull = errors.New("statsd: spool is full")
+
+// spoolHeaderSize is the size of the header of a spooled payload: its time as
+// Unix nanoseconds and its length, both big-endian.
+const spoolHeaderSize = 8 + 4
+
+// A spool is a bounded on-disk journal of the payloads which could not be
+// sent. It is guarded by the write mutex of the connection.
+type spool struct {
+	f    *os.File
+	max  int64
+	size int64
+}
+
+// stashed is a payload which could not be sent, saved with its time and
+// priority.
+type stashed struct {
+	t       time.Time
+	payload []byte
+	prio    Priority
+}
+
+// openSpool opens the spool file at path, creating it if needed. The payloads
+// spooled by a previous process are kept so that they are replayed.
+func openSpool(path string, max int64) (*spool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &spool{f: f, max: max, size: size}, nil
+}
+
+// append appends the payload p measured at time t to the spool.
+func (s *spool) append(t time.Time, p []byte) error {
+	if s.size+spoolHeaderSize+int64(len(p)) > s.max {
+		return ErrSpoolFull
+	}
+	var header [spoolHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(p)))
+	if _, err := s.f.WriteAt(header[:], s.size); err != nil {
+		return err
+	}
+	if _, err := s.f.WriteAt(p, s.size+spoolHeaderSize); err != nil {
+		return err
+	}
+	s.size += spoolHeaderSize + int64(len(p))
+	return nil
+}
+
+// read returns all the spooled payloads. A truncated trailing payload, e.g.
+// after a crash, is ignored.
+func (s *spool) read() ([]stashed, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(s.f, 0, s.size))
+	if err != nil {
+		return nil, err
+	}
+	var records []stashed
+	for len(data) >= spoolHeaderSize {
+		t := time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+		n := int(binary.BigEndian.Uint32(data[8:spoolHeaderSize]))
+		if len(data) < spoolHeaderSize+n {
+			break
+		}
+		records = append(records, stashed{t: t, payload: data[spoolHeaderSize : spoolHeaderSize+n]})
+		data = data[spoolHeaderSize+n:]
+	}
+	return records, nil
+}
+
+// reset replaces the content of the spool with the given payloads.
+func (s *spool) reset(records []stashed) error {
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	s.size = 0
+	for _, r := range records {
+		if err := s.append(r.t, r.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *spool) close() error {
+	return s.f.Close()
+}
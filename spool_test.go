@@ -0,0 +1,173 @@
+package statsd
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool")
+
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	var errs []error
+	c, err := New(Spool(path, 60), FlushPeriod(0), ErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.Flush()
+	c.Count(testKey, 2)
+	c.Flush()
+	if metrics, _ := c.Pending(); metrics != 0 {
+		t.Errorf("Pending() = %d, want the spooled metrics to be removed from the buffer", metrics)
+	}
+	c.Count(testKey, 3)
+	c.Flush()
+	full := false
+	for _, err := range errs {
+		full = full || err == ErrSpoolFull
+	}
+	if !full {
+		t.Errorf("errors = %v, want %v", errs, ErrSpoolFull)
+	}
+
+	down = false
+	c.Count(testKey, 4)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:4|ctest_key:1|ctest_key:2|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	if c.conn.spool.size != 0 {
+		t.Errorf("spool size = %d, want 0", c.conn.spool.size)
+	}
+	c.Close()
+}
+
+func TestSpoolReplayAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool")
+
+	s, err := openSpool(path, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.append(testDate, []byte("test_key:1|c\n")); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+
+	testOutput(t, "test_key:2|c\ntest_key:1|c\n", func(c *Client) {
+		c.Count(testKey, 2)
+	}, Spool(path, 1024), Network("tcp"))
+}
+
+func TestSpoolOpenError(t *testing.T) {
+	c, err := New(Spool(filepath.Join("does", "not", "exist"), 1024))
+	if c != nil || err == nil {
+		t.Errorf("New() = %v, %v, want an error", c, err)
+	}
+}
+
+func TestBufferTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	clk := &fakeClock{t: testDate}
+	c, err := New(OverflowQueue(13), Spool(filepath.Join(dir, "spool"), 1000), BufferTTL(time.Minute), UseClock(clk), FlushPeriod(0))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	// Queued, then expired.
+	c.Increment(testKey)
+	c.Flush()
+	clk.Advance(time.Minute)
+	// Spooled, then expired.
+	c.Count(testKey, 2)
+	c.Flush()
+	clk.Advance(2 * time.Minute)
+	c.Count(testKey, 3)
+	c.Flush()
+
+	down = false
+	c.Count(testKey, 4)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:4|ctest_key:3|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	if got := c.Stats().Dropped; got != 2 {
+		t.Errorf("Stats().Dropped = %d, want 2", got)
+	}
+	c.Close()
+}
+
+func TestBufferTTLKeptPayload(t *testing.T) {
+	down := true
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		if down {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	clk := &fakeClock{t: testDate}
+	c, err := New(BufferTTL(time.Minute), UseClock(clk), FlushPeriod(0), ErrorHandler(func(error) {}))
+	if c == nil {
+		t.Fatalf("New() = %v", err)
+	}
+	// Kept in the buffer, then expired.
+	c.Increment(testKey)
+	c.Flush()
+	clk.Advance(2 * time.Minute)
+	// Kept in the buffer.
+	c.Count(testKey, 2)
+	c.Flush()
+	clk.Advance(30 * time.Second)
+
+	down = false
+	c.Count(testKey, 3)
+	c.Flush()
+	if got, want := getOutput(c), "test_key:2|c\ntest_key:3|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+	if got := c.Stats().Dropped; got != 1 {
+		t.Errorf("Stats().Dropped = %d, want 1", got)
+	}
+	c.Close()
+}
@@ -0,0 +1,64 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resolveSRV returns the addresses of the targets of the SRV record name with
+// the highest priority, i.e. the lowest priority value.
+func resolveSRV(name string) ([]string, error) {
+	_, srvs, err := lookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("statsd: no SRV record for %s", name)
+	}
+	// The records are sorted by priority.
+	var addrs []string
+	for _, srv := range srvs {
+		if srv.Priority != srvs[0].Priority {
+			break
+		}
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// refreshSRVLoop periodically resolves the SRV record set by the SRV option
+// and updates the shards until the connection is closed.
+func (c *conn) refreshSRVLoop() {
+	ticker := c.clock.NewTicker(c.srvConf.SRVRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C():
+		}
+		c.refreshSRV()
+	}
+}
+
+// refreshSRV resolves the SRV record set by the SRV option and updates the
+// shards. The shards are kept if the resolution fails.
+func (c *conn) refreshSRV() {
+	addrs, err := resolveSRV(c.srvConf.SRV)
+	if err == nil {
+		err = c.setShards(c.srvConf, addrs)
+	}
+	if err != nil {
+		c.wmu.Lock()
+		c.handleError(err)
+		c.wmu.Unlock()
+	}
+}
+
+// Stubbed out for testing.
+var lookupSRV = net.LookupSRV
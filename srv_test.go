@@ -0,0 +1,87 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "b.example.com.", Port: 8125, Priority: 10},
+		{Target: "a.example.com.", Port: 8125, Priority: 10},
+		{Target: "backup.example.com.", Port: 8125, Priority: 20},
+	}
+	lookupSRV = func(_, _, name string) (string, []*net.SRV, error) {
+		if name != "_statsd._udp.example.com" {
+			return "", nil, errors.New("no such host")
+		}
+		return "", records, nil
+	}
+	dialTimeout = mockDial
+	defer func() {
+		lookupSRV = net.LookupSRV
+		dialTimeout = net.DialTimeout
+	}()
+
+	c, err := New(FlushPeriod(0), SRV("_statsd._udp.example.com"), SRVRefresh(time.Hour))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+	if got, want := shardAddrs(c), []string{"a.example.com:8125", "b.example.com:8125"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shards = %q, want %q", got, want)
+	}
+
+	records = append(records[:1], &net.SRV{Target: "c.example.com.", Port: 8126, Priority: 10})
+	c.conn.refreshSRV()
+	if got, want := shardAddrs(c), []string{"b.example.com:8125", "c.example.com:8126"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shards = %q, want %q", got, want)
+	}
+	// The parent connection is not in the ring anymore.
+	for i := 0; i < 100; i++ {
+		m := &Metric{Bucket: string(rune('a'+i%26)) + string(rune('a'+i/26))}
+		if c.conn.pick(m) == c.conn {
+			t.Fatalf("%s sent to %s which is not a target anymore", m.Bucket, c.conn.addr)
+		}
+	}
+}
+
+func TestSRVFailure(t *testing.T) {
+	lookupSRV = func(string, string, string) (string, []*net.SRV, error) {
+		return "", nil, errors.New("no such host")
+	}
+	dialTimeout = mockDial
+	defer func() {
+		lookupSRV = net.LookupSRV
+		dialTimeout = net.DialTimeout
+	}()
+
+	c, err := New(FlushPeriod(0), SRV("_statsd._udp.example.com"))
+	if err == nil {
+		t.Error("New() should return the resolution error")
+	}
+	if c.conn.addr != ":8125" {
+		t.Errorf("addr = %q, want the default address", c.conn.addr)
+	}
+	c.Close()
+}
+
+// shardAddrs returns the sorted addresses of the daemons metrics are sent to.
+func shardAddrs(c *Client) []string {
+	seen := map[string]bool{}
+	c.conn.routeMu.RLock()
+	for _, p := range c.conn.ring.points {
+		seen[p.conn.addr] = true
+	}
+	c.conn.routeMu.RUnlock()
+	var addrs []string
+	for addr := range seen {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
@@ -0,0 +1,111 @@
+package statsd
+
+import "time"
+
+// Stats are the internal statistics of the connection of a Client, which is
+// shared by its clones.
+type Stats struct {
+	// PacketsSent is the number of payloads sent.
+	PacketsSent int64
+	// BytesSent is the number of bytes sent.
+	BytesSent int64
+	// Errors is the number of errors, e.g. connection or write errors.
+	Errors int64
+
+	// QueueBytes is the number of bytes in the overflow queue.
+	QueueBytes int
+	// QueueHighWater is the maximum number of bytes the overflow queue has
+	// held.
+	QueueHighWater int
+	// Dropped is the number of metrics dropped because they could not be sent
+	// nor queued or spooled.
+	Dropped int64
+
+	// AutoMutes is the number of times the AutoMute option muted the Client.
+	AutoMutes int64
+
+	// AggregatedSamples is the number of metrics merged by the aggregation
+	// options, e.g. TimingSummaries.
+	AggregatedSamples int64
+	// AggregatedSeries is the number of series tracked by the aggregation
+	// options in the current flush period.
+	AggregatedSeries int
+	// AggregationFlushDuration is how long sending the aggregates of the last
+	// flush period took.
+	AggregationFlushDuration time.Duration
+
+	// TopSeries are the series with the most metrics during the last window
+	// of the TopSeries option, by decreasing count.
+	TopSeries []SeriesCount
+}
+
+// Stats returns the internal statistics of the Client.
+func (c *Client) Stats() Stats {
+	var s Stats
+	var tops [][]SeriesCount
+	for _, cn := range c.conn.conns() {
+		cn.mu.Lock()
+		cn.wmu.Lock()
+		if cn.top != nil {
+			cn.top.rotate(cn.clock.Now())
+			tops = append(tops, cn.top.last)
+		}
+		s.add(cn.stats)
+		s.add(cn.wstats)
+		s.QueueBytes += cn.queueSize
+		if cn.agg != nil {
+			s.AggregatedSeries += len(cn.agg.series)
+		}
+		cn.wmu.Unlock()
+		cn.mu.Unlock()
+	}
+	if len(tops) > 0 {
+		s.TopSeries = mergeTop(tops, c.conn.topK)
+	}
+	return s
+}
+
+// add adds the statistics of another connection to s.
+func (s *Stats) add(o Stats) {
+	s.PacketsSent += o.PacketsSent
+	s.BytesSent += o.BytesSent
+	s.Errors += o.Errors
+	s.QueueBytes += o.QueueBytes
+	s.QueueHighWater += o.QueueHighWater
+	s.Dropped += o.Dropped
+	s.AutoMutes += o.AutoMutes
+	s.AggregatedSamples += o.AggregatedSamples
+	s.AggregatedSeries += o.AggregatedSeries
+	if o.AggregationFlushDuration > s.AggregationFlushDuration {
+		s.AggregationFlushDuration = o.AggregationFlushDuration
+	}
+}
+
+// telemetryLoop sends the statistics of the Client through tc every interval
+// until the Client is closed.
+func (c *Client) telemetryLoop(tc *Client, interval time.Duration) {
+	ticker := c.conn.clock.NewTicker(interval)
+	defer ticker.Stop()
+	var last Stats
+	for {
+		select {
+		case <-c.conn.done:
+			return
+		case <-ticker.C():
+		}
+		last = c.sendTelemetry(tc, last)
+	}
+}
+
+// sendTelemetry sends through tc the statistics of the Client which changed
+// since last and returns the current statistics.
+func (c *Client) sendTelemetry(tc *Client, last Stats) Stats {
+	s := c.Stats()
+	tc.Count("packets_sent", s.PacketsSent-last.PacketsSent)
+	tc.Count("bytes_sent", s.BytesSent-last.BytesSent)
+	tc.Count("errors", s.Errors-last.Errors)
+	tc.Count("metrics_dropped", s.Dropped-last.Dropped)
+	tc.Count("auto_mutes", s.AutoMutes-last.AutoMutes)
+	tc.Gauge("queue_bytes", s.QueueBytes)
+	return s
+}
@@ -0,0 +1,58 @@
+package statsd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatsCounters(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Increment(testKey)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+		getBuffer(c).err = errors.New("test error")
+		c.Increment(testKey)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+
+		s := c.Stats()
+		if s.PacketsSent != 1 || s.BytesSent != 12 || s.Errors != 1 || s.Dropped != 1 {
+			t.Errorf("Stats() = %+v, want 1 packet of 12 bytes, 1 error and 1 dropped metric", s)
+		}
+	}, ErrorHandler(func(error) {}))
+}
+
+func TestClientTelemetry(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Increment(testKey)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+
+		tc := c.Clone(ReplacePrefix("statsd.client."))
+		last := c.sendTelemetry(tc, Stats{})
+		want := []string{
+			"statsd.client.packets_sent:1|c",
+			"statsd.client.bytes_sent:16|c",
+			"statsd.client.errors:0|c",
+			"statsd.client.metrics_dropped:0|c",
+			"statsd.client.auto_mutes:0|c",
+			"statsd.client.queue_bytes:0|g",
+		}
+		got := c.PendingLines()
+		if len(got) != len(want) {
+			t.Fatalf("PendingLines() = %q, want %q", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("PendingLines()[%d] = %q, want %q", i, got[i], want[i])
+			}
+		}
+		if last.PacketsSent != 1 {
+			t.Errorf("sendTelemetry() = %+v, want the current stats", last)
+		}
+	}, Prefix("app."), ClientTelemetry(time.Hour, "statsd.client."))
+}
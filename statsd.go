@@ -1,18 +1,64 @@
 package statsd
 
-import "time"
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"time"
+)
 
 // A Client represents a StatsD client.
 type Client struct {
-	conn   *conn
-	muted  bool
-	rate   float32
-	prefix string
-	tags   string
+	conn       *conn
+	muted      bool
+	rate       float32
+	rates      map[Type]float32
+	prefix     string
+	tags       string
+	tagList    []tag // the tags rendered in tags, not to parse them again
+	hooks      []func(*Metric) bool
+	priority   Priority
+	timingUnit TimeUnit
 }
 
-// New returns a new Client  (error is connection error and might be temporary)
+// New returns a new Client. If the options are invalid, New returns an error
+// without a Client. Otherwise, the error is a connection error which might be
+// temporary and the returned Client is usable.
 func New(opts ...Option) (*Client, error) {
+	conf, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := newConn(conf.Conn, conf.Client.Muted)
+	if conn == nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:  conn,
+		muted: conf.Client.Muted,
+	}
+	c.rate = conf.Client.Rate
+	c.rates = conf.Client.Rates
+	c.prefix = conf.Client.Prefix
+	c.tagList = conn.effectiveTags(conf.Client.Tags)
+	c.tags = joinTags(conn.tagFormat, c.tagList)
+	c.hooks = conf.Client.Hooks
+	c.priority = conf.Client.Priority
+	c.timingUnit = conf.Client.TimingUnit
+	if len(conf.Client.Tags) > 0 && conf.Conn.TagFormat == 0 {
+		conn.logf("the tags are ignored because no TagsFormat is set")
+	}
+	if conf.Conn.TelemetryInterval > 0 && !c.muted {
+		go c.telemetryLoop(c.Clone(ReplacePrefix(conf.Conn.TelemetryPrefix)), conf.Conn.TelemetryInterval)
+	}
+	return c, err
+}
+
+// newConfig returns the configuration set by the options or a *ConfigError if
+// it is invalid.
+func newConfig(opts []Option) (*config, error) {
 	// The default configuration.
 	conf := &config{
 		Client: clientConfig{
@@ -23,28 +69,32 @@ func New(opts ...Option) (*Client, error) {
 			FlushPeriod: 100 * time.Millisecond,
 			Network:     "udp",
 			Timeout:     5 * time.Second,
+			UDPCheck:    true,
+			TCPNoDelay:  true,
+			SRVRefresh:  30 * time.Second,
 		},
 	}
-	// Worst-case scenario:
-	// Ethernet MTU - IPv6 Header - TCP Header = 1500 - 40 - 20 = 1440
-	if conf.Conn.Network == "udp" {
-		conf.Conn.MaxPacketSize = 1000
-	} else {
-		conf.Conn.MaxPacketSize = 1440
-	}
 	for _, o := range opts {
 		o(conf)
 	}
-
-	conn, err := newConn(conf.Conn, conf.Client.Muted)
-	c := &Client{
-		conn:  conn,
-		muted: conf.Client.Muted,
+	if err := conf.validate(); err != nil {
+		return nil, err
 	}
-	c.rate = conf.Client.Rate
-	c.prefix = conf.Client.Prefix
-	c.tags = joinTags(conf.Conn.TagFormat, conf.Client.Tags)
-	return c, err
+	if !conf.Conn.DialTimeoutSet {
+		conf.Conn.DialTimeout = conf.Conn.Timeout
+	}
+	if !conf.Conn.WriteTimeoutSet {
+		conf.Conn.WriteTimeout = conf.Conn.Timeout
+	}
+	if !conf.Conn.MaxPacketSizeSet {
+		conf.Conn.MaxPacketSize = defaultPacketSize(conf.Conn)
+	}
+	if conf.Client.EntityIDTag {
+		if id := os.Getenv(entityIDEnv); id != "" {
+			conf.Client.Tags = setTag(conf.Client.Tags, entityIDTag, id)
+		}
+	}
+	return conf, nil
 }
 
 // Clone returns a clone of the Client. The cloned Client inherits its
@@ -53,73 +103,187 @@ func New(opts ...Option) (*Client, error) {
 // All cloned Clients share the same connection, so cloning a Client is a cheap
 // operation.
 func (c *Client) Clone(opts ...Option) *Client {
-	tf := c.conn.tagFormat
+	// The capacity of the tags is limited so that the options do not append
+	// to the tags of c.
 	conf := &config{
 		Client: clientConfig{
-			Rate:   c.rate,
-			Prefix: c.prefix,
-			Tags:   splitTags(tf, c.tags),
+			Rate:       c.rate,
+			Rates:      c.rates,
+			Prefix:     c.prefix,
+			Tags:       c.tagList[:len(c.tagList):len(c.tagList)],
+			Hooks:      c.hooks,
+			Priority:   c.priority,
+			TimingUnit: c.timingUnit,
 		},
 	}
 	for _, o := range opts {
 		o(conf)
 	}
 
+	tagList := c.conn.effectiveTags(conf.Client.Tags)
 	clone := &Client{
-		conn:   c.conn,
-		muted:  c.muted || conf.Client.Muted,
-		rate:   conf.Client.Rate,
-		prefix: conf.Client.Prefix,
-		tags:   joinTags(tf, conf.Client.Tags),
+		conn:       c.conn,
+		muted:      c.muted || conf.Client.Muted,
+		rate:       conf.Client.Rate,
+		rates:      conf.Client.Rates,
+		prefix:     conf.Client.Prefix,
+		tags:       joinTags(c.conn.tagFormat, tagList),
+		tagList:    tagList,
+		hooks:      conf.Client.Hooks,
+		priority:   conf.Client.Priority,
+		timingUnit: conf.Client.TimingUnit,
 	}
 	clone.conn = c.conn
 	return clone
 }
 
+// CloneStatter returns c.Clone(opts...) as a Statter.
+func (c *Client) CloneStatter(opts ...Option) Statter {
+	return c.Clone(opts...)
+}
+
+// WithTags returns a Client deriving from c which appends the given tags, as
+// key/value pairs, to the tags of c. It is a lightweight alternative to Clone
+// suited to per-request clients: the tags are rendered once and appended to
+// the rendered tags of c unless they have to be deduplicated or sorted.
+func (c *Client) WithTags(tags ...string) *Client {
+	if len(tags)%2 != 0 {
+		panic("statsd: WithTags only accepts an even number of arguments")
+	}
+	tf := c.conn.tagFormat
+	fast := !c.conn.sortTags
+	pairs := make([]tag, 0, len(tags)/2)
+	for i := 0; i < len(tags); i += 2 {
+		k := tags[i]
+		if fast && c.conn.tagPolicy != KeepAll && (hasTag(tf, c.tags, k) || hasKey(pairs, k)) {
+			fast = false
+		}
+		pairs = append(pairs, tag{K: k, V: tags[i+1]})
+	}
+	d := *c
+	if fast {
+		d.tagList = append(c.tagList[:len(c.tagList):len(c.tagList)], pairs...)
+		d.tags = appendTags(tf, c.tags, pairs)
+	} else {
+		d.tagList = c.conn.effectiveTags(append(c.tagList[:len(c.tagList):len(c.tagList)], pairs...))
+		d.tags = joinTags(tf, d.tagList)
+	}
+	return &d
+}
+
+// WithPrefix returns a Client deriving from c which appends p to the prefix of
+// c, expanded and followed by a dot as with the Prefix option. It is a
+// lightweight alternative to Clone(Prefix(p)).
+func (c *Client) WithPrefix(p string) *Client {
+	d := *c
+	d.prefix += normalizePrefix(p)
+	return &d
+}
+
 // Count adds n to bucket.
-func (c *Client) Count(bucket string, n interface{}) {
-	if c.skip() {
+func (c *Client) Count(bucket string, n interface{}, opts ...MetricOption) {
+	c.send(COUNT, bucket, n, opts)
+}
+
+// send builds the metric and hands it to the connection unless it is skipped.
+func (c *Client) send(t Type, bucket string, value interface{}, opts []MetricOption) {
+	rate := c.rateFor(t)
+	if c.skip(rate) {
 		return
 	}
-	c.conn.metric(c.prefix, bucket, n, COUNT_S, c.rate, c.tags)
+	m := GetMetric()
+	m.Type = t
+	m.Prefix = c.prefix
+	m.Bucket = bucket
+	m.Value = value
+	m.Rate = rate
+	m.Tags = c.tags
+	m.Priority = c.priority
+	for _, o := range opts {
+		o(m)
+	}
+	c.emit(m)
+}
+
+// Send sends the given metric. The prefix, tags, sample rate and priority of
+// the Client are used unless the Prefix, Tags, Rate and Priority fields of the
+// metric are set.
+//
+// Send does not modify m, so the same metric can be sent several times. Use
+// GetMetric and Metric.Release to reuse metrics without allocations.
+func (c *Client) Send(m *Metric) {
+	rate := m.Rate
+	if rate == 0 {
+		rate = c.rateFor(m.Type)
+	}
+	if c.skip(rate) {
+		return
+	}
+	sm := GetMetric()
+	*sm = *m
+	sm.Rate = rate
+	if sm.Prefix == "" {
+		sm.Prefix = c.prefix
+	}
+	if sm.Tags == "" {
+		sm.Tags = c.tags
+	}
+	if sm.Priority == 0 {
+		sm.Priority = c.priority
+	}
+	c.emit(sm)
 }
 
-func (c *Client) skip() bool {
-	return c.muted || (c.rate != 1 && randFloat() > c.rate)
+// emit runs the hooks on the pooled metric m, sends it unless a hook vetoed it
+// and releases it.
+func (c *Client) emit(m *Metric) {
+	m.tagFormat = c.conn.tagFormat
+	for _, h := range c.hooks {
+		if !h(m) {
+			m.Release()
+			return
+		}
+	}
+	c.conn.send(m)
+	m.Release()
+}
+
+// rateFor returns the sample rate of the metrics of the given type.
+func (c *Client) rateFor(t Type) float32 {
+	if rate, ok := c.rates[t]; ok {
+		return rate
+	}
+	return c.rate
+}
+
+func (c *Client) skip(rate float32) bool {
+	return (c.muted && !c.conn.record) || (rate != 1 && randFloat() > rate)
 }
 
 // Increment increment the given bucket. It is equivalent to Count(bucket, 1).
-func (c *Client) Increment(bucket string) {
-	c.Count(bucket, 1)
+func (c *Client) Increment(bucket string, opts ...MetricOption) {
+	c.Count(bucket, 1, opts...)
 }
 
 // Decrement decrement the given bucket. It is equivalent to Count(bucket, -1).
-func (c *Client) Decrement(bucket string) {
-	c.Count(bucket, -1)
+func (c *Client) Decrement(bucket string, opts ...MetricOption) {
+	c.Count(bucket, -1, opts...)
 }
 
 // Gauge records an absolute value for the given bucket.
-func (c *Client) Gauge(bucket string, value interface{}) {
-	if c.skip() {
-		return
-	}
-	c.conn.gauge(c.prefix, bucket, value, c.tags)
+func (c *Client) Gauge(bucket string, value interface{}, opts ...MetricOption) {
+	c.send(GAUGE, bucket, value, opts)
 }
 
-// Timing sends a timing value to a bucket.
-func (c *Client) Timing(bucket string, value interface{}) {
-	if c.skip() {
-		return
-	}
-	c.conn.metric(c.prefix, bucket, value, TIMINGS_S, c.rate, c.tags)
+// Timing sends a timing value to a bucket. A time.Duration value is sent in
+// the unit set by TimingUnit.
+func (c *Client) Timing(bucket string, value interface{}, opts ...MetricOption) {
+	c.send(TIMINGS, bucket, c.timingValue(value), opts)
 }
 
 // Histogram sends an histogram value to a bucket.
-func (c *Client) Histogram(bucket string, value interface{}) {
-	if c.skip() {
-		return
-	}
-	c.conn.metric(c.prefix, bucket, value, HISTOGRAM_S, c.rate, c.tags)
+func (c *Client) Histogram(bucket string, value interface{}, opts ...MetricOption) {
+	c.send(HISTOGRAM, bucket, value, opts)
 }
 
 // A Timing is an helper object that eases sending timing values.
@@ -130,25 +294,135 @@ type Timing struct {
 
 // NewTiming creates a new Timing.
 func (c *Client) NewTiming() Timing {
-	return Timing{start: now(), c: c}
+	return Timing{start: c.conn.clock.Now(), c: c}
 }
 
-// Send sends the time elapsed since the creation of the Timing.
-func (t Timing) Send(bucket string) {
-	t.c.Timing(bucket, int(t.Duration()/time.Millisecond))
+// Send sends the time elapsed since the creation of the Timing, in the unit set
+// by TimingUnit.
+func (t Timing) Send(bucket string, opts ...MetricOption) {
+	if t.c == nil {
+		return
+	}
+	t.c.Timing(bucket, t.Duration(), opts...)
 }
 
 // Duration returns the time elapsed since the creation of the Timing.
 func (t Timing) Duration() time.Duration {
-	return now().Sub(t.start)
+	if t.c == nil {
+		return now().Sub(t.start)
+	}
+	return t.c.conn.clock.Now().Sub(t.start)
 }
 
-// Unique sends the given value to a set bucket.
-func (c *Client) Unique(bucket string, value string) {
-	if c.skip() {
+// Custom sends the given value to bucket with the type suffix typ, e.g. "kv"
+// or "g+", for the metric types supported by some StatsD daemons but not by
+// the Client. The value can be any type of integer or float, as in Count, or a
+// string. The typ must not contain "|" or new lines.
+func (c *Client) Custom(bucket string, value interface{}, typ string, opts ...MetricOption) {
+	rate := c.rateFor(CUSTOM)
+	if c.skip(rate) {
 		return
 	}
-	c.conn.unique(c.prefix, bucket, value, c.tags)
+	m := GetMetric()
+	m.Type = CUSTOM
+	m.CustomType = strings.TrimPrefix(typ, "|")
+	m.Prefix = c.prefix
+	m.Bucket = bucket
+	m.Value = value
+	m.Rate = rate
+	m.Tags = c.tags
+	m.Priority = c.priority
+	for _, o := range opts {
+		o(m)
+	}
+	c.emit(m)
+}
+
+// Unique sends the given value to a set bucket.
+func (c *Client) Unique(bucket string, value string, opts ...MetricOption) {
+	c.send(SET, bucket, value, opts)
+}
+
+// UniqueInt64 sends the given integer value to a set bucket. It is equivalent
+// to Unique(bucket, strconv.FormatInt(value, 10)) without formatting the value
+// beforehand.
+func (c *Client) UniqueInt64(bucket string, value int64, opts ...MetricOption) {
+	c.send(SET, bucket, value, opts)
+}
+
+// UniqueNumber sends the given value to a set bucket. The value can be any
+// type of integer or float, as in Count.
+func (c *Client) UniqueNumber(bucket string, value interface{}, opts ...MetricOption) {
+	c.send(SET, bucket, value, opts)
+}
+
+// SendRaw appends a line already in the wire format, e.g. built by another
+// encoder, to the buffer of the Client, so that it is packed and sent with the
+// other metrics. The line is sent as is: the prefix, tags and sample rate of
+// the Client are not applied and it is not routed to the Shards or Routes.
+//
+// SendRaw returns an error if the line is empty, contains a new line apart
+// from a trailing one or does not fit in a packet.
+func (c *Client) SendRaw(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) == 0 {
+		return errEmptyLine
+	}
+	if bytes.IndexByte(line, '\n') >= 0 {
+		return errMultipleLines
+	}
+	if c.muted && !c.conn.record {
+		return nil
+	}
+	return c.conn.sendRaw(line)
+}
+
+// Snapshot returns the metrics recorded by a muted Client created with the
+// Record option, one metric per line in the wire format. It returns nil if the
+// Client does not record.
+func (c *Client) Snapshot() []string {
+	if !c.conn.record {
+		return nil
+	}
+	return c.PendingLines()
+}
+
+// Pending returns the number of metric lines and bytes buffered by the Client
+// and not flushed yet. It helps finding out whether metrics are stuck in the
+// Client or lost downstream.
+func (c *Client) Pending() (metrics int, bytes int) {
+	for _, cn := range c.conn.conns() {
+		cn.mu.Lock()
+		p := cn.pending()
+		metrics += bytesCount(p, '\n')
+		bytes += len(p)
+		cn.mu.Unlock()
+	}
+	return metrics, bytes
+}
+
+// PendingLines returns the metrics buffered by the Client and not flushed yet,
+// one metric per line in the wire format.
+func (c *Client) PendingLines() []string {
+	lines := []string{}
+	for _, cn := range c.conn.conns() {
+		cn.mu.Lock()
+		if p := cn.pending(); len(p) > 0 {
+			lines = append(lines, strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")...)
+		}
+		cn.mu.Unlock()
+	}
+	return lines
+}
+
+func bytesCount(b []byte, c byte) int {
+	n := 0
+	for _, x := range b {
+		if x == c {
+			n++
+		}
+	}
+	return n
 }
 
 // Flush flushes the Client's buffer.
@@ -156,35 +430,49 @@ func (c *Client) Flush() error {
 	if c.muted {
 		return nil
 	}
-	c.conn.mu.Lock()
-	err := c.conn.flush(0)
-	if err != nil {
-		c.conn.handleError(err)
-	} else {
-		err = c.conn.w.Close()
-		c.conn.handleError(err)
+	c.conn.sendCounters()
+	var err error
+	for _, cn := range c.conn.conns() {
+		if ferr := cn.flushAndClose(); err == nil {
+			err = ferr
+		}
 	}
-	c.conn.mu.Unlock()
+	return err
+}
 
+// FlushContext flushes the Client's buffer like Flush, except that the writes
+// are bounded by ctx and that the connection is kept open. If ctx is done
+// before the buffer is sent, FlushContext returns the error of ctx and the
+// buffer is kept for the next flush, so that a graceful shutdown cannot hang on
+// an unresponsive StatsD daemon.
+func (c *Client) FlushContext(ctx context.Context) error {
+	if c.muted {
+		return nil
+	}
+	c.conn.sendCounters()
+	var err error
+	for _, cn := range c.conn.conns() {
+		if ferr := cn.flushContext(ctx); err == nil {
+			err = ferr
+		}
+	}
 	return err
 }
 
-// Close flushes the Client's buffer and releases the associated ressources. The
-// Client and all the cloned Clients must not be used afterward.
+// Close flushes the Client's buffer and releases the associated ressources. It
+// is safe to call Close several times. The metrics sent by the Client and all
+// the cloned Clients afterward are dropped and ErrClosed is reported to the
+// ErrorHandler.
 func (c *Client) Close() error {
 	if c.muted {
 		return nil
 	}
-	c.conn.mu.Lock()
-	err := c.conn.flush(0)
-	if err != nil {
-		c.conn.handleError(err)
-	} else if c.conn.w != nil {
-		err = c.conn.w.Close()
-		c.conn.handleError(err)
+	c.conn.sendCounters()
+	var err error
+	for _, cn := range c.conn.conns() {
+		if cerr := cn.close(); err == nil {
+			err = cerr
+		}
 	}
-	c.conn.closed = true
-	c.conn.mu.Unlock()
-
 	return err
 }
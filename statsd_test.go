@@ -2,10 +2,15 @@ package statsd
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -46,6 +51,13 @@ func TestGauge(t *testing.T) {
 	})
 }
 
+func TestSignedGauges(t *testing.T) {
+	testOutput(t, "test_key:5|g\ntest_key:-10|g", func(c *Client) {
+		c.Gauge(testKey, 5)
+		c.Gauge(testKey, -10)
+	}, SignedGauges(true))
+}
+
 func TestTiming(t *testing.T) {
 	testOutput(t, "test_key:6|ms", func(c *Client) {
 		c.Timing(testKey, 6)
@@ -128,6 +140,120 @@ func TestUnique(t *testing.T) {
 	})
 }
 
+func TestCustom(t *testing.T) {
+	testOutput(t, "test_key:1|kv\ntest_key:+5|g+|#a:b\ntest_key:2|x|@0.5|T1000000000", func(c *Client) {
+		c.Custom(testKey, 1, "kv")
+		c.Clone(Tags("a", "b")).Custom(testKey, "+5", "|g+")
+		randFloat = func() float32 { return 0.1 }
+		c.Clone(SampleRateFor(CUSTOM, 0.5)).Custom(testKey, 2, "x", Timestamp(time.Unix(1e9, 0)))
+	}, TagsFormat(Datadog))
+}
+
+func TestSendRaw(t *testing.T) {
+	testOutput(t, "test_key:1|c\nraw:1|kv\nraw:2|kvtest_key:2|c", func(c *Client) {
+		c.Increment(testKey)
+		for _, line := range []string{"raw:1|kv", "raw:2|kv\n"} {
+			if err := c.SendRaw([]byte(line)); err != nil {
+				t.Errorf("SendRaw(%q) = %v", line, err)
+			}
+		}
+		for _, line := range []string{"", "\n", "a:1|c\nb:1|c", strings.Repeat("a", 40)} {
+			if err := c.SendRaw([]byte(line)); err == nil {
+				t.Errorf("SendRaw(%q) should fail", line)
+			}
+		}
+		c.Count(testKey, 2)
+	}, MaxPacketSize(40))
+}
+
+func TestTypeBuffers(t *testing.T) {
+	testOutput(t, "raw:1|kv\na:1|c\nc:1|c\nb:1|g\nd:2|g", func(c *Client) {
+		c.Increment("a")
+		c.Gauge("b", 1)
+		c.Increment("c")
+		c.SendRaw([]byte("raw:1|kv"))
+		c.Gauge("d", 2)
+		want := []string{"raw:1|kv", "a:1|c", "c:1|c", "b:1|g", "d:2|g"}
+		if got := c.PendingLines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("PendingLines() = %q, want %q", got, want)
+		}
+	}, TypeBuffers())
+
+	testOutput(t, "a:1|c\nc:1|c\ne:1|cf:1|c\nb:1|g", func(c *Client) {
+		c.Increment("a")
+		c.Gauge("b", 1)
+		c.Increment("c")
+		c.Increment("e")
+		c.Increment("f")
+	}, TypeBuffers(), MaxPacketSize(20))
+}
+
+func TestTypeBuffersPacking(t *testing.T) {
+	randFloat = func() float32 { return 0.1 }
+	defer func() { randFloat = rand.Float32 }()
+	testOutput(t, "a:1|c:2|c|@0.5\nb:1|ms:2|ms\nb,k=v:3|ms\nb:4|ms", func(c *Client) {
+		c.Increment("a")
+		c.Timing("b", 1)
+		c.Clone(SampleRate(0.5)).Count("a", 2)
+		c.Timing("b", 2)
+		c.WithTags("k", "v").Timing("b", 3)
+		c.Timing("b", 4)
+	}, TypeBuffers(), TagsFormat(InfluxDB))
+
+	// The packed line is moved to the payloads when it is full.
+	testOutput(t, "b:10|ms:20|msb:30|ms", func(c *Client) {
+		c.Timing("b", 10)
+		c.Timing("b", 20)
+		c.Timing("b", 30)
+	}, TypeBuffers(), MaxPacketSize(16))
+
+	testOutput(t, "b:1|ms|#k:v\nb:2|ms|#k:v", func(c *Client) {
+		c.Timing("b", 1)
+		c.Timing("b", 2)
+	}, TypeBuffers(), TagsFormat(Datadog), Tags("k", "v"))
+}
+
+func TestUniqueNumbers(t *testing.T) {
+	testOutput(t, "test_key:-42|s\ntest_key:42|s\ntest_key:1.5|s", func(c *Client) {
+		c.UniqueInt64(testKey, -42)
+		c.UniqueNumber(testKey, uint32(42))
+		c.UniqueNumber(testKey, 1.5)
+	})
+}
+
+func TestTimestamp(t *testing.T) {
+	testOutput(t, "test_key:5|c|T1445532780\ntest_key:1|g|T1445532780\ntest_key:2|ms", func(c *Client) {
+		c.Count(testKey, 5, Timestamp(testDate))
+		c.Gauge(testKey, 1, Timestamp(testDate))
+		c.Timing(testKey, 2)
+	}, TagsFormat(Datadog))
+	testOutput(t, "test_key:1|g|#tag1:value1|T1445532780", func(c *Client) {
+		c.Gauge(testKey, 1, Timestamp(testDate))
+	}, TagsFormat(Datadog), Tags("tag1", "value1"))
+}
+
+func TestSend(t *testing.T) {
+	testOutput(t, "app.test_key:5|c|@0.5|#tag1:value1\n"+
+		"other.test_key:-1|g|#tag2:value2\n"+
+		"app.test_key:foo|s|#tag1:value1\n"+
+		"app.test_key:3|ms|@0.8|#tag1:value1", func(c *Client) {
+		randFloat = func() float32 { return 0.1 }
+		m := GetMetric()
+		m.Type = COUNT
+		m.Bucket = testKey
+		m.Value = 5
+		c.Send(m)
+		if m.Prefix != "" || m.Rate != 0 || m.Tags != "" {
+			t.Errorf("Send() modified the metric: %+v", m)
+		}
+		m.Release()
+
+		c.Send(&Metric{Type: GAUGE, Prefix: "other.", Bucket: testKey, Value: -1, Tags: "|#tag2:value2"})
+		c.Send(&Metric{Type: SET, Bucket: testKey, Value: "foo"})
+		c.Send(&Metric{Type: TIMINGS, Bucket: testKey, Value: 3, Rate: 0.8})
+	}, Prefix("app"), SampleRate(0.5), SignedGauges(true), TagsFormat(Datadog), Tags("tag1", "value1"))
+}
+
 func TestMute(t *testing.T) {
 	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
 		t.Fatal("net.Dial should not be called")
@@ -144,10 +270,54 @@ func TestMute(t *testing.T) {
 	c.Timing(testKey, 1)
 	c.Histogram(testKey, 1)
 	c.Unique(testKey, "1")
+	c.UniqueInt64(testKey, 1)
+	c.UniqueNumber(testKey, 1)
 	c.Flush()
 	c.Close()
 }
 
+func TestRecord(t *testing.T) {
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		t.Fatal("net.Dial should not be called")
+		return nil, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(Mute(true), Record(true), MaxPacketSize(10), Prefix("app"))
+	if err != nil {
+		t.Errorf("New() = %v", err)
+	}
+	if got := c.Snapshot(); len(got) != 0 {
+		t.Errorf("Snapshot() = %q, want empty", got)
+	}
+	c.Increment(testKey)
+	c.Gauge(testKey, 1)
+	c.Clone(Prefix("http")).Timing(testKey, 1)
+	c.Unique(testKey, "1")
+	c.Flush()
+
+	want := []string{
+		"app.test_key:1|c",
+		"app.test_key:1|g",
+		"app.http.test_key:1|ms",
+		"app.test_key:1|s",
+	}
+	if got := c.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %q, want %q", got, want)
+	}
+	c.Close()
+}
+
+func TestSnapshotNotRecording(t *testing.T) {
+	testClient(t, func(c *Client) {
+		c.Increment(testKey)
+		if got := c.Snapshot(); got != nil {
+			t.Errorf("Snapshot() = %q, want nil", got)
+		}
+		c.Close()
+	})
+}
+
 func TestSamplingRateOK(t *testing.T) {
 	testOutput(t, "test_key:3|c|@0.6\ntest_key:4|ms|@0.6", func(c *Client) {
 		randFloat = func() float32 { return 0.5 }
@@ -164,6 +334,25 @@ func TestSamplingRateKO(t *testing.T) {
 	}, SampleRate(0.3))
 }
 
+func TestSamplingRateFor(t *testing.T) {
+	testOutput(t, "test_key:3|c\ntest_key:4|ms|@0.6", func(c *Client) {
+		randFloat = func() float32 { return 0.5 }
+		c.Count(testKey, 3)
+		c.Timing(testKey, 4)
+		c.Histogram(testKey, 5)
+	}, SampleRate(0.3), SampleRateFor(COUNT, 1), SampleRateFor(TIMINGS, 0.6))
+}
+
+func TestCloneSamplingRateFor(t *testing.T) {
+	testOutput(t, "test_key:3|c|@0.6\ntest_key:4|ms|@0.6", func(c *Client) {
+		randFloat = func() float32 { return 0.5 }
+		clone := c.Clone(SampleRate(0.6), SampleRateFor(COUNT, 0.6))
+		clone.Count(testKey, 3)
+		clone.Timing(testKey, 4)
+		c.Count(testKey, 5)
+	}, SampleRate(0.3), SampleRateFor(COUNT, 0.4))
+}
+
 func TestPrefix(t *testing.T) {
 	testOutput(t, "foo.test_key:1|c", func(c *Client) {
 		c.Increment(testKey)
@@ -226,6 +415,62 @@ func TestErrorHandler(t *testing.T) {
 	}))
 }
 
+func TestConnectionCallbacks(t *testing.T) {
+	var events []string
+	testClient(t, func(c *Client) {
+		getBuffer(c).err = errors.New("test error")
+		c.Increment(testKey)
+		c.conn.mu.Lock()
+		c.conn.flush(0)
+		c.conn.mu.Unlock()
+		c.Increment(testKey)
+		c.Close()
+	}, ErrorHandler(func(error) {}), OnConnect(func(addr string) {
+		events = append(events, "connect "+addr)
+	}), OnDisconnect(func(addr string, err error) {
+		events = append(events, fmt.Sprintf("disconnect %s %v", addr, err))
+	}))
+
+	want := []string{
+		"connect :8125",
+		"disconnect :8125 test error",
+		"connect :8125",
+		"disconnect :8125 <nil>",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Invalid events, got %q, want %q", events, want)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	var errs []error
+	testClient(t, func(c *Client) {
+		c.Increment(testKey)
+		if err := c.Close(); err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("second Close() = %v", err)
+		}
+		c.Increment(testKey)
+		c.Clone().Gauge(testKey, 1)
+		if err := c.Flush(); err != ErrClosed {
+			t.Errorf("Flush() after Close = %v, want ErrClosed", err)
+		}
+		if got, want := getOutput(c), "test_key:1|c"; got != want {
+			t.Errorf("Invalid output, got %q, want %q", got, want)
+		}
+		if got := c.Stats().Dropped; got != 2 {
+			t.Errorf("Dropped = %d, want 2", got)
+		}
+	}, ErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	if want := []error{ErrClosed, ErrClosed}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("Invalid errors, got %v, want %v", errs, want)
+	}
+}
+
 func TestFlush(t *testing.T) {
 	testClient(t, func(c *Client) {
 		c.Increment(testKey)
@@ -239,6 +484,201 @@ func TestFlush(t *testing.T) {
 	})
 }
 
+func TestFlushContext(t *testing.T) {
+	hc := &hungConn{wake: make(chan struct{}, 1)}
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		return hc, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(Network("tcp"), FlushPeriod(0), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("FlushContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := c.FlushContext(ctx); err != context.Canceled {
+		t.Errorf("FlushContext() = %v, want %v", err, context.Canceled)
+	}
+	if got, want := c.PendingLines(), []string{"test_key:1|c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PendingLines() = %q, want %q", got, want)
+	}
+}
+
+// doneContext is a context which is done but whose Err is nil, as seen by a
+// flush if it is canceled right after checking it.
+type doneContext struct {
+	context.Context
+	done chan struct{}
+}
+
+func (ctx doneContext) Done() <-chan struct{} { return ctx.done }
+
+func TestFlushContextCanceledDuringFlush(t *testing.T) {
+	hc := &hungConn{wake: make(chan struct{}, 1)}
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		return hc, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(Network("tcp"), FlushPeriod(0), ErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+
+	ctx := doneContext{Context: context.Background(), done: make(chan struct{})}
+	close(ctx.done)
+	flushed := make(chan error)
+	go func() {
+		flushed <- c.FlushContext(ctx)
+	}()
+	select {
+	case err := <-flushed:
+		if err == nil {
+			t.Error("FlushContext() = nil, want an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("FlushContext() was not interrupted")
+	}
+}
+
+func TestDialWriteTimeouts(t *testing.T) {
+	var timeout time.Duration
+	hc := &hungConn{wake: make(chan struct{}, 1)}
+	dialTimeout = func(_, _ string, d time.Duration) (net.Conn, error) {
+		timeout = d
+		return hc, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(Network("tcp"), FlushPeriod(0), Timeout(time.Hour), DialTimeout(10*time.Second), WriteTimeout(10*time.Millisecond), ErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 10*time.Second {
+		t.Errorf("dial timeout = %v, want 10s", timeout)
+	}
+	c.Increment(testKey)
+	start := time.Now()
+	if err := c.Flush(); err == nil {
+		t.Error("Flush() should time out")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Flush() took %v, want the write timeout to be used", d)
+	}
+}
+
+func TestFlushDoesNotBlockSends(t *testing.T) {
+	bc := &blockedConn{started: make(chan struct{}, 2), release: make(chan struct{})}
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		return bc, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(Network("tcp"), FlushPeriod(0), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	flushed := make(chan struct{})
+	go func() {
+		c.FlushContext(context.Background())
+		close(flushed)
+	}()
+	<-bc.started
+
+	sent := make(chan struct{})
+	go func() {
+		c.Count(testKey, 2)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Count() blocked while the buffer was written")
+	}
+	if got, want := c.PendingLines(), []string{"test_key:2|c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PendingLines() = %q, want %q", got, want)
+	}
+
+	go c.FlushContext(context.Background())
+	close(bc.release)
+	<-flushed
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bc.buf.String(), "test_key:1|c\ntest_key:2|c\n"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+// blockedConn is a connection whose writes block until release is closed.
+type blockedConn struct {
+	testBuffer
+	mu      sync.Mutex
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockedConn) Write(p []byte) (int, error) {
+	select {
+	case c.started <- struct{}{}:
+	default:
+	}
+	<-c.release
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.testBuffer.Write(p)
+}
+
+// hungConn is a connection whose writes block until their deadline.
+type hungConn struct {
+	testBuffer
+	mu       sync.Mutex
+	deadline time.Time
+	wake     chan struct{}
+}
+
+func (c *hungConn) Write(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		d := c.deadline
+		c.mu.Unlock()
+		if !d.IsZero() && !time.Now().Before(d) {
+			return 0, errors.New("i/o timeout")
+		}
+		var timeout <-chan time.Time
+		if !d.IsZero() {
+			timeout = time.After(time.Until(d))
+		}
+		select {
+		case <-timeout:
+		case <-c.wake:
+		}
+	}
+}
+
+func (c *hungConn) SetDeadline(t time.Time) error { return c.SetWriteDeadline(t) }
+
+func (c *hungConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 func TestFlushPeriod(t *testing.T) {
 	testClient(t, func(c *Client) {
 		c.Increment(testKey)
@@ -254,6 +694,79 @@ func TestFlushPeriod(t *testing.T) {
 	}, FlushPeriod(time.Nanosecond))
 }
 
+func TestFlushJitter(t *testing.T) {
+	testClient(t, func(c *Client) {
+		for i := 0; i < 100; i++ {
+			d := c.conn.nextFlushPeriod()
+			if d < 80*time.Millisecond || d > 120*time.Millisecond {
+				t.Fatalf("nextFlushPeriod() = %v, want between 80ms and 120ms", d)
+			}
+		}
+		c.Close()
+	}, FlushPeriod(100*time.Millisecond), FlushJitter(0.2))
+}
+
+func TestPending(t *testing.T) {
+	testClient(t, func(c *Client) {
+		if metrics, bytes := c.Pending(); metrics != 0 || bytes != 0 {
+			t.Errorf("Pending() = %d, %d, want 0, 0", metrics, bytes)
+		}
+		if got := c.PendingLines(); len(got) != 0 {
+			t.Errorf("PendingLines() = %q, want empty", got)
+		}
+		c.Increment(testKey)
+		c.Gauge(testKey, -1)
+		if metrics, bytes := c.Pending(); metrics != 3 || bytes != 40 {
+			t.Errorf("Pending() = %d, %d, want 3, 40", metrics, bytes)
+		}
+		want := []string{"test_key:1|c", "test_key:0|g", "test_key:-1|g"}
+		if got := c.PendingLines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("PendingLines() = %q, want %q", got, want)
+		}
+		c.Flush()
+		if metrics, bytes := c.Pending(); metrics != 0 || bytes != 0 {
+			t.Errorf("Pending() = %d, %d, want 0, 0", metrics, bytes)
+		}
+		c.Close()
+	})
+}
+
+func TestDebugWriter(t *testing.T) {
+	now = func() time.Time { return testDate }
+	defer func() { now = time.Now }()
+
+	var debug bytes.Buffer
+	testOutput(t, "test_key:1|c\ntest_key:2|ms", func(c *Client) {
+		c.Increment(testKey)
+		c.Timing(testKey, 2)
+	}, DebugWriter(&debug))
+	want := "2015-10-22T16:53:00.000Z test_key:1|c\n2015-10-22T16:53:00.000Z test_key:2|ms\n"
+	if got := debug.String(); got != want {
+		t.Errorf("Invalid debug output, got %q, want %q", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		t.Fatal("net.Dial should not be called")
+		return nil, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	var out bytes.Buffer
+	c, err := New(DryRun(&out), FlushPeriod(0), MaxPacketSize(20))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Increment(testKey)
+	c.Increment(testKey)
+	c.Timing(testKey, 2)
+	c.Close()
+	if got, want := out.String(), "test_key:1|c\ntest_key:1|c\ntest_key:2|ms\n"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
+
 func TestMaxPacketSize(t *testing.T) {
 	testClient(t, func(c *Client) {
 		c.Increment(testKey)
@@ -279,6 +792,29 @@ func TestMaxPacketSize(t *testing.T) {
 	}, MaxPacketSize(15))
 }
 
+func TestInitialBufferSize(t *testing.T) {
+	testClient(t, func(c *Client) {
+		if got, want := cap(c.conn.buf), 1000+bufferSlack; got != want {
+			t.Errorf("Invalid buffer capacity, got %d, want %d", got, want)
+		}
+		c.Close()
+	}, MaxPacketSize(1000))
+
+	testClient(t, func(c *Client) {
+		if got := cap(c.conn.buf); got != 10 {
+			t.Errorf("Invalid buffer capacity, got %d, want 10", got)
+		}
+		for i := 0; i < 10; i++ {
+			c.Increment(testKey)
+		}
+		c.Close()
+		want := strings.TrimSuffix(strings.Repeat("test_key:1|c\n", 10), "\n")
+		if got := getOutput(c); got != want {
+			t.Errorf("Invalid output, got %q, want %q", got, want)
+		}
+	}, MaxPacketSize(1000), InitialBufferSize(10))
+}
+
 func TestClone(t *testing.T) {
 	testOutput(t, "test_key:5|c", func(c *Client) {
 		c.Clone().Count(testKey, 5)
@@ -318,6 +854,14 @@ func TestClonePrefix(t *testing.T) {
 	}, Prefix("app"))
 }
 
+func TestCloneReplacePrefix(t *testing.T) {
+	testOutput(t, "http.test_key:5|c\ntest_key:5|c\napp.test_key:5|c", func(c *Client) {
+		c.Clone(ReplacePrefix("http.")).Count(testKey, 5)
+		c.Clone(ReplacePrefix("")).Count(testKey, 5)
+		c.Count(testKey, 5)
+	}, Prefix("app"))
+}
+
 func TestCloneRate(t *testing.T) {
 	testOutput(t, "", func(c *Client) {
 		randFloat = func() float32 { return 0.8 }
@@ -339,6 +883,136 @@ func TestCloneDatadogTags(t *testing.T) {
 	}, TagsFormat(Datadog), Tags("tag1", "value1"))
 }
 
+func TestCloneOverrideTags(t *testing.T) {
+	testOutput(t, "test_key:5|c|#tag1:value1,tag2:value4", func(c *Client) {
+		clone := c.Clone(Tags("tag2", "value3", "tag2", "value4"))
+		clone.Count(testKey, 5)
+	}, TagsFormat(Datadog), Tags("tag1", "value1", "tag2", "value2"))
+}
+
+func TestCloneRemoveTag(t *testing.T) {
+	testOutput(t, "test_key,tag3=value3:5|c\ntest_key,tag1=value1,tag2=value2:5|c", func(c *Client) {
+		clone := c.Clone(RemoveTag("tag1", "tag2", "unknown"), Tags("tag3", "value3"))
+		clone.Count(testKey, 5)
+		c.Count(testKey, 5)
+	}, TagsFormat(InfluxDB), Tags("tag1", "value1", "tag2", "value2"))
+}
+
+func TestCloneRemoveTagKeepsParentTags(t *testing.T) {
+	testOutput(t, "test_key:1|c|#b:2\ntest_key:2|c|#a:1,b:2,c:3", func(c *Client) {
+		c.Clone(RemoveTag("a", "c")).Count(testKey, 1)
+		c.Clone().Count(testKey, 2)
+	}, TagsFormat(Datadog), Tags("a", "1", "b", "2", "c", "3"))
+}
+
+func TestTagsMap(t *testing.T) {
+	testOutput(t, "test_key:5|c|#tag1:value3,a:1,b:2", func(c *Client) {
+		c.Clone(TagsMap(map[string]string{"tag1": "value3", "b": "2", "a": "1"})).Count(testKey, 5)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"))
+}
+
+func TestSortedTags(t *testing.T) {
+	testOutput(t, "test_key,a=1,b=2,c=3:5|c\ntest_key,a=1,b=2,c=3,d=4:6|c\ntest_key,a=1,aa=5,b=2,c=3,d=4:7|c", func(c *Client) {
+		c.Count(testKey, 5)
+		clone := c.Clone(Tags("d", "4"))
+		clone.Count(testKey, 6)
+		clone.WithTags("aa", "5").Count(testKey, 7)
+	}, TagsFormat(InfluxDB), Tags("c", "3", "a", "1", "b", "2"), SortedTags())
+}
+
+func TestTagDedup(t *testing.T) {
+	tests := []struct {
+		policy TagPolicy
+		want   string
+	}{
+		{KeepLast, "test_key:5|c|#tag1:value3,tag2:value2\ntest_key:6|c|#tag1:value4,tag2:value2"},
+		{KeepFirst, "test_key:5|c|#tag1:value1,tag2:value2\ntest_key:6|c|#tag1:value1,tag2:value2"},
+		{KeepAll, "test_key:5|c|#tag1:value1,tag2:value2,tag1:value3\ntest_key:6|c|#tag1:value1,tag2:value2,tag1:value4"},
+	}
+	for _, test := range tests {
+		testOutput(t, test.want, func(c *Client) {
+			c.Clone(Tags("tag1", "value3")).Count(testKey, 5)
+			c.WithTags("tag1", "value4").Count(testKey, 6)
+		}, TagsFormat(Datadog), Tags("tag1", "value1", "tag2", "value2"), TagDedup(test.policy))
+	}
+}
+
+func TestWithTags(t *testing.T) {
+	testOutput(t, "test_key:5|c|#tag1:value1,tag2:value2\ntest_key:6|c|#tag1:value1\nother:7|c|#tag3:value3", func(c *Client) {
+		c.WithTags("tag2", "value2").Count(testKey, 5)
+		c.Count(testKey, 6)
+		c.Clone(RemoveTag("tag1")).WithTags("tag3", "value3").Count("other", 7)
+	}, TagsFormat(Datadog), Tags("tag1", "value1"))
+}
+
+func TestWithTagsInfluxDB(t *testing.T) {
+	testOutput(t, "test_key,tag1=value1,tag2=value2:5|c", func(c *Client) {
+		c.WithTags("tag2", "value2").Count(testKey, 5)
+	}, TagsFormat(InfluxDB), Tags("tag1", "value1"))
+}
+
+func TestWithPrefix(t *testing.T) {
+	testOutput(t, "app.http.test_key:5|c\napp.test_key:6|c\napp.api.test_key:7|c", func(c *Client) {
+		c.WithPrefix("http.").Count(testKey, 5)
+		c.Count(testKey, 6)
+		c.WithPrefix("api").Count(testKey, 7)
+	}, Prefix("app"))
+}
+
+func TestLengthPrefix(t *testing.T) {
+	testOutput(t, "\x0c\x00\x00\x00test_key:1|c\x19\x00\x00\x00test_key:2|c\ntest_key:3|c", func(c *Client) {
+		c.Increment(testKey)
+		c.Flush()
+		c.Count(testKey, 2)
+		c.Count(testKey, 3)
+	}, Network("unix"), LengthPrefix(true))
+}
+
+func TestUnixStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ln, err := net.Listen("unix", filepath.Join(dir, "dsd.socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan []byte)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		p, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- p
+	}()
+
+	c, err := New(
+		Address(ln.Addr().String()),
+		Network("unix"),
+		LengthPrefix(true),
+		ErrorHandler(expectNoError(t)),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.Increment(testKey)
+	c.Close()
+
+	select {
+	case <-time.After(time.Second):
+		t.Error("server received nothing after 1s")
+	case p := <-received:
+		if want := "\x0c\x00\x00\x00test_key:1|c"; string(p) != want {
+			t.Errorf("Invalid output, got %q, want %q", p, want)
+		}
+	}
+}
+
 func TestDialError(t *testing.T) {
 	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
 		return nil, errors.New("")
@@ -354,6 +1028,119 @@ func TestDialError(t *testing.T) {
 	}
 }
 
+func TestLazyConnect(t *testing.T) {
+	dials := 0
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	errorCount := 0
+	c, err := New(LazyConnect(true), FlushPeriod(0), ErrorHandler(func(error) { errorCount++ }))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if dials != 0 || c.conn.w != nil {
+		t.Fatal("New() should not connect")
+	}
+	c.Increment(testKey)
+	if err := c.Flush(); err == nil {
+		t.Error("Flush() should return the connection error")
+	}
+	if errorCount == 0 {
+		t.Error("The connection error should be reported to the ErrorHandler")
+	}
+	c.Close()
+	if got, want := getOutput(c), "test_key:1|c"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
+
+func TestLazyConnectFlush(t *testing.T) {
+	dialTimeout = mockDial
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(LazyConnect(true), FlushPeriod(0), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+}
+
+// newRetrying calls New with the options and the fake clock clk, advancing clk
+// through the connection retries. It returns the waits between the retries.
+func newRetrying(clk *fakeClock, opts ...Option) (*Client, []time.Duration, error) {
+	var c *Client
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err = New(append(opts, UseClock(clk))...)
+	}()
+	var slept []time.Duration
+	for i := len(clk.tickers); ; {
+		select {
+		case <-done:
+			return c, slept, err
+		default:
+		}
+		if t := clk.ticker(i); t != nil {
+			slept = append(slept, t.period)
+			clk.Advance(t.period)
+			i++
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	clk := &fakeClock{t: testDate}
+	dials := 0
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		dials++
+		if dials < 4 {
+			return nil, errors.New("not listening")
+		}
+		return &testBuffer{}, nil
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, slept, err := newRetrying(clk, ConnectRetry(time.Second), FlushPeriod(0))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if c.conn.w == nil {
+		t.Error("New() should return a connected client")
+	}
+	want := []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("backoff = %v, want %v", slept, want)
+	}
+
+	dials = 0
+	c, slept, err = newRetrying(clk, ConnectRetry(120*time.Millisecond), FlushPeriod(0))
+	if err == nil {
+		t.Error("New() should return the last connection error")
+	}
+	if c.conn.w != nil {
+		t.Error("New() should not return a connected client")
+	}
+	want = []time.Duration{50 * time.Millisecond, 70 * time.Millisecond}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("backoff = %v, want %v", slept, want)
+	}
+}
+
 func TestTimeoutOption(t *testing.T) {
 	{
 		c, err := New(
@@ -362,8 +1149,8 @@ func TestTimeoutOption(t *testing.T) {
 		if c == nil {
 			t.Fatalf("New: %v", err)
 		}
-		if c.conn.timeout != 100*time.Millisecond {
-			t.Errorf("Client timeout set to %v, want %v", c.conn.timeout, 100*time.Millisecond)
+		if c.conn.dialTimeout != 100*time.Millisecond || c.conn.writeTimeout != 100*time.Millisecond {
+			t.Errorf("Client timeouts set to %v and %v, want %v", c.conn.dialTimeout, c.conn.writeTimeout, 100*time.Millisecond)
 		}
 		c.Close()
 	}
@@ -401,6 +1188,19 @@ func TestUDPNotListening(t *testing.T) {
 	}
 }
 
+func TestNoUDPCheck(t *testing.T) {
+	dialTimeout = mockUDPClosed
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	c, err := New(UDPCheck(false), FlushPeriod(0))
+	if err != nil {
+		t.Errorf("New() = %v", err)
+	}
+	if c.conn.w == nil {
+		t.Error("New() should return a connected client")
+	}
+}
+
 type mockClosedUDPConn struct {
 	i int
 	net.Conn
@@ -0,0 +1,174 @@
+package statsdtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/statsd"
+)
+
+// A Recorder holds the metrics sent by a Client, like a Server.
+type Recorder interface {
+	Metrics() []*statsd.Metric
+}
+
+// Lines is a Recorder holding metric lines, e.g. the lines recorded by a
+// muted Client created with the Record option:
+//
+//	statsdtest.AssertCount(t, statsdtest.Lines(c.Snapshot()), "hits", 3)
+type Lines []string
+
+// Metrics returns the metrics of the lines. The lines which are not metrics
+// are skipped.
+func (l Lines) Metrics() []*statsd.Metric {
+	var metrics []*statsd.Metric
+	for _, line := range l {
+		if m, err := statsd.ParseMetric(line); err == nil {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// A Matcher selects the metrics checked by the assertion helpers.
+type Matcher func(*statsd.Metric) bool
+
+// Tag selects the metrics having the tag k with the value v, in the InfluxDB
+// or Datadog format.
+func Tag(k, v string) Matcher {
+	return func(m *statsd.Metric) bool {
+		for _, t := range tags(m) {
+			if t == k+":"+v || t == k+"="+v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// tags returns the tags of m, e.g. "k:v" or "k=v".
+func tags(m *statsd.Metric) []string {
+	t := strings.TrimPrefix(strings.TrimPrefix(m.Tags, "|#"), ",")
+	if t == "" {
+		return nil
+	}
+	return strings.Split(t, ",")
+}
+
+// find returns the metrics of r of type typ sent to bucket and selected by
+// all the matchers.
+func find(r Recorder, typ statsd.Type, bucket string, matchers []Matcher) []*statsd.Metric {
+	var found []*statsd.Metric
+metrics:
+	for _, m := range r.Metrics() {
+		if m.Type != typ || m.Name() != bucket {
+			continue
+		}
+		for _, match := range matchers {
+			if !match(m) {
+				continue metrics
+			}
+		}
+		found = append(found, m)
+	}
+	return found
+}
+
+// value returns the value of m as a float64, summing the packed values.
+func value(m *statsd.Metric) float64 {
+	switch v := m.Value.(type) {
+	case float64:
+		return v
+	case []float64:
+		sum := 0.0
+		for _, x := range v {
+			sum += x
+		}
+		return sum
+	}
+	return 0
+}
+
+// AssertCount checks that the counts sent to bucket add up to want. The
+// sample rates are ignored.
+func AssertCount(t testing.TB, r Recorder, bucket string, want float64, matchers ...Matcher) {
+	t.Helper()
+	got := 0.0
+	for _, m := range find(r, statsd.COUNT, bucket, matchers) {
+		got += value(m)
+	}
+	if got != want {
+		t.Errorf("count %s = %v, want %v", bucket, got, want)
+	}
+}
+
+// AssertGauge checks that the last value of the gauge bucket is want.
+func AssertGauge(t testing.TB, r Recorder, bucket string, want float64, matchers ...Matcher) {
+	t.Helper()
+	found := find(r, statsd.GAUGE, bucket, matchers)
+	if len(found) == 0 {
+		t.Errorf("gauge %s not sent, want %v", bucket, want)
+		return
+	}
+	if got := value(found[len(found)-1]); got != want {
+		t.Errorf("gauge %s = %v, want %v", bucket, got, want)
+	}
+}
+
+// AssertTiming checks that a timing sent to bucket is within delta of want,
+// e.g. to check the Timing of an operation whose duration varies a bit.
+func AssertTiming(t testing.TB, r Recorder, bucket string, want, delta time.Duration, matchers ...Matcher) {
+	t.Helper()
+	var got []time.Duration
+	for _, m := range find(r, statsd.TIMINGS, bucket, matchers) {
+		values, ok := m.Value.([]float64)
+		if !ok {
+			values = []float64{value(m)}
+		}
+		for _, v := range values {
+			d := time.Duration(v * float64(time.Millisecond))
+			if d >= want-delta && d <= want+delta {
+				return
+			}
+			got = append(got, d)
+		}
+	}
+	t.Errorf("timings %s = %v, want %v ± %v", bucket, got, want, delta)
+}
+
+// AssertUnique checks that value was sent to the set bucket.
+func AssertUnique(t testing.TB, r Recorder, bucket, value string, matchers ...Matcher) {
+	t.Helper()
+	for _, m := range find(r, statsd.SET, bucket, matchers) {
+		if m.Value == value {
+			return
+		}
+	}
+	t.Errorf("set %s does not contain %q", bucket, value)
+}
+
+// AssertSent checks that a metric of any type was sent to bucket.
+func AssertSent(t testing.TB, r Recorder, bucket string, matchers ...Matcher) {
+	t.Helper()
+	if !sent(r, bucket, matchers) {
+		t.Errorf("%s not sent", bucket)
+	}
+}
+
+// AssertNotSent checks that no metric was sent to bucket.
+func AssertNotSent(t testing.TB, r Recorder, bucket string, matchers ...Matcher) {
+	t.Helper()
+	if sent(r, bucket, matchers) {
+		t.Errorf("%s sent, want none", bucket)
+	}
+}
+
+func sent(r Recorder, bucket string, matchers []Matcher) bool {
+	for _, typ := range []statsd.Type{statsd.COUNT, statsd.GAUGE, statsd.TIMINGS, statsd.HISTOGRAM, statsd.SET} {
+		if len(find(r, typ, bucket, matchers)) > 0 {
+			return true
+		}
+	}
+	return false
+}
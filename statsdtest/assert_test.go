@@ -0,0 +1,71 @@
+package statsdtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/statsd"
+)
+
+// fakeT records the failures of the assertions.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	c, err := statsd.New(statsd.Mute(true), statsd.Record(true), statsd.TagsFormat(statsd.Datadog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment("hits")
+	c.Count("hits", 2)
+	c.Clone(statsd.Tags("env", "prod")).Increment("hits")
+	c.Gauge("size", 3)
+	c.Gauge("size", 4)
+	c.Timing("latency", 102)
+	c.Unique("users", "bob")
+	r := Lines(c.Snapshot())
+
+	ft := &fakeT{}
+	AssertCount(ft, r, "hits", 4)
+	AssertCount(ft, r, "hits", 1, Tag("env", "prod"))
+	AssertGauge(ft, r, "size", 4)
+	AssertTiming(ft, r, "latency", 100*time.Millisecond, 5*time.Millisecond)
+	AssertUnique(ft, r, "users", "bob")
+	AssertSent(ft, r, "hits")
+	AssertNotSent(ft, r, "misses")
+	for _, e := range ft.errors {
+		t.Errorf("unexpected failure: %s", e)
+	}
+
+	ft = &fakeT{}
+	AssertCount(ft, r, "hits", 3)
+	AssertCount(ft, r, "hits", 1, Tag("env", "dev"))
+	AssertGauge(ft, r, "size", 3)
+	AssertGauge(ft, r, "missing", 3)
+	AssertTiming(ft, r, "latency", 110*time.Millisecond, 5*time.Millisecond)
+	AssertUnique(ft, r, "users", "alice")
+	AssertSent(ft, r, "misses")
+	AssertNotSent(ft, r, "hits")
+	if len(ft.errors) != 8 {
+		t.Errorf("%d failures, want 8: %q", len(ft.errors), ft.errors)
+	}
+}
+
+func TestTagMatcherInfluxDB(t *testing.T) {
+	r := Lines{"hits,env=prod:1|c", "hits:2|c"}
+	ft := &fakeT{}
+	AssertCount(ft, r, "hits", 1, Tag("env", "prod"))
+	AssertCount(ft, r, "hits", 3)
+	for _, e := range ft.errors {
+		t.Errorf("unexpected failure: %s", e)
+	}
+}
@@ -0,0 +1,144 @@
+// Package statsdtest provides utilities for testing the StatsD instrumentation
+// of an application end to end.
+package statsdtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/msaf1980/statsd"
+)
+
+// A Server is a StatsD server listening on a random local endpoint which
+// collects the lines it receives.
+type Server struct {
+	// Timeout is the maximum time Wait waits for the lines. It must not be
+	// changed while Wait is running.
+	Timeout time.Duration
+
+	network string
+	addr    string
+	dir     string
+	l       *statsd.Listener
+
+	mu      sync.Mutex
+	lines   []string
+	changed chan struct{}
+}
+
+// NewServer starts a Server listening on a random local endpoint of the given
+// network: "udp", "tcp", "unix" or "unixgram". The unix sockets are created in
+// a temporary directory.
+func NewServer(network string) (*Server, error) {
+	s := &Server{
+		Timeout: 5 * time.Second,
+		network: network,
+		changed: make(chan struct{}),
+	}
+	var addr string
+	switch network {
+	case "udp", "tcp":
+		addr = "127.0.0.1:0"
+	case "unix", "unixgram":
+		var err error
+		if s.dir, err = ioutil.TempDir("", "statsdtest"); err != nil {
+			return nil, err
+		}
+		addr = filepath.Join(s.dir, "statsd.sock")
+	default:
+		return nil, fmt.Errorf("statsdtest: unsupported network %q", network)
+	}
+	l, err := statsd.ListenLines(network, addr, s.add)
+	if err != nil {
+		if s.dir != "" {
+			os.RemoveAll(s.dir)
+		}
+		return nil, err
+	}
+	s.l = l
+	s.addr = l.Addr().String()
+	return s, nil
+}
+
+// Network returns the network the Server listens on.
+func (s *Server) Network() string {
+	return s.network
+}
+
+// Addr returns the address the Server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Options returns the options connecting a Client to the Server.
+func (s *Server) Options() []statsd.Option {
+	return []statsd.Option{statsd.Network(s.network), statsd.Address(s.addr)}
+}
+
+// Lines returns the lines received so far.
+func (s *Server) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+// Metrics returns the metrics received so far. The lines which are not
+// metrics are skipped.
+func (s *Server) Metrics() []*statsd.Metric {
+	var metrics []*statsd.Metric
+	for _, line := range s.Lines() {
+		if m, err := statsd.ParseMetric(line); err == nil {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// Wait waits until the Server has received at least n lines. It returns an
+// error if they are not received within the Timeout.
+func (s *Server) Wait(n int) error {
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		got, changed := len(s.lines), s.changed
+		s.mu.Unlock()
+		if got >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return fmt.Errorf("statsdtest: received %d lines after %v, want %d", got, s.Timeout, n)
+		}
+	}
+}
+
+// Reset forgets the lines received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.lines = nil
+	s.mu.Unlock()
+}
+
+// Close stops the Server.
+func (s *Server) Close() error {
+	err := s.l.Close()
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+	return err
+}
+
+// add records a line.
+func (s *Server) add(line string) {
+	s.mu.Lock()
+	s.lines = append(s.lines, line)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+}
@@ -0,0 +1,62 @@
+package statsdtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/statsd"
+)
+
+func TestServer(t *testing.T) {
+	for _, network := range []string{"udp", "tcp", "unix", "unixgram"} {
+		t.Run(network, func(t *testing.T) {
+			s, err := NewServer(network)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			c, err := statsd.New(append(s.Options(), statsd.FlushPeriod(0))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Increment("count")
+			c.Gauge("gauge", 1.5)
+			c.Unique("set", "a")
+			c.Close()
+
+			if err := s.Wait(3); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"count:1|c", "gauge:1.5|g", "set:a|s"}
+			if got := s.Lines(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Lines() = %q, want %q", got, want)
+			}
+			metrics := s.Metrics()
+			if len(metrics) != 3 {
+				t.Fatalf("Metrics() returned %d metrics, want 3", len(metrics))
+			}
+			if m := metrics[1]; m.Bucket != "gauge" || m.Type != statsd.GAUGE || m.Value != 1.5 {
+				t.Errorf("Metrics()[1] = %+v, want the gauge", m)
+			}
+
+			s.Reset()
+			if got := s.Lines(); len(got) != 0 {
+				t.Errorf("Lines() = %q after Reset, want none", got)
+			}
+		})
+	}
+}
+
+func TestServerWaitTimeout(t *testing.T) {
+	s, err := NewServer("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Timeout = 10 * time.Millisecond
+	if err := s.Wait(1); err == nil {
+		t.Error("Wait should time out")
+	}
+}
@@ -0,0 +1,57 @@
+package statsd
+
+// A Statter sends metrics. It is implemented by *Client and NoopClient, so
+// that libraries can accept a Statter and be disabled with a NoopClient.
+//
+// CloneStatter is the Clone method returning a Statter, so that a NoopClient
+// clones itself.
+type Statter interface {
+	Count(bucket string, n interface{}, opts ...MetricOption)
+	Increment(bucket string, opts ...MetricOption)
+	Decrement(bucket string, opts ...MetricOption)
+	Gauge(bucket string, value interface{}, opts ...MetricOption)
+	Timing(bucket string, value interface{}, opts ...MetricOption)
+	Histogram(bucket string, value interface{}, opts ...MetricOption)
+	Unique(bucket string, value string, opts ...MetricOption)
+	NewTiming() Timing
+	CloneStatter(opts ...Option) Statter
+}
+
+var (
+	_ Statter = (*Client)(nil)
+	_ Statter = NoopClient{}
+)
+
+// NoopClient is a Statter which does nothing.
+type NoopClient struct{}
+
+// Count does nothing.
+func (NoopClient) Count(bucket string, n interface{}, opts ...MetricOption) {}
+
+// Increment does nothing.
+func (NoopClient) Increment(bucket string, opts ...MetricOption) {}
+
+// Decrement does nothing.
+func (NoopClient) Decrement(bucket string, opts ...MetricOption) {}
+
+// Gauge does nothing.
+func (NoopClient) Gauge(bucket string, value interface{}, opts ...MetricOption) {}
+
+// Timing does nothing.
+func (NoopClient) Timing(bucket string, value interface{}, opts ...MetricOption) {}
+
+// Histogram does nothing.
+func (NoopClient) Histogram(bucket string, value interface{}, opts ...MetricOption) {}
+
+// Unique does nothing.
+func (NoopClient) Unique(bucket string, value string, opts ...MetricOption) {}
+
+// NewTiming returns a Timing whose Send does nothing.
+func (NoopClient) NewTiming() Timing {
+	return Timing{start: now()}
+}
+
+// CloneStatter returns the NoopClient.
+func (n NoopClient) CloneStatter(opts ...Option) Statter {
+	return n
+}
@@ -0,0 +1,18 @@
+package statsd
+
+import "testing"
+
+func TestNoopClient(t *testing.T) {
+	var s Statter = NoopClient{}
+	s.Increment(testKey)
+	s.NewTiming().Send(testKey)
+	if c := s.CloneStatter(Prefix("app.")); c != (NoopClient{}) {
+		t.Errorf("CloneStatter() = %#v, want a NoopClient", c)
+	}
+}
+
+func TestClientCloneStatter(t *testing.T) {
+	testOutput(t, "app.test_key:1|c", func(c *Client) {
+		c.CloneStatter(Prefix("app.")).Increment(testKey)
+	})
+}
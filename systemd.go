@@ -0,0 +1,163 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The first file descriptor passed by systemd socket activation, as defined by
+// sd_listen_fds(3).
+const listenFDsStart = 3
+
+// An activatedFile is a socket inherited through systemd socket activation.
+type activatedFile struct {
+	name string
+	f    *os.File
+}
+
+// activationFiles returns the sockets inherited through systemd socket
+// activation. It is a variable so that it can be stubbed in tests.
+var activationFiles = func() ([]activatedFile, error) {
+	activated.once.Do(func() {
+		var names []string
+		names, activated.err = listenFDs()
+		for i, name := range names {
+			f := os.NewFile(uintptr(listenFDsStart+i), name)
+			activated.files = append(activated.files, activatedFile{name: name, f: f})
+		}
+	})
+	return activated.files, activated.err
+}
+
+// activated caches the inherited sockets: their *os.File must be created once
+// as the file descriptor is closed when they are garbage collected.
+var activated struct {
+	once  sync.Once
+	files []activatedFile
+	err   error
+}
+
+// errNotActivated is returned when the process did not inherit any socket.
+var errNotActivated = errors.New("statsd: no socket passed by systemd socket activation (LISTEN_FDS is not set)")
+
+// listenFDs parses the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES environment
+// variables set by systemd socket activation. It returns the names of the
+// inherited file descriptors, starting at listenFDsStart.
+func listenFDs() ([]string, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, errNotActivated
+	}
+	s := os.Getenv("LISTEN_FDS")
+	if s == "" {
+		return nil, errNotActivated
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return nil, &ConfigError{Option: "LISTEN_FDS", Value: s, Reason: "must be a non-negative integer"}
+	}
+	var fdNames []string
+	if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+		fdNames = strings.Split(s, ":")
+	}
+	names := make([]string, n)
+	for i := range names {
+		if i < len(fdNames) {
+			names[i] = fdNames[i]
+		} else {
+			names[i] = "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		}
+	}
+	return names, nil
+}
+
+// activatedSocket returns the inherited socket with the given name, or the
+// first one for which accept returns true if name is empty. The returned
+// net.PacketConn or net.Listener is a duplicate of the inherited socket, which
+// can be closed independently.
+func activatedSocket(name string, accept func(packets bool) bool) (net.PacketConn, net.Listener, error) {
+	files, err := activationFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, af := range files {
+		if name != "" && af.name != name {
+			continue
+		}
+		pc, l, err := fileSocket(af.f)
+		if err != nil {
+			if name != "" {
+				return nil, nil, err
+			}
+			continue
+		}
+		if name != "" || accept(pc != nil) {
+			return pc, l, nil
+		}
+		if pc != nil {
+			pc.Close()
+		} else {
+			l.Close()
+		}
+	}
+	if name != "" {
+		return nil, nil, fmt.Errorf("statsd: no socket named %q passed by systemd socket activation", name)
+	}
+	return nil, nil, errors.New("statsd: no suitable socket passed by systemd socket activation")
+}
+
+// fileSocket returns the datagram socket or the listening stream socket f.
+func fileSocket(f *os.File) (net.PacketConn, net.Listener, error) {
+	// Unix stream sockets can be used as net.PacketConn so the network of
+	// the local address is checked instead.
+	if pc, err := net.FilePacketConn(f); err == nil {
+		switch pc.LocalAddr().Network() {
+		case "udp", "unixgram":
+			return pc, nil, nil
+		}
+		pc.Close()
+	}
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, l, nil
+}
+
+// activatedConn sends the payloads through an inherited datagram socket.
+type activatedConn struct {
+	net.PacketConn
+	addr net.Addr
+}
+
+func (c activatedConn) Write(p []byte) (int, error) {
+	return c.WriteTo(p, c.addr)
+}
+
+// dialActivated returns a connection sending the payloads to addr through the
+// inherited datagram socket with the given name.
+func dialActivated(name, addr string) (WriteCloserWithTimeout, error) {
+	pc, l, err := activatedSocket(name, func(packets bool) bool { return packets })
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		l.Close()
+		return nil, fmt.Errorf("statsd: the socket %q passed by systemd socket activation is not a datagram socket", name)
+	}
+	var dst net.Addr
+	if network := pc.LocalAddr().Network(); network == "unixgram" {
+		dst, err = net.ResolveUnixAddr(network, addr)
+	} else {
+		dst, err = net.ResolveUDPAddr(network, addr)
+	}
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	return activatedConn{PacketConn: pc, addr: dst}, nil
+}
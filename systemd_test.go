@@ -0,0 +1,145 @@
+package statsd
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// activate stubs the sockets inherited through systemd socket activation with
+// the given sockets.
+func activate(t *testing.T, socks map[string]interface{ File() (*os.File, error) }) func() {
+	var files []activatedFile
+	for name, s := range socks {
+		f, err := s.File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, activatedFile{name: name, f: f})
+	}
+	saved := activationFiles
+	activationFiles = func() ([]activatedFile, error) { return files, nil }
+	return func() {
+		for _, af := range files {
+			af.f.Close()
+		}
+		activationFiles = saved
+	}
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc.(*net.UDPConn)
+}
+
+func TestListenSystemd(t *testing.T) {
+	sock := listenUDP(t)
+	defer sock.Close()
+	defer activate(t, map[string]interface{ File() (*os.File, error) }{"statsd.socket": sock})()
+
+	lines := make(chan string, 10)
+	l, err := ListenLines("systemd", "statsd.socket", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got, want := l.Addr().String(), sock.LocalAddr().String(); got != want {
+		t.Errorf("Invalid address, got %s, want %s", got, want)
+	}
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("test_key:1|c\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-lines:
+		if got != "test_key:1|c" {
+			t.Errorf("Invalid line, got %q, want %q", got, "test_key:1|c")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No line received")
+	}
+
+	if _, err := ListenLines("systemd", "other.socket", func(string) {}); err == nil {
+		t.Error("An error should be returned for an unknown socket name")
+	}
+}
+
+func TestSocketActivation(t *testing.T) {
+	sock := listenUDP(t)
+	defer sock.Close()
+	defer activate(t, map[string]interface{ File() (*os.File, error) }{"statsd.socket": sock})()
+
+	lines := make(chan string, 10)
+	l, err := ListenLines("udp", "127.0.0.1:0", func(line string) {
+		lines <- line
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := New(Address(l.Addr().String()), SocketActivation(""), FlushPeriod(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	c.Close()
+	select {
+	case got := <-lines:
+		if got != "test_key:1|c" {
+			t.Errorf("Invalid line, got %q, want %q", got, "test_key:1|c")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No line received")
+	}
+}
+
+func TestSocketActivationNotActivated(t *testing.T) {
+	defer setenv(t, map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid() + 1), "LISTEN_FDS": "1"})()
+	if _, err := listenFDs(); err != errNotActivated {
+		t.Errorf("listenFDs() = %v, want %v", err, errNotActivated)
+	}
+
+	saved := activationFiles
+	activationFiles = func() ([]activatedFile, error) {
+		_, err := listenFDs()
+		return nil, err
+	}
+	defer func() { activationFiles = saved }()
+	var got error
+	c, err := New(SocketActivation(""), FlushPeriod(0), ErrorHandler(func(err error) { got = err }))
+	if err != errNotActivated && got != errNotActivated {
+		t.Errorf("New() = %v, reported %v, want %v", err, got, errNotActivated)
+	}
+	if c != nil {
+		c.Close()
+	}
+}
+
+func TestListenFDs(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "2",
+		"LISTEN_FDNAMES": "statsd.socket",
+	})()
+	names, err := listenFDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"statsd.socket", "LISTEN_FD_4"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Invalid names, got %q, want %q", names, want)
+	}
+}
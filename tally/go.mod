@@ -0,0 +1,10 @@
+module github.com/msaf1980/statsd/tally
+
+go 1.16
+
+require (
+	github.com/msaf1980/statsd v0.0.0-20261016111447-6797249223ae
+	github.com/uber-go/tally v3.3.15+incompatible
+)
+
+replace github.com/msaf1980/statsd => ../
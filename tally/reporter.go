@@ -0,0 +1,177 @@
+// Package tally provides a reporter sending the metrics of uber-go/tally
+// scopes through a statsd.Client.
+//
+// It lives in its own module so that the statsd package does not depend on
+// tally.
+package tally
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/msaf1980/statsd"
+	"github.com/uber-go/tally"
+)
+
+// DefaultHistogramBucketNamePrecision is the default precision of the bounds
+// of the histogram buckets in the bucket names.
+const DefaultHistogramBucketNamePrecision = 6
+
+// maxClones is the maximum number of tag sets whose Statter is cached by a
+// reporter, to bound its memory if the tags have unbounded values.
+const maxClones = 1000
+
+// Options are the options of a reporter.
+type Options struct {
+	// HistogramBucketNamePrecision is the precision of the bounds of the
+	// value histogram buckets in the bucket names. It defaults to
+	// DefaultHistogramBucketNamePrecision.
+	HistogramBucketNamePrecision uint
+}
+
+type reporter struct {
+	s         statsd.Statter
+	bucketFmt string
+
+	mu sync.Mutex
+	// clones are the Statters sending the metrics with a tag set, by tagsKey.
+	clones map[string]statsd.Statter
+}
+
+// NewReporter returns a tally.StatsReporter sending the metrics of a scope
+// through s:
+//   - counters are sent as counts,
+//   - gauges as gauges,
+//   - timers as timings in milliseconds,
+//   - and the samples of a histogram bucket as a count of the bucket
+//     <name>.<lower bound>-<upper bound>.
+//
+// The tags of the metrics are sent as tags, so s should be configured with a
+// TagFormat supported by the server.
+func NewReporter(s statsd.Statter, opts Options) tally.StatsReporter {
+	if opts.HistogramBucketNamePrecision == 0 {
+		opts.HistogramBucketNamePrecision = DefaultHistogramBucketNamePrecision
+	}
+	return &reporter{
+		s:         s,
+		bucketFmt: "%." + strconv.Itoa(int(opts.HistogramBucketNamePrecision)) + "f",
+		clones:    make(map[string]statsd.Statter),
+	}
+}
+
+// with returns the Statter sending the metrics with tags. The Statters are
+// cached as tally reports the metrics of a scope with the same tags at every
+// interval.
+func (r *reporter) with(tags map[string]string) statsd.Statter {
+	if len(tags) == 0 {
+		return r.s
+	}
+	key := tagsKey(tags)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.clones[key]; ok {
+		return s
+	}
+	s := r.s.CloneStatter(statsd.TagsMap(tags))
+	if len(r.clones) < maxClones {
+		r.clones[key] = s
+	}
+	return s
+}
+
+// tagsKey returns a key identifying the tag set.
+func tagsKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		// The keys and values are quoted so that they cannot be confused
+		// whatever characters they contain.
+		b.WriteString(strconv.Quote(k))
+		b.WriteString(strconv.Quote(tags[k]))
+	}
+	return b.String()
+}
+
+func (r *reporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.with(tags).Count(name, value)
+}
+
+func (r *reporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.with(tags).Gauge(name, value)
+}
+
+func (r *reporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.with(tags).Timing(name, float64(interval)/float64(time.Millisecond))
+}
+
+func (r *reporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	bucket := name + "." + r.valueBound(bucketLowerBound) + "-" + r.valueBound(bucketUpperBound)
+	r.with(tags).Count(bucket, samples)
+}
+
+func (r *reporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	bucket := name + "." + durationBound(bucketLowerBound) + "-" + durationBound(bucketUpperBound)
+	r.with(tags).Count(bucket, samples)
+}
+
+func (r *reporter) valueBound(b float64) string {
+	switch b {
+	case math.MaxFloat64:
+		return "infinity"
+	case -math.MaxFloat64:
+		return "-infinity"
+	}
+	return fmt.Sprintf(r.bucketFmt, b)
+}
+
+func durationBound(b time.Duration) string {
+	switch b {
+	case math.MaxInt64:
+		return "infinity"
+	case math.MinInt64:
+		return "-infinity"
+	}
+	return b.String()
+}
+
+func (r *reporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *reporter) Reporting() bool {
+	return true
+}
+
+func (r *reporter) Tagging() bool {
+	return true
+}
+
+// Flush flushes the Statter if it can be flushed, as a *statsd.Client.
+func (r *reporter) Flush() {
+	if f, ok := r.s.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,72 @@
+package tally
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/statsd"
+	"github.com/msaf1980/statsd/statsdtest"
+	"github.com/uber-go/tally"
+)
+
+func TestReporter(t *testing.T) {
+	s, err := statsdtest.NewServer("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c, err := statsd.New(append(s.Options(), statsd.TagsFormat(statsd.Datadog))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:   "app",
+		Reporter: NewReporter(c, Options{}),
+	}, 0)
+	scope.Tagged(map[string]string{"route": "home"}).Counter("hits").Inc(2)
+	scope.Gauge("load").Update(1.5)
+	scope.Timer("latency").Record(1500 * time.Microsecond)
+	scope.Histogram("size", tally.ValueBuckets{10, 100}).RecordValue(42)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Wait(4); err != nil {
+		t.Fatal(err)
+	}
+	statsdtest.AssertCount(t, s, "app.hits", 2, statsdtest.Tag("route", "home"))
+	statsdtest.AssertGauge(t, s, "app.load", 1.5)
+	statsdtest.AssertTiming(t, s, "app.latency", 1500*time.Microsecond, 0)
+	statsdtest.AssertCount(t, s, "app.size.10.000000-100.000000", 1)
+}
+
+func TestReporterClones(t *testing.T) {
+	r := NewReporter(statsd.NoopClient{}, Options{}).(*reporter)
+	r.with(map[string]string{"route": "home", "method": "GET"})
+	r.with(map[string]string{"method": "GET", "route": "home"})
+	r.with(map[string]string{"method": "GET,route"})
+	if got, want := len(r.clones), 2; got != want {
+		t.Errorf("%d Statters cached, want %d", got, want)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	r := NewReporter(statsd.NoopClient{}, Options{HistogramBucketNamePrecision: 2}).(*reporter)
+	tests := []struct {
+		got, want string
+	}{
+		{r.valueBound(1.5), "1.50"},
+		{r.valueBound(-math.MaxFloat64), "-infinity"},
+		{durationBound(time.Second), "1s"},
+		{durationBound(1<<63 - 1), "infinity"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package statsd
+
+import "time"
+
+// A TimeUnit is the unit in which the durations are sent as timing values.
+type TimeUnit uint8
+
+// The units of the timing values.
+const (
+	// Milliseconds sends the durations as integer milliseconds, truncating
+	// them, as expected by most StatsD daemons.
+	Milliseconds TimeUnit = iota
+	// Microseconds sends the durations as integer microseconds.
+	Microseconds
+	// FloatMilliseconds sends the durations as milliseconds with a decimal
+	// part, keeping their sub-millisecond precision.
+	FloatMilliseconds
+)
+
+// TimingUnit sets the unit of the timing values sent for durations: the
+// time.Duration values passed to Timing and TimingContext, and the durations
+// measured with NewTiming. The other values are sent as is, so the call sites
+// can pass durations instead of converting them by hand.
+//
+// By default, the durations are sent in Milliseconds.
+func TimingUnit(u TimeUnit) Option {
+	return Option(func(c *config) {
+		c.Client.TimingUnit = u
+	})
+}
+
+// timingValue returns the timing value of v: v converted to the TimeUnit of
+// the Client if it is a time.Duration, v otherwise.
+func (c *Client) timingValue(v interface{}) interface{} {
+	d, ok := v.(time.Duration)
+	if !ok {
+		return v
+	}
+	switch c.timingUnit {
+	case Microseconds:
+		return int64(d / time.Microsecond)
+	case FloatMilliseconds:
+		return float64(d) / float64(time.Millisecond)
+	}
+	return int64(d / time.Millisecond)
+}
@@ -0,0 +1,37 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingUnit(t *testing.T) {
+	d := 1500 * time.Microsecond
+	tests := []struct {
+		unit TimeUnit
+		want string
+	}{
+		{Milliseconds, "test_key:1|ms"},
+		{Microseconds, "test_key:1500|ms"},
+		{FloatMilliseconds, "test_key:1.5|ms"},
+	}
+	for _, tt := range tests {
+		testOutput(t, tt.want, func(c *Client) {
+			c.Timing(testKey, d)
+		}, TimingUnit(tt.unit))
+	}
+
+	testOutput(t, "test_key:42|ms", func(c *Client) {
+		c.Timing(testKey, 42)
+	}, TimingUnit(Microseconds))
+}
+
+func TestTimingUnitNewTiming(t *testing.T) {
+	clk := &fakeClock{t: testDate}
+	testOutput(t, "test_key:2500|ms\ntest_key:2.5|ms", func(c *Client) {
+		timing := c.NewTiming()
+		clk.Advance(2500 * time.Microsecond)
+		timing.Send(testKey)
+		c.Clone(TimingUnit(FloatMilliseconds)).Timing(testKey, timing.Duration())
+	}, UseClock(clk), TimingUnit(Microseconds))
+}
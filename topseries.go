@@ -0,0 +1,155 @@
+package statsd
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxTrackedSeries is the maximum number of series counted in a window by the
+// TopSeries option, to bound its memory when the number of series explodes.
+const maxTrackedSeries = 100000
+
+// A SeriesCount is the number of metrics sent to a series.
+type SeriesCount struct {
+	// Name is the name of the series, with its prefix.
+	Name string
+	// Tags are the tags of the series, rendered in the TagFormat of the
+	// Client.
+	Tags  string
+	Count int64
+}
+
+// TopSeries makes the Client count the metrics sent to each series, i.e. to
+// each combination of bucket and tags, during windows of the given duration.
+// The k series with the most metrics of the last complete window are returned
+// in Stats.TopSeries and by Client.TopSeriesHandler, e.g. to find which code
+// path makes the number of series explode.
+//
+// It is meant for debugging: counting the series costs a map lookup per
+// metric. Only the first 100000 series of a window are counted. This option is
+// ignored in Client.Clone().
+func TopSeries(k int, window time.Duration) Option {
+	return Option(func(c *config) {
+		c.Conn.TopSeries = k
+		c.Conn.TopSeriesWindow = window
+	})
+}
+
+// A seriesID identifies a series counted by TopSeries without concatenating
+// its prefix and bucket.
+type seriesID struct {
+	prefix, bucket, tags string
+}
+
+// topSeries counts the metrics sent to each series.
+type topSeries struct {
+	k      int
+	window time.Duration
+	start  time.Time
+	counts map[seriesID]int64
+	// last is the top of the last complete window.
+	last []SeriesCount
+}
+
+func newTopSeries(k int, window time.Duration, now time.Time) *topSeries {
+	return &topSeries{
+		k:      k,
+		window: window,
+		start:  now,
+		counts: make(map[seriesID]int64),
+	}
+}
+
+// add counts the metric m sent at now.
+func (t *topSeries) add(m *Metric, now time.Time) {
+	t.rotate(now)
+	id := seriesID{prefix: m.Prefix, bucket: m.Bucket, tags: m.Tags}
+	if n, ok := t.counts[id]; ok || len(t.counts) < maxTrackedSeries {
+		t.counts[id] = n + 1
+	}
+}
+
+// rotate starts a new window if the current one ended before now.
+func (t *topSeries) rotate(now time.Time) {
+	elapsed := now.Sub(t.start)
+	if elapsed < t.window {
+		return
+	}
+	if elapsed < 2*t.window {
+		t.last = t.rank()
+	} else {
+		// No metric was sent during the last complete window.
+		t.last = nil
+	}
+	t.counts = make(map[seriesID]int64, len(t.counts))
+	t.start = t.start.Add(elapsed / t.window * t.window)
+}
+
+// rank returns the k series of the current window with the most metrics.
+func (t *topSeries) rank() []SeriesCount {
+	top := make([]SeriesCount, 0, len(t.counts))
+	for key, n := range t.counts {
+		top = append(top, SeriesCount{Name: key.prefix + key.bucket, Tags: key.tags, Count: n})
+	}
+	return truncateTop(top, t.k)
+}
+
+// truncateTop sorts the series by decreasing count, then by name and tags, and
+// returns the first k ones.
+func truncateTop(top []SeriesCount, k int) []SeriesCount {
+	sort.Slice(top, func(i, j int) bool {
+		a, b := top[i], top[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Tags < b.Tags
+	})
+	if len(top) > k {
+		top = top[:k]
+	}
+	return top
+}
+
+// mergeTop merges the top series of the connections, which count different
+// metrics, and returns the k first ones.
+func mergeTop(tops [][]SeriesCount, k int) []SeriesCount {
+	if len(tops) == 1 {
+		// The top is copied as it is shared with the next calls.
+		return append([]SeriesCount(nil), tops[0]...)
+	}
+	type key struct{ name, tags string }
+	counts := make(map[key]int64)
+	for _, top := range tops {
+		for _, s := range top {
+			counts[key{s.Name, s.Tags}] += s.Count
+		}
+	}
+	var merged []SeriesCount
+	for key, n := range counts {
+		merged = append(merged, SeriesCount{Name: key.name, Tags: key.tags, Count: n})
+	}
+	return truncateTop(merged, k)
+}
+
+// TopSeriesHandler returns an HTTP handler listing the series returned in
+// Stats.TopSeries, one per line with their count, e.g. to mount it on a debug
+// server:
+//
+//	http.Handle("/debug/statsd/top", c.TopSeriesHandler())
+func (c *Client) TopSeriesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if c.conn.topK == 0 {
+			http.Error(w, "statsd: the TopSeries option is not used", http.StatusNotFound)
+			return
+		}
+		for _, s := range c.Stats().TopSeries {
+			fmt.Fprintf(w, "%d\t%s%s\n", s.Count, s.Name, s.Tags)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package statsd
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopSeries(t *testing.T) {
+	clk := &fakeClock{t: testDate}
+	testClient(t, func(c *Client) {
+		for i := 0; i < 3; i++ {
+			c.Increment("a")
+		}
+		c.WithTags("k", "v").Increment("b")
+		c.WithTags("k", "w").Increment("b")
+		c.Clone(Prefix("p")).Increment("b")
+		c.WithTags("k", "v").Increment("b")
+		if got := c.Stats().TopSeries; got != nil {
+			t.Errorf("TopSeries = %v before the end of the window, want nil", got)
+		}
+
+		clk.Advance(time.Minute)
+		want := []SeriesCount{{Name: "a", Count: 3}, {Name: "b", Tags: ",k=v", Count: 2}}
+		if got := c.Stats().TopSeries; !reflect.DeepEqual(got, want) {
+			t.Errorf("TopSeries = %v, want %v", got, want)
+		}
+
+		rec := httptest.NewRecorder()
+		c.TopSeriesHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if got, want := rec.Body.String(), "3\ta\n2\tb,k=v\n"; got != want {
+			t.Errorf("TopSeriesHandler() = %q, want %q", got, want)
+		}
+
+		c.Increment("c")
+		clk.Advance(2 * time.Minute)
+		if got := c.Stats().TopSeries; len(got) != 0 {
+			t.Errorf("TopSeries = %v after an empty window, want none", got)
+		}
+	}, UseClock(clk), TagsFormat(InfluxDB), TopSeries(2, time.Minute))
+}
+
+func TestTopSeriesHandlerNotUsed(t *testing.T) {
+	testClient(t, func(c *Client) {
+		rec := httptest.NewRecorder()
+		c.TopSeriesHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 404 {
+			t.Errorf("TopSeriesHandler() status = %d, want 404", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package statsd
+
+const trafficClassSupported = false
+
+func setTrafficClass(w interface{}, tos int) error {
+	return nil
+}
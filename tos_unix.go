@@ -0,0 +1,49 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package statsd
+
+import (
+	"net"
+	"syscall"
+)
+
+const trafficClassSupported = true
+
+// setTrafficClass sets the IPv4 type of service or the IPv6 traffic class of
+// the IP connection w. Other connections are left untouched.
+func setTrafficClass(w interface{}, tos int) error {
+	nc, ok := w.(net.Conn)
+	if !ok {
+		return nil
+	}
+	var ip net.IP
+	switch a := nc.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return nil
+	}
+	sc, ok := w.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	level, opt := syscall.IPPROTO_IP, syscall.IP_TOS
+	if ip.To4() == nil {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, opt, tos)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
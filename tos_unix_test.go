@@ -0,0 +1,43 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package statsd
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestTrafficClass(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := New(Address(l.LocalAddr().String()), FlushPeriod(0), TrafficClass(0x20))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+
+	rc, err := c.conn.w.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var gerr error
+	err = rc.Control(func(fd uintptr) {
+		tos, gerr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gerr != nil {
+		t.Fatal(gerr)
+	}
+	if tos != 0x20 {
+		t.Errorf("IP_TOS = %#x, want 0x20", tos)
+	}
+}
@@ -0,0 +1,106 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUseConn(t *testing.T) {
+	dialTimeout = func(string, string, time.Duration) (net.Conn, error) {
+		t.Error("the Client should not dial")
+		return nil, errors.New("dialed")
+	}
+	defer func() { dialTimeout = net.DialTimeout }()
+
+	first := &testBuffer{}
+	var errs []error
+	c, err := New(UseConn(first), FlushPeriod(0), ErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	if got, want := first.buf.String(), "test_key:1|c"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	first.err = errors.New("test error")
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	if len(errs) != 2 || errs[1] != errNoRedial {
+		t.Errorf("errors = %v, want the write error and %v", errs, errNoRedial)
+	}
+	c.Close()
+}
+
+func TestRedial(t *testing.T) {
+	first, second := &testBuffer{err: errors.New("test error")}, &testBuffer{}
+	c, err := New(UseConn(first), Redial(func() (WriteCloserWithTimeout, error) {
+		return second, nil
+	}), FlushPeriod(0), ErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	c.conn.flush(0)
+	c.conn.mu.Unlock()
+	c.Count(testKey, 2)
+	c.Close()
+	if got, want := second.buf.String(), "test_key:2|c"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestUseConnFlush(t *testing.T) {
+	w := &closeCounter{}
+	c, err := New(UseConn(w), FlushPeriod(0), ErrorHandler(expectNoError(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	c.Increment(testKey)
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.closed != 0 {
+		t.Errorf("Flush() closed the connection set by UseConn")
+	}
+	c.Close()
+	if w.closed != 1 {
+		t.Errorf("the connection was closed %d times, want once", w.closed)
+	}
+}
+
+// closeCounter is a testBuffer failing to write once closed.
+type closeCounter struct {
+	testBuffer
+	closed int
+}
+
+func (w *closeCounter) Write(p []byte) (int, error) {
+	if w.closed > 0 {
+		return 0, errors.New("write to a closed connection")
+	}
+	return w.testBuffer.Write(p)
+}
+
+func (w *closeCounter) Close() error {
+	w.closed++
+	return nil
+}
@@ -0,0 +1,78 @@
+package statsd
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// watch starts reading from the freshly dialed c.w in the background to detect
+// the errors which are otherwise only reported by a later write, if at all.
+func (c *conn) watch() {
+	switch w := c.w.(type) {
+	case *net.UDPConn:
+		go c.watchDatagram(w)
+	case *net.TCPConn:
+		go c.watchStream(w)
+	case *net.UnixConn:
+		if !isDatagram(c.network) {
+			go c.watchStream(w)
+		}
+	}
+}
+
+// watchDatagram reads from the UDP connection w until it is closed, to report
+// the ICMP port unreachable messages received after the writes, e.g. when the
+// StatsD daemon dies. The kernel only reports them to the next read or write
+// on the socket, so they would otherwise be missed by the periodic flushes.
+func (c *conn) watchDatagram(w *net.UDPConn) {
+	buf := make([]byte, 1)
+	for {
+		_, err := w.Read(buf)
+		if err == nil {
+			// The StatsD daemon is not supposed to answer, ignore it.
+			continue
+		}
+		if !isConnRefused(err) {
+			return
+		}
+		c.wmu.Lock()
+		if c.w != w {
+			c.wmu.Unlock()
+			return
+		}
+		c.handleError(err)
+		c.wmu.Unlock()
+	}
+}
+
+// watchStream reads from the stream connection w until it is closed, to detect
+// when the StatsD daemon closes or resets it between two flushes, e.g. when it
+// restarts. The Client then reconnects right away: otherwise the next flush
+// would be written to the dead connection and lost.
+func (c *conn) watchStream(w net.Conn) {
+	buf := make([]byte, 512)
+	var err error
+	for err == nil {
+		// The StatsD daemon is not supposed to answer, ignore it.
+		_, err = w.Read(buf)
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return
+	}
+	if err == io.EOF {
+		err = errors.New("statsd: connection closed by the StatsD daemon")
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.w != w || c.closed {
+		return
+	}
+	c.logf("connection to %s %s lost (%v), reconnecting", c.network, c.addr, err)
+	c.disconnect(err)
+	if derr := c.dial(); derr != nil {
+		// The next flush will try again.
+		c.handleError(derr)
+	}
+}
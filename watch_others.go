@@ -0,0 +1,15 @@
+//go:build !plan9
+// +build !plan9
+
+package statsd
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isConnRefused reports whether err reports that the peer refused the
+// connection, e.g. after an ICMP port unreachable message.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
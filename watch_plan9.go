@@ -0,0 +1,9 @@
+package statsd
+
+import "strings"
+
+// isConnRefused reports whether err reports that the peer refused the
+// connection. Plan 9 has no errno, its errors are only strings.
+func isConnRefused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
@@ -0,0 +1,104 @@
+package statsd
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatchDatagram(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the ICMP errors are reported differently on " + runtime.GOOS)
+	}
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.LocalAddr().String()
+	l.Close()
+
+	errs := make(chan error, 10)
+	c, err := New(Address(addr), UDPCheck(false), FlushPeriod(0), ErrorHandler(func(err error) {
+		errs <- err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	err = c.conn.flush(0)
+	c.conn.mu.Unlock()
+	if err != nil {
+		t.Fatalf("flush() = %v, want the write to succeed", err)
+	}
+
+	select {
+	case err := <-errs:
+		if !isConnRefused(err) {
+			t.Errorf("ErrorHandler got %v, want ECONNREFUSED", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the unreachable port was not reported")
+	}
+}
+
+func TestWatchStream(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	disconnected := make(chan error, 1)
+	c, err := New(Address(l.Addr().String()), Network("tcp"), FlushPeriod(0), ErrorHandler(expectNoError(t)),
+		OnDisconnect(func(_ string, err error) {
+			if err != nil {
+				disconnected <- err
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	(<-accepted).Close()
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+		defer conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("the Client did not reconnect")
+	}
+	if err := <-disconnected; err == nil {
+		t.Error("OnDisconnect should get the error")
+	}
+
+	c.Increment(testKey)
+	c.conn.mu.Lock()
+	err = c.conn.flush(0)
+	c.conn.mu.Unlock()
+	if err != nil {
+		t.Fatalf("flush() = %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), "test_key:1|c\n"; got != want {
+		t.Errorf("Invalid output, got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Package worker instruments pools of workers processing jobs with a StatsD
+// Client.
+//
+// A Pool sends the following metrics, prefixed by its name:
+//   - in_flight: a gauge of the number of jobs running
+//   - queue_depth: a gauge of the number of jobs waiting, see Pool.QueueDepth
+//   - job: the timing of each job
+//   - job.success and job.error: counters of the jobs which returned nil or an
+//     error, or panicked
+//   - panics: a counter of the jobs which panicked
+package worker
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/msaf1980/statsd"
+)
+
+// A Pool instruments the jobs of a pool of workers. It is safe for
+// concurrent use.
+type Pool struct {
+	inFlight int64
+	c        *statsd.Client
+	onPanic  func(v interface{})
+}
+
+// An Option configures a Pool.
+type Option func(*Pool)
+
+// OnPanic makes the Pool recover the panics of the jobs and call f with the
+// value they panicked with. The job then returns a *PanicError.
+//
+// By default, the panics are counted and go on.
+func OnPanic(f func(v interface{})) Option {
+	return Option(func(p *Pool) {
+		p.onPanic = f
+	})
+}
+
+// A PanicError is the error returned by a job which panicked when the OnPanic
+// option is used.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("worker: job panicked: %v", e.Value)
+}
+
+// New returns a Pool sending its metrics through c, prefixed by name and a
+// dot. The in_flight gauge is sent at every flush period of c, or at every poll
+// period if the PollPeriod option is used.
+func New(c *statsd.Client, name string, opts ...Option) *Pool {
+	p := &Pool{c: c.WithPrefix(name + ".")}
+	for _, o := range opts {
+		o(p)
+	}
+	p.c.GaugeFunc("in_flight", func() float64 {
+		return float64(p.InFlight())
+	})
+	return p
+}
+
+// QueueDepth registers f to be called periodically to send the number of jobs
+// waiting to be run as the queue_depth gauge, e.g. the length of the channel
+// the workers receive the jobs from:
+//
+//	p.QueueDepth(func() int { return len(jobs) })
+func (p *Pool) QueueDepth(f func() int) {
+	p.c.GaugeFunc("queue_depth", func() float64 {
+		return float64(f())
+	})
+}
+
+// InFlight returns the number of jobs running.
+func (p *Pool) InFlight() int {
+	return int(atomic.LoadInt64(&p.inFlight))
+}
+
+// Run runs job, sending its metrics, and returns its error.
+func (p *Pool) Run(job func() error) error {
+	atomic.AddInt64(&p.inFlight, 1)
+	return p.run(job)
+}
+
+// run runs job, counted in flight by the caller.
+func (p *Pool) run(job func() error) (err error) {
+	defer func() {
+		atomic.AddInt64(&p.inFlight, -1)
+		if v := recover(); v != nil {
+			p.c.Increment("panics")
+			if p.onPanic == nil {
+				panic(v)
+			}
+			p.onPanic(v)
+			err = &PanicError{Value: v}
+		}
+	}()
+	return p.c.Observe("job", job)
+}
+
+// Go runs job in a new goroutine, sending its metrics. Its error is dropped,
+// so it is meant for jobs handling their errors and used with the OnPanic
+// option, as a panic stops the program otherwise.
+func (p *Pool) Go(job func() error) {
+	// The job is counted in flight before Go returns.
+	atomic.AddInt64(&p.inFlight, 1)
+	go p.run(job)
+}
+
+// Wrap returns a function running job like Run, e.g. to instrument the jobs
+// given to an existing pool of workers.
+func (p *Pool) Wrap(job func() error) func() error {
+	return func() error {
+		return p.Run(job)
+	}
+}
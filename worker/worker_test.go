@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/statsd"
+	"github.com/msaf1980/statsd/statsdtest"
+)
+
+func TestPool(t *testing.T) {
+	srv, err := statsdtest.NewServer("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	c, err := statsd.New(append(srv.Options(), statsd.FlushPeriod(10*time.Millisecond))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var panics []interface{}
+	p := New(c, "pool", OnPanic(func(v interface{}) {
+		panics = append(panics, v)
+	}))
+	p.QueueDepth(func() int { return 3 })
+
+	testErr := errors.New("test error")
+	if err := p.Run(func() error {
+		if got := p.InFlight(); got != 1 {
+			t.Errorf("InFlight() = %d, want 1", got)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+	if err := p.Wrap(func() error { return testErr })(); err != testErr {
+		t.Errorf("Run() = %v, want %v", err, testErr)
+	}
+	err = p.Run(func() error { panic("test panic") })
+	if pe, ok := err.(*PanicError); !ok || pe.Value != "test panic" {
+		t.Errorf("Run() = %v, want a *PanicError", err)
+	}
+	if len(panics) != 1 {
+		t.Errorf("OnPanic called %d times, want 1", len(panics))
+	}
+
+	block := make(chan struct{})
+	p.Go(func() error {
+		<-block
+		return nil
+	})
+	waitLine(t, srv, "pool.in_flight:1|g")
+	close(block)
+	// The metrics of the job are flushed with or before the gauge.
+	waitLine(t, srv, "pool.in_flight:0|g")
+
+	statsdtest.AssertCount(t, srv, "pool.job.success", 2)
+	statsdtest.AssertCount(t, srv, "pool.job.error", 2)
+	statsdtest.AssertCount(t, srv, "pool.panics", 1)
+	statsdtest.AssertTiming(t, srv, "pool.job", 0, time.Second)
+	statsdtest.AssertGauge(t, srv, "pool.queue_depth", 3)
+}
+
+// waitLine waits until srv received line.
+func waitLine(t *testing.T, srv *statsdtest.Server, line string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		lines := srv.Lines()
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i] == line {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%q not received", line)
+}
+
+func TestPoolPanic(t *testing.T) {
+	c, err := statsd.New(statsd.Mute(true), statsd.Record(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(c, "pool")
+	defer func() {
+		if v := recover(); v != "test panic" {
+			t.Errorf("recover() = %v, want the panic of the job", v)
+		}
+		if got := p.InFlight(); got != 0 {
+			t.Errorf("InFlight() = %d, want 0", got)
+		}
+		statsdtest.AssertCount(t, statsdtest.Lines(c.Snapshot()), "pool.panics", 1)
+	}()
+	p.Run(func() error { panic("test panic") })
+}